@@ -0,0 +1,398 @@
+package startup
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/jessevdk/go-flags"
+	"go.yaml.in/yaml/v3"
+)
+
+// configFileOptions is added to every parser, so --config is known
+// regardless of the options struct the application passes in.
+type configFileOptions struct {
+	ConfigFiles []string `long:"config" env:"CONFIG_FILE" env-delim:"," description:"Read option values from a yaml, toml or json file. Can be specified multiple times, later files win."`
+	PrintConfig bool     `long:"print-config" description:"Prints the effective configuration with secrets redacted and exits."`
+
+	SecretReloadInterval time.Duration `long:"secret-reload-interval" env:"SECRET_RELOAD_INTERVAL" description:"Re-read secrets that were read from files in this interval, to pick up rotated credentials. Disabled if zero."`
+
+	StopOnSignal bool `long:"stop-on-signal" env:"STOP_ON_SIGNAL" description:"Stop all components in order on SIGINT or SIGTERM and exit. The http server of startup_http does this by default."`
+}
+
+// configPrescan is parsed before the real options, to find out which config
+// files to load and which environment overlays to apply.
+type configPrescan struct {
+	configFileOptions
+	Environment string `long:"environment" env:"ENVIRONMENT"`
+}
+
+// configValues maps the long name of an option (including its namespace)
+// to the values read from the config files.
+type configValues map[string][]string
+
+// applyConfigFiles loads all config files referenced by --config or CONFIG_FILE
+// and installs their values as option defaults on the parser. go-flags prefers
+// environment variables and command line flags over defaults, which gives us the
+// precedence order defaults < file < env < flags.
+//
+// For every file like config.yaml, an environment overlay config.<environment>.yaml
+// is loaded after all base files, if it exists.
+func applyConfigFiles(parser *flags.Parser, options flags.Options) (configValues, error) {
+	prescan, err := prescanConfigOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(prescan.ConfigFiles) == 0 {
+		return nil, nil
+	}
+
+	values := configValues{}
+
+	var overlays []string
+	for _, file := range prescan.ConfigFiles {
+		fileValues, err := readConfigFile(parser, file, options)
+		if err != nil {
+			return nil, err
+		}
+
+		values.merge(fileValues)
+	}
+
+	environment := configEnvironment(prescan.Environment, values)
+
+	for _, file := range prescan.ConfigFiles {
+		overlay := overlayFileName(file, environment)
+		if _, err := os.Stat(overlay); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return nil, fmt.Errorf("config file %q: %w", overlay, err)
+		}
+
+		overlays = append(overlays, overlay)
+	}
+
+	for _, file := range overlays {
+		fileValues, err := readConfigFile(parser, file, options)
+		if err != nil {
+			return nil, err
+		}
+
+		values.merge(fileValues)
+	}
+
+	for _, option := range optionsIter(parser.Command.Group) {
+		if value, ok := values[option.LongNameWithNamespace()]; ok {
+			option.Default = value
+		}
+	}
+
+	return values, nil
+}
+
+func prescanConfigOptions(options flags.Options) (configPrescan, error) {
+	var prescan configPrescan
+
+	parser := flags.NewParser(&prescan, flags.IgnoreUnknown|(options&flags.PassDoubleDash))
+	parser.NamespaceDelimiter = "-"
+
+	if _, err := parser.Parse(); err != nil {
+		return prescan, fmt.Errorf("lookup config files: %w", err)
+	}
+
+	return prescan, nil
+}
+
+// configEnvironment resolves the environment the same way BaseOptions does:
+// flag or ENVIRONMENT first, then the config files, then STAGE.
+func configEnvironment(environment string, values configValues) string {
+	if environment != "" {
+		return environment
+	}
+
+	if value := values["environment"]; len(value) > 0 && value[0] != "" {
+		return value[0]
+	}
+
+	if stage := os.Getenv("STAGE"); stage != "" {
+		return stage
+	}
+
+	return "development"
+}
+
+func overlayFileName(file, environment string) string {
+	ext := filepath.Ext(file)
+	return strings.TrimSuffix(file, ext) + "." + environment + ext
+}
+
+func readConfigFile(parser *flags.Parser, file string, options flags.Options) (configValues, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read config file %q: %w", file, err)
+	}
+
+	doc, err := decodeConfigFile(file, content)
+	if err != nil {
+		return nil, fmt.Errorf("decode config file %q: %w", file, err)
+	}
+
+	values := configValues{}
+
+	var unknown []string
+	if err := collectConfigValues(parser.Command.Group, "", doc, values, &unknown); err != nil {
+		return nil, fmt.Errorf("config file %q: %w", file, err)
+	}
+
+	if len(unknown) > 0 {
+		if options&flags.IgnoreUnknown == 0 {
+			return nil, fmt.Errorf("config file %q: unknown options %s", file, strings.Join(unknown, ", "))
+		}
+
+		log.Warn("Found ignored options in config file",
+			slog.String("file", file),
+			slog.Any("options", unknown))
+	}
+
+	log.Info("Loaded config file", slog.String("file", file), slog.Int("count", len(values)))
+
+	return values, nil
+}
+
+func decodeConfigFile(file string, content []byte) (map[string]any, error) {
+	var doc map[string]any
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(content, &doc); err != nil {
+			return nil, err
+		}
+
+	case ".toml":
+		if err := toml.Unmarshal(content, &doc); err != nil {
+			return nil, err
+		}
+
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.UseNumber()
+
+		if err := decoder.Decode(&doc); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, errors.New("unsupported file type, expected .yaml, .toml or .json")
+	}
+
+	return doc, nil
+}
+
+// collectConfigValues walks the document and maps its keys to options of the given group.
+// A key either names an option by its long name, or names a group by its
+// description or namespace. In the latter case the value must be a nested
+// document holding the options of this group.
+func collectConfigValues(group *flags.Group, path string, doc map[string]any, values configValues, unknown *[]string) error {
+	for _, key := range sortedKeys(doc) {
+		value := doc[key]
+
+		option, err := findConfigOption(group, key)
+		if err != nil {
+			return fmt.Errorf("option %q: %w", path+key, err)
+		}
+
+		if option != nil {
+			if value == nil {
+				// an empty key like `db-url:` in yaml keeps the default of the option
+				continue
+			}
+
+			optionValues, err := configOptionValues(option, value)
+			if err != nil {
+				return fmt.Errorf("option %q: %w", path+key, err)
+			}
+
+			values[option.LongNameWithNamespace()] = optionValues
+			continue
+		}
+
+		if nested, ok := value.(map[string]any); ok {
+			if subGroup := findConfigGroup(group, key); subGroup != nil {
+				if err := collectConfigValues(subGroup, path+key+".", nested, values, unknown); err != nil {
+					return err
+				}
+
+				continue
+			}
+		}
+
+		*unknown = append(*unknown, path+key)
+	}
+
+	return nil
+}
+
+// findConfigOption looks up the option of the key in the group and its sub groups. The
+// key is either the full name of the option or, for options within a namespace, the
+// name without the namespace. An ambiguous name is an error.
+func findConfigOption(group *flags.Group, key string) (*flags.Option, error) {
+	var matches []*flags.Option
+
+	for _, option := range optionsIter(group) {
+		if option.LongName == "" {
+			continue
+		}
+
+		if option.LongNameWithNamespace() == key {
+			return option, nil
+		}
+
+		if option.LongName == key {
+			matches = append(matches, option)
+		}
+	}
+
+	if len(matches) > 1 {
+		var names []string
+		for _, option := range matches {
+			names = append(names, option.LongNameWithNamespace())
+		}
+
+		return nil, fmt.Errorf("ambiguous, matches %s", strings.Join(names, ", "))
+	}
+
+	if len(matches) == 1 {
+		return matches[0], nil
+	}
+
+	return nil, nil
+}
+
+// findConfigGroup looks up a group below the given group. Intermediate groups do
+// not need to be named, e.g. the implicit "Application Options" group of the parser.
+func findConfigGroup(group *flags.Group, key string) *flags.Group {
+	for _, subGroup := range group.Groups() {
+		if strings.EqualFold(subGroup.ShortDescription, key) || (subGroup.Namespace != "" && subGroup.Namespace == key) {
+			return subGroup
+		}
+
+		if found := findConfigGroup(subGroup, key); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+// optionsIter yields all options of the group and its sub groups, together
+// with the group the option is defined in.
+func optionsIter(group *flags.Group) iter.Seq2[*flags.Group, *flags.Option] {
+	return func(yield func(*flags.Group, *flags.Option) bool) {
+		for _, option := range group.Options() {
+			if !yield(group, option) {
+				return
+			}
+		}
+
+		for _, subGroup := range group.Groups() {
+			for optionGroup, option := range optionsIter(subGroup) {
+				if !yield(optionGroup, option) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// configOptionValues converts a value from a config file into the string
+// representation go-flags expects for defaults.
+func configOptionValues(option *flags.Option, value any) ([]string, error) {
+	switch value := value.(type) {
+	case []any:
+		var result []string
+		for _, item := range value {
+			str, err := configScalar(item)
+			if err != nil {
+				return nil, err
+			}
+
+			result = append(result, str)
+		}
+
+		return result, nil
+
+	case []map[string]any:
+		return nil, errors.New("tables are not supported as option values")
+
+	case map[string]any:
+		if option.Field().Type.Kind() != reflect.Map {
+			return nil, errors.New("option does not accept a map value")
+		}
+
+		var result []string
+		for _, key := range sortedKeys(value) {
+			str, err := configScalar(value[key])
+			if err != nil {
+				return nil, err
+			}
+
+			result = append(result, key+":"+str)
+		}
+
+		return result, nil
+
+	default:
+		str, err := configScalar(value)
+		if err != nil {
+			return nil, err
+		}
+
+		return []string{str}, nil
+	}
+}
+
+func configScalar(value any) (string, error) {
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case int:
+		return strconv.Itoa(value), nil
+	case int64:
+		return strconv.FormatInt(value, 10), nil
+	case uint64:
+		return strconv.FormatUint(value, 10), nil
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case json.Number:
+		return value.String(), nil
+	case time.Time:
+		return value.Format(time.RFC3339Nano), nil
+	default:
+		return "", fmt.Errorf("unsupported value of type %T", value)
+	}
+}
+
+func (values configValues) merge(other configValues) {
+	maps.Copy(values, other)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	return slices.Sorted(maps.Keys(m))
+}
@@ -0,0 +1,274 @@
+package startup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type configTestOptions struct {
+	Base struct {
+		Environment string `long:"environment" env:"ENVIRONMENT"`
+	} `group:"Base configuration"`
+
+	HTTP struct {
+		Address string   `long:"http-address" env:"HTTP_ADDRESS" default:":3080"`
+		Verbose bool     `long:"http-verbose" env:"HTTP_VERBOSE"`
+		Tags    []string `long:"http-tag" env:"HTTP_TAG" env-delim:","`
+	} `group:"HTTP server settings"`
+
+	Database struct {
+		URL      string            `long:"url" env:"DB_URL" default:"postgres://localhost"`
+		PoolSize int               `long:"pool" env:"DB_POOL" default:"8"`
+		Params   map[string]string `long:"param"`
+	} `group:"Database" namespace:"db"`
+}
+
+// writeConfigFile writes a config file into a temporary directory and returns its path.
+func writeConfigFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// withCleanConfigEnv makes sure that environment variables of the host do not leak into the test.
+func withCleanConfigEnv(t *testing.T) {
+	t.Helper()
+
+	keys := []string{
+		"CONFIG_FILE", "ENVIRONMENT", "STAGE",
+		"HTTP_ADDRESS", "HTTP_VERBOSE", "HTTP_TAG", "DB_URL", "DB_POOL",
+		"TEST_ADDRESS", "TEST_DATABASE", "TEST_PASSWORD", "TEST_POOL", "TEST_TOKEN", "TEST_TOKEN_FILE",
+	}
+
+	for _, key := range keys {
+		t.Setenv(key, "")
+		require.NoError(t, os.Unsetenv(key))
+	}
+}
+
+func TestConfigFileFormats(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": `
+http-address: ":8080"
+HTTP server settings:
+  http-verbose: true
+  http-tag: [a, b]
+db:
+  pool: 16
+  param:
+    sslmode: disable
+`,
+		"config.toml": `
+http-address = ":8080"
+db-pool = 16
+
+["HTTP server settings"]
+http-verbose = true
+http-tag = ["a", "b"]
+
+[db.param]
+sslmode = "disable"
+`,
+		"config.json": `{
+  "http-address": ":8080",
+  "http-verbose": true,
+  "http-tag": ["a", "b"],
+  "Database": {"pool": 16, "param": {"sslmode": "disable"}}
+}`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			withEmptyArgs(t)
+			withCleanConfigEnv(t)
+
+			t.Setenv("CONFIG_FILE", writeConfigFile(t, t.TempDir(), name, content))
+
+			var opts configTestOptions
+			require.NoError(t, ParseCommandLine(t.Context(), &opts))
+
+			require.Equal(t, ":8080", opts.HTTP.Address)
+			require.True(t, opts.HTTP.Verbose)
+			require.Equal(t, []string{"a", "b"}, opts.HTTP.Tags)
+			require.Equal(t, 16, opts.Database.PoolSize)
+			require.Equal(t, map[string]string{"sslmode": "disable"}, opts.Database.Params)
+
+			// values not in the file keep their defaults
+			require.Equal(t, "postgres://localhost", opts.Database.URL)
+		})
+	}
+}
+
+func TestConfigFilePrecedence(t *testing.T) {
+	withCleanConfigEnv(t)
+
+	config := writeConfigFile(t, t.TempDir(), "config.yaml", `
+http-address: ":8080"
+db-url: "postgres://file"
+db-pool: 16
+`)
+
+	old := os.Args
+	os.Args = []string{"cmd", "--config", config, "--db-pool=32"}
+	t.Cleanup(func() { os.Args = old })
+
+	t.Setenv("DB_URL", "postgres://env")
+
+	var opts configTestOptions
+	require.NoError(t, ParseCommandLine(t.Context(), &opts))
+
+	// file overwrites the default
+	require.Equal(t, ":8080", opts.HTTP.Address)
+
+	// env overwrites the file
+	require.Equal(t, "postgres://env", opts.Database.URL)
+
+	// flag overwrites the file
+	require.Equal(t, 32, opts.Database.PoolSize)
+}
+
+func TestConfigFileEnvironmentOverlay(t *testing.T) {
+	withEmptyArgs(t)
+	withCleanConfigEnv(t)
+
+	dir := t.TempDir()
+
+	base := writeConfigFile(t, dir, "config.yaml", `
+environment: staging
+http-address: ":8080"
+db-pool: 16
+`)
+
+	writeConfigFile(t, dir, "config.staging.yaml", `
+db-pool: 4
+`)
+
+	writeConfigFile(t, dir, "config.production.yaml", `
+db-pool: 64
+`)
+
+	t.Setenv("CONFIG_FILE", base)
+
+	var opts configTestOptions
+	require.NoError(t, ParseCommandLine(t.Context(), &opts))
+
+	require.Equal(t, "staging", opts.Base.Environment)
+	require.Equal(t, ":8080", opts.HTTP.Address)
+	require.Equal(t, 4, opts.Database.PoolSize)
+
+	// the environment variable selects a different overlay
+	t.Setenv("ENVIRONMENT", "production")
+
+	opts = configTestOptions{}
+	require.NoError(t, ParseCommandLine(t.Context(), &opts))
+
+	require.Equal(t, "production", opts.Base.Environment)
+	require.Equal(t, 64, opts.Database.PoolSize)
+}
+
+func TestConfigFileLayers(t *testing.T) {
+	withEmptyArgs(t)
+	withCleanConfigEnv(t)
+
+	dir := t.TempDir()
+
+	first := writeConfigFile(t, dir, "first.yaml", `
+http-address: ":8080"
+db-pool: 16
+`)
+
+	second := writeConfigFile(t, dir, "second.json", `{"db-pool": 24}`)
+
+	t.Setenv("CONFIG_FILE", first+","+second)
+
+	var opts configTestOptions
+	require.NoError(t, ParseCommandLine(t.Context(), &opts))
+
+	require.Equal(t, ":8080", opts.HTTP.Address)
+	require.Equal(t, 24, opts.Database.PoolSize)
+}
+
+func TestConfigFileNullKeepsDefault(t *testing.T) {
+	withEmptyArgs(t)
+	withCleanConfigEnv(t)
+
+	dir := t.TempDir()
+
+	first := writeConfigFile(t, dir, "first.yaml", `
+db-pool: 16
+`)
+
+	second := writeConfigFile(t, dir, "second.yaml", `
+http-address: null
+db-url:
+db-pool: ~
+`)
+
+	t.Setenv("CONFIG_FILE", first+","+second)
+
+	var opts configTestOptions
+	require.NoError(t, ParseCommandLine(t.Context(), &opts))
+
+	require.Equal(t, ":3080", opts.HTTP.Address)
+	require.Equal(t, "postgres://localhost", opts.Database.URL)
+
+	// a null in a later file does not erase the value of an earlier one
+	require.Equal(t, 16, opts.Database.PoolSize)
+}
+
+func TestConfigFileUnknownOption(t *testing.T) {
+	withEmptyArgs(t)
+	withCleanConfigEnv(t)
+
+	t.Setenv("CONFIG_FILE", writeConfigFile(t, t.TempDir(), "config.yaml", `
+http-address: ":8080"
+does-not-exist: 1
+`))
+
+	var opts configTestOptions
+	err := ParseCommandLine(t.Context(), &opts)
+	require.ErrorContains(t, err, "does-not-exist")
+}
+
+func TestConfigFileAmbiguousOption(t *testing.T) {
+	withEmptyArgs(t)
+	withCleanConfigEnv(t)
+
+	type replicaOptions struct {
+		Base struct {
+			Environment string `long:"environment" env:"ENVIRONMENT"`
+		} `group:"Base configuration"`
+
+		Primary struct {
+			URL string `long:"url"`
+		} `group:"Primary" namespace:"primary"`
+
+		Replica struct {
+			URL string `long:"url"`
+		} `group:"Replica" namespace:"replica"`
+	}
+
+	t.Setenv("CONFIG_FILE", writeConfigFile(t, t.TempDir(), "config.yaml", `
+url: postgres://primary
+`))
+
+	var opts replicaOptions
+	err := ParseCommandLine(t.Context(), &opts)
+	require.ErrorContains(t, err, "ambiguous")
+
+	// the namespace resolves the option
+	t.Setenv("CONFIG_FILE", writeConfigFile(t, t.TempDir(), "config.yaml", `
+replica:
+  url: postgres://replica
+`))
+
+	opts = replicaOptions{}
+	require.NoError(t, ParseCommandLine(t.Context(), &opts))
+	require.Equal(t, "postgres://replica", opts.Replica.URL)
+	require.Empty(t, opts.Primary.URL)
+}
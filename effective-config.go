@@ -0,0 +1,132 @@
+package startup
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/flachnetz/startup/v2/startup_base"
+	"github.com/jessevdk/go-flags"
+)
+
+const redacted = "[redacted]"
+
+// effectiveConfig collects the resolved value of every option together with
+// the source it came from. Must be called after the parser ran, but before
+// secret files are resolved.
+func effectiveConfig(parser *flags.Parser, fileValues configValues) []startup_base.ConfigValue {
+	var result []startup_base.ConfigValue
+
+	for group, option := range optionsIter(parser.Command.Group) {
+		name := option.LongNameWithNamespace()
+		if name == "" {
+			continue
+		}
+
+		envKey := option.EnvKeyWithNamespace()
+		secret := option.Field().Tag.Get("secret") == "true"
+		value := formatOptionValue(option.Value())
+
+		// the reference to a secret file is not a secret itself
+		secretFile, hasSecretFile := secretFileOf(option)
+		if hasSecretFile {
+			value = fileReferencePrefix + secretFile
+			secret = false
+		}
+
+		var source startup_base.ConfigSource
+		switch {
+		case hasSecretFile && !strings.HasPrefix(formatOptionValue(option.Value()), fileReferencePrefix):
+			// the file was referenced by the file-env variable
+			source = startup_base.ConfigSourceEnv
+			envKey = secretFileEnvKey(option)
+
+		case option.IsSet() && !option.IsSetDefault():
+			source = startup_base.ConfigSourceFlag
+
+		case envKey != "" && isEnvSet(envKey):
+			source = startup_base.ConfigSourceEnv
+
+		case fileValues[name] != nil:
+			source = startup_base.ConfigSourceFile
+
+		default:
+			source = startup_base.ConfigSourceDefault
+		}
+
+		if secret {
+			value = redactValue(value)
+		}
+
+		result = append(result, startup_base.ConfigValue{
+			Group:  group.ShortDescription,
+			Name:   name,
+			Env:    envKey,
+			Value:  value,
+			Source: source,
+			Secret: secret,
+		})
+	}
+
+	return result
+}
+
+func isEnvSet(key string) bool {
+	_, ok := os.LookupEnv(key)
+	return ok
+}
+
+// formatOptionValue formats the value the same way it would be passed on the
+// command line, if the type supports it.
+func formatOptionValue(value any) string {
+	if value == nil {
+		return ""
+	}
+
+	// MarshalFlag is usually implemented on the pointer type
+	ptr := reflect.New(reflect.TypeOf(value))
+	ptr.Elem().Set(reflect.ValueOf(value))
+
+	if marshaler, ok := ptr.Interface().(flags.Marshaler); ok {
+		str, err := marshaler.MarshalFlag()
+		if err != nil {
+			return ""
+		}
+
+		return str
+	}
+
+	return fmt.Sprint(value)
+}
+
+// redactValue hides a secret value. For urls only the password is hidden,
+// so the host and database stay visible.
+func redactValue(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	if parsed, err := url.Parse(value); err == nil && parsed.User != nil {
+		if _, hasPassword := parsed.User.Password(); hasPassword {
+			return parsed.Redacted()
+		}
+	}
+
+	return redacted
+}
+
+// writeEffectiveConfig prints the effective configuration as a table.
+func writeEffectiveConfig(w io.Writer, values []startup_base.ConfigValue) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	_, _ = fmt.Fprintln(tw, "OPTION\tVALUE\tSOURCE\tENV")
+	for _, value := range values {
+		_, _ = fmt.Fprintf(tw, "--%s\t%s\t%s\t%s\n", value.Name, value.Value, value.Source, value.Env)
+	}
+
+	return tw.Flush()
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/flachnetz/startup/v2/startup_base"
 	"github.com/stretchr/testify/require"
 )
 
@@ -77,8 +78,10 @@ func TestInitializeInjectsSeenDependencies(t *testing.T) {
 		"Consumer should receive a pointer to the real Leaf field")
 }
 
-// diMissing is never used as a field, so it is never "seen".
-type diMissing struct{}
+// diMissing is an options struct that is never used as a field, so it is never "seen".
+type diMissing struct {
+	Name string `long:"missing-name"`
+}
 
 // diOptional depends on a *diMissing, which is optional and must be nil when
 // no diMissing value was seen.
@@ -107,12 +110,12 @@ func TestInitializeOptionalPointerIsNilWhenUnseen(t *testing.T) {
 }
 
 // diRequiresMissing depends on a diMissing by value (not a pointer), which is a
-// required dependency. Since it is never seen, Initialize must panic.
+// required dependency. Since it is never seen, parsing must fail.
 type diRequiresMissing struct{}
 
 func (diRequiresMissing) Initialize(missing diMissing) {}
 
-func TestInitializePanicsOnMissingRequiredDependency(t *testing.T) {
+func TestInitializeFailsOnMissingRequiredDependency(t *testing.T) {
 	withEmptyArgs(t)
 
 	type options struct {
@@ -120,7 +123,114 @@ func TestInitializePanicsOnMissingRequiredDependency(t *testing.T) {
 	}
 
 	var opts options
-	require.Panics(t, func() {
-		_ = ParseCommandLine(t.Context(), &opts)
-	}, "missing required dependency should panic")
+	err := ParseCommandLine(t.Context(), &opts)
+	require.ErrorContains(t, err, "unresolvable dependencies")
+	require.ErrorContains(t, err, "diRequiresMissing.Initialize() requires startup.diMissing")
+}
+
+// diResource is not an options struct, so a pointer to it is not optional.
+type diResource struct{}
+
+// diRequiresResource depends on a *diResource that nothing provides.
+type diRequiresResource struct{}
+
+func (diRequiresResource) Initialize(resource *diResource) {}
+
+func TestInitializeFailsOnMissingPointerDependency(t *testing.T) {
+	withEmptyArgs(t)
+
+	type options struct {
+		Requires diRequiresResource
+	}
+
+	var opts options
+	err := ParseCommandLine(t.Context(), &opts)
+	require.ErrorContains(t, err, "diRequiresResource.Initialize() requires *startup.diResource")
+}
+
+func TestInitializeOrdersByDependencies(t *testing.T) {
+	withEmptyArgs(t)
+
+	// the consumer is declared before its dependency
+	type options struct {
+		Consumer diConsumer
+		Leaf     diLeaf
+	}
+
+	var opts options
+	require.NoError(t, ParseCommandLine(t.Context(), &opts))
+
+	require.Equal(t, 1, opts.Consumer.initCount)
+	require.Equal(t, 1, opts.Consumer.gotLeafValue.initCount,
+		"Leaf must be initialized before Consumer")
+	require.Same(t, &opts.Leaf, opts.Consumer.gotLeafPtr)
+}
+
+// diGreeter is an interface that is provided by diProvider.
+type diGreeter interface {
+	Greet() string
+}
+
+type diGreeting string
+
+func (g diGreeting) Greet() string {
+	return string(g)
+}
+
+// diProvider provides a diGreeter, that is only available after Initialize was called.
+type diProvider struct {
+	greeting diGreeting
+}
+
+func (p *diProvider) Initialize() {
+	p.greeting = "hello"
+}
+
+func (p *diProvider) Provides() []startup_base.Provider {
+	return []startup_base.Provider{
+		startup_base.Provide(func() diGreeter { return p.greeting }),
+	}
+}
+
+type diGreeterConsumer struct {
+	greeting string
+}
+
+func (c *diGreeterConsumer) Initialize(greeter diGreeter) {
+	c.greeting = greeter.Greet()
+}
+
+func TestInitializeInjectsProvidedValues(t *testing.T) {
+	withEmptyArgs(t)
+
+	type options struct {
+		Consumer diGreeterConsumer
+		Provider diProvider
+	}
+
+	var opts options
+	require.NoError(t, ParseCommandLine(t.Context(), &opts))
+
+	require.Equal(t, "hello", opts.Consumer.greeting)
+}
+
+type (
+	diCycleA struct{}
+	diCycleB struct{}
+)
+
+func (diCycleA) Initialize(diCycleB) {}
+func (diCycleB) Initialize(diCycleA) {}
+
+func TestInitializeFailsOnCycle(t *testing.T) {
+	withEmptyArgs(t)
+
+	type options struct {
+		A diCycleA
+		B diCycleB
+	}
+
+	var opts options
+	err := ParseCommandLine(t.Context(), &opts)
+	require.ErrorContains(t, err, "dependency cycle")
 }
@@ -0,0 +1,234 @@
+package startup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+
+	"github.com/flachnetz/startup/v2/startup_base"
+)
+
+// injectNode is a struct field of the options struct. It can be injected into
+// Initialize methods, might have an Initialize method itself and might provide
+// additional values using a Provides method.
+type injectNode struct {
+	value reflect.Value
+	init  reflect.Value
+
+	// indices of the nodes that must be initialized before this one
+	dependencies []int
+}
+
+// injectSource resolves a value that can be injected into an Initialize method.
+type injectSource struct {
+	node  int
+	value func() reflect.Value
+}
+
+// initializeOptions calls the Initialize methods of all struct fields in opts. Parameters
+// are resolved from other struct fields and values declared with startup_base.Provide.
+// Initializers run after the initializers of their dependencies, otherwise in field order.
+func initializeOptions(ctx context.Context, opts reflect.Value) error {
+	var nodes []*injectNode
+	for fieldValue := range fieldsIter(opts) {
+		if fieldValue.Kind() != reflect.Struct {
+			continue
+		}
+
+		nodes = append(nodes, &injectNode{
+			value: fieldValue,
+			init:  findInitializerMethod(fieldValue),
+		})
+	}
+
+	sources, err := collectInjectSources(nodes)
+	if err != nil {
+		return err
+	}
+
+	// resolve the parameters of all initializers
+	var unresolved []string
+	params := make([][]injectSource, len(nodes))
+
+	for idx, node := range nodes {
+		if !node.init.IsValid() {
+			continue
+		}
+
+		for in := range node.init.Type().Ins() {
+			source, ok := resolveInjectSource(ctx, sources, in)
+			if !ok {
+				unresolved = append(unresolved, fmt.Sprintf("%s.Initialize() requires %s", node.value.Type(), in))
+				continue
+			}
+
+			if source.node >= 0 && source.node != idx {
+				node.dependencies = append(node.dependencies, source.node)
+			}
+
+			params[idx] = append(params[idx], source)
+		}
+	}
+
+	if len(unresolved) > 0 {
+		return fmt.Errorf("unresolvable dependencies:\n  %s", strings.Join(unresolved, "\n  "))
+	}
+
+	order, err := initializeOrder(nodes)
+	if err != nil {
+		return err
+	}
+
+	for _, idx := range order {
+		node := nodes[idx]
+		if !node.init.IsValid() {
+			continue
+		}
+
+		var inputValues []reflect.Value
+		for _, source := range params[idx] {
+			inputValues = append(inputValues, source.value())
+		}
+
+		if _, ok := node.value.Interface().(startup_base.BaseOptions); !ok {
+			log.Info("Calling Initialize()", slog.String("type", node.value.Type().String()))
+		}
+
+		node.init.Call(inputValues)
+	}
+
+	return nil
+}
+
+// collectInjectSources builds a lookup table of all injectable values by type.
+// For struct fields T, both T and *T are injectable. If a struct type occurs
+// multiple times, the first field wins.
+func collectInjectSources(nodes []*injectNode) (map[reflect.Type]injectSource, error) {
+	type providesValues interface {
+		Provides() []startup_base.Provider
+	}
+
+	sources := map[reflect.Type]injectSource{}
+
+	for idx, node := range nodes {
+		fieldValue := node.value
+
+		if _, exists := sources[fieldValue.Type()]; !exists {
+			sources[fieldValue.Type()] = injectSource{node: idx, value: func() reflect.Value { return fieldValue }}
+			sources[reflect.PointerTo(fieldValue.Type())] = injectSource{node: idx, value: fieldValue.Addr}
+		}
+
+		method := findMethod(fieldValue, "Provides")
+		if !method.IsValid() {
+			continue
+		}
+
+		provides, ok := method.Interface().(func() []startup_base.Provider)
+		if !ok {
+			var iface providesValues
+			return nil, fmt.Errorf("%s.Provides() must match %T", fieldValue.Type(), iface)
+		}
+
+		for _, provider := range provides() {
+			if existing, exists := sources[provider.Type()]; exists {
+				return nil, fmt.Errorf("%s is provided by both %s and %s",
+					provider.Type(), nodes[existing.node].value.Type(), fieldValue.Type())
+			}
+
+			sources[provider.Type()] = injectSource{node: idx, value: provider.Value}
+		}
+	}
+
+	return sources, nil
+}
+
+// resolveInjectSource finds the source for a parameter of the given type.
+// A pointer to an options struct indicates optional options and resolves to nil if
+// the options are not part of the application. Other pointers, e.g. a *sqlx.DB,
+// must be provided like any other value.
+func resolveInjectSource(ctx context.Context, sources map[reflect.Type]injectSource, in reflect.Type) (injectSource, bool) {
+	switch source, ok := sources[in]; {
+	case in == reflect.TypeFor[context.Context]():
+		return injectSource{node: -1, value: func() reflect.Value { return reflect.ValueOf(ctx) }}, true
+
+	case ok:
+		return source, true
+
+	case in.Kind() == reflect.Pointer && isOptionsType(in.Elem()):
+		// set inputValue to (*T)(nil)
+		return injectSource{node: -1, value: func() reflect.Value { return reflect.New(in).Elem() }}, true
+
+	default:
+		return injectSource{}, false
+	}
+}
+
+// isOptionsType returns true for structs that can be a field of the options, that is
+// structs with an Initialize method or with fields that are command line options.
+func isOptionsType(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Struct {
+		return false
+	}
+
+	if _, ok := reflect.PointerTo(typ).MethodByName("Initialize"); ok {
+		return true
+	}
+
+	for field := range typ.Fields() {
+		for _, tag := range []string{"long", "group", "namespace"} {
+			if _, ok := field.Tag.Lookup(tag); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// initializeOrder sorts the nodes topologically. Nodes without dependencies
+// between each other keep their field order.
+func initializeOrder(nodes []*injectNode) ([]int, error) {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+
+	state := make([]int, len(nodes))
+	order := make([]int, 0, len(nodes))
+
+	var visit func(idx int, path []string) error
+	visit = func(idx int, path []string) error {
+		path = append(path, nodes[idx].value.Type().String())
+
+		switch state[idx] {
+		case visited:
+			return nil
+		case visiting:
+			return errors.New("dependency cycle between initializers: " + strings.Join(path, " -> "))
+		}
+
+		state[idx] = visiting
+
+		for _, dependency := range nodes[idx].dependencies {
+			if err := visit(dependency, path); err != nil {
+				return err
+			}
+		}
+
+		state[idx] = visited
+		order = append(order, idx)
+
+		return nil
+	}
+
+	for idx := range nodes {
+		if err := visit(idx, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
@@ -1,7 +1,8 @@
 // Package actor carries the typed principal that caused a request or an event.
 //
 // It is a leaf package on purpose: lib/history already imports lib/events, so
-// a type shared by both cannot live in either. It imports nothing but context.
+// a type shared by both cannot live in either. It imports nothing but the
+// standard library.
 //
 // DEV-NOTE: see BauerMediaGroup-Stardust/platform-gitops
 // docs/plans/keycloak-service-auth.md section 8. The actor is audit
@@ -9,7 +10,10 @@
 // decide anything from it.
 package actor
 
-import "context"
+import (
+	"context"
+	"sync/atomic"
+)
 
 // Type is the kind of principal an Actor describes. It is typed rather than a
 // bare id so "everything this staff member did" is answerable without guessing
@@ -53,8 +57,37 @@ func (a Actor) Zero() bool {
 
 type contextKey struct{}
 
+type recorderKey struct{}
+
+// Recorder remembers the actor most recently attached to a context derived
+// from the context returned by WithRecorder. This lets an outer middleware, like
+// an access log, see the actor an inner handler established.
+type Recorder struct {
+	actor atomic.Pointer[Actor]
+}
+
+// Actor returns the recorded actor, if any.
+func (r *Recorder) Actor() (Actor, bool) {
+	a := r.actor.Load()
+	if a == nil || a.Zero() {
+		return Actor{}, false
+	}
+
+	return *a, true
+}
+
+// WithRecorder returns a context with a Recorder that observes calls to WithActor.
+func WithRecorder(ctx context.Context) (context.Context, *Recorder) {
+	recorder := &Recorder{}
+	return context.WithValue(ctx, recorderKey{}, recorder), recorder
+}
+
 // WithActor returns a context carrying the actor.
 func WithActor(ctx context.Context, a Actor) context.Context {
+	if recorder, ok := ctx.Value(recorderKey{}).(*Recorder); ok {
+		recorder.actor.Store(&a)
+	}
+
 	return context.WithValue(ctx, contextKey{}, a)
 }
 
@@ -34,3 +34,17 @@ func TestActor_InnerActorWins(t *testing.T) {
 	require.True(t, ok)
 	require.Equal(t, Actor{Type: TypeUser, Id: "sub-1"}, got)
 }
+
+func TestActor_RecorderSeesActorOfDerivedContext(t *testing.T) {
+	ctx, recorder := WithRecorder(t.Context())
+
+	_, ok := recorder.Actor()
+	require.False(t, ok)
+
+	// an inner handler attaches the actor to a context the caller never sees
+	_ = WithActor(ctx, Actor{Type: TypePlayer, Id: "player-1"})
+
+	got, ok := recorder.Actor()
+	require.True(t, ok)
+	require.Equal(t, Actor{Type: TypePlayer, Id: "player-1"}, got)
+}
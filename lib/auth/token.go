@@ -48,7 +48,7 @@ var ErrNoToken = errors.New("keycloak returned no access token")
 type TokenSource struct {
 	tokenURL     string
 	clientID     string
-	clientSecret string
+	clientSecret func() string
 	httpClient   *http.Client
 
 	// now is injectable for tests; defaults to clock.GlobalClock.Now.
@@ -87,6 +87,11 @@ type Options struct {
 	ClientID     string
 	ClientSecret string
 
+	// ClientSecretFunc is called for every token request and takes precedence over
+	// ClientSecret. Use it to pick up a rotated secret without a restart, e.g. with
+	// startup_base.ReloadableSecret.
+	ClientSecretFunc func() string
+
 	// HTTPClient is used for token requests. Defaults to a client with a 5s
 	// timeout.
 	HTTPClient *http.Client
@@ -108,10 +113,15 @@ func NewTokenSource(ctx context.Context, opts Options) *TokenSource {
 		now = clock.GlobalClock.Now
 	}
 
+	clientSecret := opts.ClientSecretFunc
+	if clientSecret == nil {
+		clientSecret = func() string { return opts.ClientSecret }
+	}
+
 	return &TokenSource{
 		tokenURL:     opts.TokenURL,
 		clientID:     opts.ClientID,
-		clientSecret: opts.ClientSecret,
+		clientSecret: clientSecret,
 		httpClient:   httpClient,
 		now:          now,
 		baseContext:  ctx,
@@ -217,7 +227,7 @@ func (t *TokenSource) request(ctx context.Context, audience string) (string, tim
 	form := url.Values{
 		"grant_type":    {"client_credentials"},
 		"client_id":     {t.clientID},
-		"client_secret": {t.clientSecret},
+		"client_secret": {t.clientSecret()},
 		"scope":         {audience},
 	}
 
@@ -307,6 +307,35 @@ func TestTokenSource_ErrorNeverLeaksTheClientSecret(t *testing.T) {
 	require.NotContains(t, err.Error(), fakeSecret)
 }
 
+func TestTokenSource_UsesRotatedClientSecret(t *testing.T) {
+	ts := newTokenServer(t)
+
+	var secret atomic.Pointer[string]
+	secret.Store(&fakeSecret)
+
+	clk := &fakeClock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	src := NewTokenSource(t.Context(), Options{
+		TokenURL:         ts.url,
+		ClientID:         "order-service",
+		ClientSecretFunc: func() string { return *secret.Load() },
+		Now:              clk.Now,
+	})
+
+	_, err := src.Token(t.Context(), "payment-service")
+	require.NoError(t, err)
+	require.Equal(t, fakeSecret, ts.form()["client_secret"])
+
+	rotated := "rotated-" + fakeSecret
+	secret.Store(&rotated)
+
+	// the next fetch after expiry uses the rotated secret
+	clk.advance(10 * time.Minute)
+
+	_, err = src.Token(t.Context(), "payment-service")
+	require.NoError(t, err)
+	require.Equal(t, rotated, ts.form()["client_secret"])
+}
+
 func TestTransport_AddsBearerWithoutMutatingTheRequest(t *testing.T) {
 	ts := newTokenServer(t)
 	src, _ := newSource(t, ts)
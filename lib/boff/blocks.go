@@ -254,9 +254,14 @@ func (b ActionsBlock) Render(rc RenderContext) (template.HTML, error) {
 // PagerModel is the pagination state a PagerBlock renders. Both pagers (above
 // and below the table) render the same model. The Link fields are empty when
 // there is no such page.
+//
+// Cursor switches to cursor mode for keyset pagination: the links carry opaque
+// cursors instead of page numbers, so the pager shows neither the page number
+// nor a jump to the last page.
 type PagerModel struct {
 	Page       int
 	TotalPages int
+	Cursor     bool
 	FirstLink  string
 	PrevLink   string
 	NextLink   string
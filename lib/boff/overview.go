@@ -57,6 +57,13 @@ type OverviewConfig struct {
 	// whole result set is not worth a second query; the pager then only walks.
 	TotalPages int
 
+	// NextCursor and PrevCursor switch the pager to cursor mode for keyset
+	// pagination, e.g. the cursors of a ql.Page. The pager then links with
+	// CursorParam instead of page numbers and Page, HasNext and TotalPages are
+	// ignored. An empty cursor means there is no such page.
+	NextCursor string
+	PrevCursor string
+
 	// Blocks overrides the sections rendered on the page. When nil the page uses
 	// its default layout: a HeaderBlock, ScopeNoteBlock, a PagerBlock, a
 	// FilterableTableBlock (filters and table in one card) and a trailing
@@ -88,9 +95,26 @@ func (d DefaultOverviewBlocks) All() []Block {
 // PageParam is the query parameter the overview pager pages with.
 const PageParam = "page"
 
+// CursorParam is the query parameter the overview pager pages with in cursor mode.
+const CursorParam = "cursor"
+
 // pageLink builds the URL of another page of the same filtered list. Filters are
 // carried over, so paging never silently widens the list.
 func pageLink(filters []OverviewFilter, page int) string {
+	var value string
+	if page > 1 {
+		value = strconv.Itoa(page)
+	}
+
+	return listLink(filters, PageParam, value)
+}
+
+// cursorLink is pageLink for cursor mode. An empty cursor links to the first page.
+func cursorLink(filters []OverviewFilter, cursor string) string {
+	return listLink(filters, CursorParam, cursor)
+}
+
+func listLink(filters []OverviewFilter, param, value string) string {
 	query := url.Values{}
 	for _, f := range filters {
 		if f.Value != "" {
@@ -98,8 +122,8 @@ func pageLink(filters []OverviewFilter, page int) string {
 		}
 	}
 
-	if page > 1 {
-		query.Set(PageParam, strconv.Itoa(page))
+	if value != "" {
+		query.Set(param, value)
 	}
 
 	if len(query) == 0 {
@@ -156,6 +180,41 @@ func RenderOverview(w io.Writer, title string, headers []string, rows []Overview
 // RenderOverviewWithConfig is RenderOverview with the optional display elements
 // (filter form, scope note).
 func RenderOverviewWithConfig(w io.Writer, cfg OverviewConfig) error {
+	pager := overviewPager(cfg)
+
+	defaults := DefaultOverviewBlocks{
+		Header:    HeaderBlock{Title: cfg.Title},
+		Filters:   FiltersBlock(cfg.Filters),
+		ScopeNote: ScopeNoteBlock(cfg.ScopeNote),
+		Pager:     PagerBlock(pager),
+		Table:     FilterableTableBlock(cfg.Filters, cfg.Headers, cfg.Rows),
+	}
+
+	blocks := defaults.All()
+	if cfg.Blocks != nil {
+		blocks = cfg.Blocks(defaults)
+	}
+
+	return Render(w, RenderConfig{Title: cfg.Title, Blocks: blocks})
+}
+
+// overviewPager builds the pager of an overview, in cursor mode if a cursor is set.
+func overviewPager(cfg OverviewConfig) PagerModel {
+	if cfg.NextCursor != "" || cfg.PrevCursor != "" {
+		pager := PagerModel{Cursor: true}
+
+		if cfg.PrevCursor != "" {
+			pager.FirstLink = cursorLink(cfg.Filters, "")
+			pager.PrevLink = cursorLink(cfg.Filters, cfg.PrevCursor)
+		}
+
+		if cfg.NextCursor != "" {
+			pager.NextLink = cursorLink(cfg.Filters, cfg.NextCursor)
+		}
+
+		return pager
+	}
+
 	page := max(cfg.Page, 1)
 
 	pager := PagerModel{Page: page, TotalPages: cfg.TotalPages}
@@ -175,18 +234,5 @@ func RenderOverviewWithConfig(w io.Writer, cfg OverviewConfig) error {
 		pager.LastLink = pageLink(cfg.Filters, cfg.TotalPages)
 	}
 
-	defaults := DefaultOverviewBlocks{
-		Header:    HeaderBlock{Title: cfg.Title},
-		Filters:   FiltersBlock(cfg.Filters),
-		ScopeNote: ScopeNoteBlock(cfg.ScopeNote),
-		Pager:     PagerBlock(pager),
-		Table:     FilterableTableBlock(cfg.Filters, cfg.Headers, cfg.Rows),
-	}
-
-	blocks := defaults.All()
-	if cfg.Blocks != nil {
-		blocks = cfg.Blocks(defaults)
-	}
-
-	return Render(w, RenderConfig{Title: cfg.Title, Blocks: blocks})
+	return pager
 }
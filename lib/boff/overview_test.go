@@ -244,3 +244,41 @@ func TestRenderOverviewPagerJumpsToFirstAndLast(t *testing.T) {
 		t.Errorf("last-page jump offered without a total:\n%s", unknown)
 	}
 }
+
+// In cursor mode the pager links with the opaque cursors of a keyset paginated
+// query and has no page position to show.
+func TestRenderOverviewPagerFollowsCursors(t *testing.T) {
+	filters := []OverviewFilter{{Name: "status", Value: "PAID"}}
+
+	var buf bytes.Buffer
+	err := RenderOverviewWithConfig(&buf, OverviewConfig{
+		Title: "t", Headers: []string{"ID"}, Filters: filters, Page: 4, TotalPages: 9,
+		PrevCursor: "prev-cursor", NextCursor: "next-cursor",
+	})
+	if err != nil {
+		t.Fatalf("render overview: %v", err)
+	}
+	out := buf.String()
+
+	if !strings.Contains(out, `href="?cursor=prev-cursor&amp;status=PAID"`) {
+		t.Errorf("previous link lost its cursor or filters:\n%s", out)
+	}
+	if !strings.Contains(out, `href="?cursor=next-cursor&amp;status=PAID"`) {
+		t.Errorf("next link lost its cursor or filters:\n%s", out)
+	}
+	if !strings.Contains(out, `href="?status=PAID"`) {
+		t.Errorf("first-page jump missing:\n%s", out)
+	}
+	if strings.Contains(out, "Page 4") || strings.Contains(out, "Last") || strings.Contains(out, "page=") {
+		t.Errorf("cursor pager shows page numbers:\n%s", out)
+	}
+
+	// The first page only has a next cursor.
+	var first bytes.Buffer
+	if err := RenderOverviewWithConfig(&first, OverviewConfig{Title: "t", Headers: []string{"ID"}, NextCursor: "next-cursor"}); err != nil {
+		t.Fatalf("render overview: %v", err)
+	}
+	if strings.Contains(first.String(), `href="?"`) || !strings.Contains(first.String(), `href="?cursor=next-cursor"`) {
+		t.Errorf("first cursor page links backwards:\n%s", first.String())
+	}
+}
@@ -113,10 +113,11 @@ func TestWriteToOutbox_PersistsActorHeaders(t *testing.T) {
 	execer := &capturingExecer{}
 	require.NoError(t, WriteToOutbox(ctx, execer, *meta, "bo_outbox", []byte("payload")))
 
-	// stmt args are topic, key, value, header keys, header values
-	require.Len(t, execer.args, 5)
+	// stmt args are topic, key, value, header keys, header values, channel
+	require.Len(t, execer.args, 6)
 	assert.Equal(t, []string{HeaderActorType, HeaderActorId, HeaderActorLabel}, execer.args[3])
 	assert.Equal(t, []string{"user", "sub-1", "a@b.c"}, execer.args[4])
+	assert.Equal(t, DefaultOutboxChannel, execer.args[5])
 }
 
 func kafkaHeader(key, value string) kafka.Header {
@@ -7,24 +7,23 @@ import (
 	"log/slog"
 	"time"
 
+	"github.com/flachnetz/startup/v2/lib/pg"
 	"github.com/jmoiron/sqlx"
 )
 
-func WriteToOutbox(ctx context.Context, tx sqlx.ExecerContext, metadata EventMetadata, table string, payload []byte) error {
-	topic := metadata.Topic
-	key := metadata.Key
+// DefaultOutboxChannel is the channel the outburst relay listens on by default.
+const DefaultOutboxChannel = "kafka-message"
 
-	if key == nil {
-		key = new(fmt.Sprintf("%d", time.Now().UnixMilli()))
-	}
+// WriteToOutbox writes the event into the outbox table and notifies the outburst relay,
+// which publishes it right after the transaction commits.
+func WriteToOutbox(ctx context.Context, tx sqlx.ExecerContext, metadata EventMetadata, table string, payload []byte) error {
+	return WriteToOutboxChannel(ctx, tx, metadata, table, DefaultOutboxChannel, payload)
+}
 
-	headerKeys := make([]string, 0, len(metadata.Headers))
-	headerValues := make([]string, 0, len(metadata.Headers))
-
-	for _, header := range metadata.Headers {
-		headerKeys = append(headerKeys, header.Key)
-		headerValues = append(headerValues, header.Value)
-	}
+// WriteToOutboxChannel is WriteToOutbox for an outbox whose relay listens on another
+// channel than DefaultOutboxChannel, e.g. when running several outboxes.
+func WriteToOutboxChannel(ctx context.Context, tx sqlx.ExecerContext, metadata EventMetadata, table, channel string, payload []byte) error {
+	topic, key, headerKeys, headerValues := outboxColumns(metadata)
 
 	// insert event into database and notify listeners
 	stmt := fmt.Sprintf(`
@@ -34,16 +33,71 @@ func WriteToOutbox(ctx context.Context, tx sqlx.ExecerContext, metadata EventMet
 				VALUES ($1, $2, $3, $4, $5)
 				RETURNING id, kafka_key)
 
-		SELECT pg_notify('kafka-message', json_build_object('id', id, 'key', kafka_key)::text)
+		SELECT pg_notify($6, json_build_object('id', id, 'key', kafka_key)::text)
 		FROM ids;
 	`, table)
-	_, err := tx.ExecContext(ctx, stmt, topic, key, payload, headerKeys, headerValues)
+	_, err := tx.ExecContext(ctx, stmt, topic, key, payload, headerKeys, headerValues, channel)
 	if err != nil {
 		return fmt.Errorf("write event into database: %w", err)
 	}
 	return nil
 }
 
+// WriteToOutboxAt writes the event into the outbox table to be published not before the
+// given time, e.g. for reminders and timeouts. The time is compared with clock.GlobalClock,
+// so a time jump applies to scheduled events too. The outburst sweeper picks the event up
+// on its first pass after that time, which might be a few seconds later.
+//
+// Use a key in the metadata to cancel the event again with CancelScheduled.
+func WriteToOutboxAt(ctx context.Context, tx sqlx.ExecerContext, notBefore time.Time, metadata EventMetadata, table string, payload []byte) error {
+	topic, key, headerKeys, headerValues := outboxColumns(metadata)
+
+	// no notification, the notify path would only skip the row anyway
+	stmt := fmt.Sprintf(`
+		INSERT INTO %s (kafka_topic, kafka_key, kafka_value, kafka_header_keys, kafka_header_values, not_before)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, table)
+	_, err := tx.ExecContext(ctx, stmt, topic, key, payload, headerKeys, headerValues, notBefore)
+	if err != nil {
+		return fmt.Errorf("write scheduled event into database: %w", err)
+	}
+	return nil
+}
+
+// CancelScheduled deletes all events with the given key from the outbox table that were
+// written with WriteToOutboxAt and are not yet published. Returns the number of
+// cancelled events.
+func CancelScheduled(ctx context.Context, tx sqlx.ExecerContext, table string, key string) (int64, error) {
+	stmt := fmt.Sprintf(`DELETE FROM %s WHERE kafka_key = $1 AND not_before IS NOT NULL`, table)
+
+	res, err := tx.ExecContext(ctx, stmt, key)
+	if err != nil {
+		return 0, fmt.Errorf("cancel scheduled events: %w", err)
+	}
+
+	return res.RowsAffected()
+}
+
+func outboxColumns(metadata EventMetadata) (topic string, key *string, headerKeys, headerValues []string) {
+	key = metadata.Key
+	if key == nil {
+		key = new(fmt.Sprintf("%d", time.Now().UnixMilli()))
+	}
+
+	headerKeys = make([]string, 0, len(metadata.Headers))
+	headerValues = make([]string, 0, len(metadata.Headers))
+
+	for _, header := range metadata.Headers {
+		headerKeys = append(headerKeys, header.Key)
+		headerValues = append(headerValues, header.Value)
+	}
+
+	return metadata.Topic, key, headerKeys, headerValues
+}
+
+// CreateOutbox creates the kafka_outbox table with the columns the outburst relay
+// needs for attempt tracking and scheduled delivery, so WriteToOutboxAt and
+// CancelScheduled work before a relay ran.
 func CreateOutbox(ctx context.Context, db *sql.DB) error {
 	tx, err := db.BeginTx(ctx, &sql.TxOptions{})
 	if err != nil {
@@ -60,21 +114,52 @@ func CreateOutbox(ctx context.Context, db *sql.DB) error {
 			kafka_key           text NOT NULL,
 			kafka_value         BYTEA NOT NULL,
 			kafka_header_keys   text[] NOT NULL,
-			kafka_header_values text[] NOT NULL
+			kafka_header_values text[] NOT NULL,
+
+			attempts            integer NOT NULL DEFAULT 0,
+			last_error          text NULL,
+			not_before          timestamp with time zone NULL
 		)
 	`
 
-	if _, err := tx.ExecContext(ctx, createTable); err != nil {
+	rollback := func(err error) error {
 		if err := tx.Rollback(); err != nil {
 			slog.WarnContext(ctx, "Failed to rollback create outbox table transaction", slog.String("error", err.Error()))
 		}
 
-		return fmt.Errorf("create table: %w", err)
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, createTable); err != nil {
+		return rollback(fmt.Errorf("create table: %w", err))
+	}
+
+	// tables created by older versions lack the attempt tracking and scheduling.
+	// ALTER TABLE locks the table exclusively, even if the columns exist.
+	missing, err := pg.MissingColumns(ctx, tx, "public.kafka_outbox", "attempts", "last_error", "not_before")
+	if err != nil {
+		return rollback(err)
+	}
+
+	if len(missing) > 0 {
+		addColumns := `
+			ALTER TABLE PUBLIC.kafka_outbox
+				ADD COLUMN IF NOT EXISTS attempts   integer NOT NULL DEFAULT 0,
+				ADD COLUMN IF NOT EXISTS last_error text NULL,
+				ADD COLUMN IF NOT EXISTS not_before timestamp with time zone NULL
+		`
+
+		if _, err := tx.ExecContext(ctx, addColumns); err != nil {
+			return rollback(fmt.Errorf("add columns: %w", err))
+		}
 	}
 
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("commit: %w", err)
 	}
 
-	return nil
+	// scheduled rows are cancelled by their key. The index is built concurrently
+	// outside the transaction, so writers of the outbox are not blocked.
+	return pg.CreateIndexConcurrently(ctx, db, "public.kafka_outbox", "kafka_outbox_scheduled_key",
+		"(kafka_key) WHERE not_before IS NOT NULL")
 }
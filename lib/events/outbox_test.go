@@ -0,0 +1,65 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingExecer records the statement and arguments passed to the database.
+type recordingExecer struct {
+	stmt string
+	args []any
+}
+
+func (e *recordingExecer) ExecContext(_ context.Context, stmt string, args ...any) (sql.Result, error) {
+	e.stmt, e.args = stmt, args
+	return driver.RowsAffected(2), nil
+}
+
+func TestWriteToOutboxAt(t *testing.T) {
+	notBefore := time.Date(2026, 5, 1, 12, 15, 0, 0, time.UTC)
+
+	meta := EventMetadata{Topic: "reminders", Key: new("order-1"), Headers: []EventHeader{{Key: "h", Value: "v"}}}
+
+	execer := &recordingExecer{}
+	require.NoError(t, WriteToOutboxAt(t.Context(), execer, notBefore, meta, "outbox", []byte("payload")))
+
+	// scheduled rows must not be forwarded right away by a notification
+	assert.NotContains(t, execer.stmt, "pg_notify")
+	assert.Contains(t, execer.stmt, "not_before")
+
+	require.Len(t, execer.args, 6)
+	assert.Equal(t, "reminders", execer.args[0])
+	assert.Equal(t, new("order-1"), execer.args[1])
+	assert.Equal(t, []string{"h"}, execer.args[3])
+	assert.Equal(t, []string{"v"}, execer.args[4])
+	assert.Equal(t, notBefore, execer.args[5])
+}
+
+func TestCancelScheduled(t *testing.T) {
+	execer := &recordingExecer{}
+
+	cancelled, err := CancelScheduled(t.Context(), execer, "outbox", "order-1")
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(2), cancelled)
+	assert.Equal(t, []any{"order-1"}, execer.args)
+	assert.Contains(t, execer.stmt, "not_before IS NOT NULL")
+}
+
+func TestWriteToOutboxChannel(t *testing.T) {
+	execer := &recordingExecer{}
+
+	meta := EventMetadata{Topic: "bulk", Key: new("order-1")}
+	require.NoError(t, WriteToOutboxChannel(t.Context(), execer, meta, "bulk_outbox", "bulk-message", []byte("payload")))
+
+	assert.Contains(t, execer.stmt, "INSERT INTO bulk_outbox")
+	require.Len(t, execer.args, 6)
+	assert.Equal(t, "bulk-message", execer.args[5])
+}
@@ -0,0 +1,393 @@
+package outburst
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flachnetz/go-admin"
+	"github.com/flachnetz/startup/v2/lib/boff"
+	"github.com/flachnetz/startup/v2/lib/clock"
+	sl "github.com/flachnetz/startup/v2/startup_logging"
+)
+
+//go:embed templates/admin.gohtml
+var adminTemplateFS embed.FS
+
+var adminTemplate = boff.MustTemplatesFromFS(adminTemplateFS)
+
+// adminQuarantineLimit is the number of quarantined rows shown per relay.
+const adminQuarantineLimit = 50
+
+// AdminHandlers returns the outburst admin page, to be mounted with the
+// AdminHandlers of startup_http:
+//
+//	httpOpts.Serve(startup_http.Config{
+//		AdminHandlers: outburst.AdminHandlers(boff.RoleAdmin),
+//	})
+//
+// /admin/outburst shows the backlog, worker queues, recent errors and quarantined
+// rows of every relay in this process, /admin/outburst/status serves the same as
+// JSON. The actions to pause and resume a relay, trigger a sweep and requeue
+// quarantined rows require actionRole in the notation of boff.Role, checked
+// against the jwt.Identity of the request. startup_http provides the basic auth
+// admin user as identity with the admin role. Mounted elsewhere, a middleware like
+// jwt.KeycloakRoleMiddleware must provide the identity, otherwise every action is
+// forbidden. An empty actionRole leaves the actions to the authentication of the
+// admin page alone.
+//
+// Actions posted by a browser from another origin are rejected, see
+// http.CrossOriginProtection.
+func AdminHandlers(actionRole boff.Role) []admin.RouteConfig {
+	h := adminHandler{actionRole: actionRole}
+
+	return []admin.RouteConfig{
+		admin.Describe("Outbox relays with backlog, worker queues, recent errors and quarantined rows.",
+			admin.WithGetHandlerFunc("outburst", h.page)),
+
+		admin.Describe("Outbox relays as JSON.",
+			admin.WithGetHandlerFunc("outburst/status", h.status)),
+
+		admin.Describe("Pause the outbox relay given as 'outbox' by posting to this endpoint.",
+			admin.WithHandlerFunc("POST", "outburst/pause", h.action(func(r *http.Request, relay *Relay) (adminActionResult, error) {
+				relay.Pause()
+				return adminActionResult{}, nil
+			}))),
+
+		admin.Describe("Resume the outbox relay given as 'outbox' by posting to this endpoint.",
+			admin.WithHandlerFunc("POST", "outburst/resume", h.action(func(r *http.Request, relay *Relay) (adminActionResult, error) {
+				relay.Resume()
+				return adminActionResult{}, nil
+			}))),
+
+		admin.Describe("Sweep the outbox of the relay given as 'outbox' by posting to this endpoint.",
+			admin.WithHandlerFunc("POST", "outburst/sweep", h.action(func(r *http.Request, relay *Relay) (adminActionResult, error) {
+				if relay.Paused() {
+					return adminActionResult{}, adminError{http.StatusConflict, "relay is paused"}
+				}
+
+				relay.TriggerSweep()
+				return adminActionResult{}, nil
+			}))),
+
+		admin.Describe("Requeue the quarantined rows given as 'id' of the relay given as 'outbox' by posting to this endpoint.",
+			admin.WithHandlerFunc("POST", "outburst/requeue", h.action(func(r *http.Request, relay *Relay) (adminActionResult, error) {
+				ids, err := parseRowIDs(r.Form["id"])
+				if err != nil {
+					return adminActionResult{}, adminError{http.StatusBadRequest, err.Error()}
+				}
+
+				requeued, err := relay.Requeue(r.Context(), ids...)
+				return adminActionResult{Requeued: requeued}, err
+			}))),
+	}
+}
+
+type adminHandler struct {
+	actionRole boff.Role
+}
+
+// adminCrossOrigin rejects actions posted from other origins, as the basic auth
+// credentials of the admin page are sent along by the browser.
+var adminCrossOrigin = http.NewCrossOriginProtection()
+
+// adminActionResult is the JSON response of an action.
+type adminActionResult struct {
+	Outbox   string `json:"outbox"`
+	Paused   bool   `json:"paused"`
+	Requeued int    `json:"requeued"`
+}
+
+// adminError is an action failure with the status to respond with.
+type adminError struct {
+	Status  int
+	Message string
+}
+
+func (e adminError) Error() string {
+	return e.Message
+}
+
+func (h adminHandler) page(w http.ResponseWriter, r *http.Request) {
+	stats, err := allRelayStats(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	subtitle := fmt.Sprintf("%d relays in this process", len(stats))
+	if len(stats) == 0 {
+		subtitle = "No relay is running in this process"
+	}
+
+	blocks := []boff.Block{boff.HeaderBlock{Title: "Outburst", Subtitle: subtitle}}
+	for _, relay := range stats {
+		blocks = append(blocks, h.relayBlocks(relay)...)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	err = boff.Render(w, boff.RenderConfig{
+		Title:  "Outburst",
+		Viewer: boff.ViewerOf(r.Context(), nil),
+		Blocks: blocks,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to render outburst admin page", sl.Error(err))
+	}
+}
+
+func (h adminHandler) status(w http.ResponseWriter, r *http.Request) {
+	stats, err := allRelayStats(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeAdminJSON(w, stats)
+}
+
+// action wraps an action on the relay given as "outbox". A form posted from the
+// admin page is redirected back to the page, any other client gets the result as
+// JSON.
+func (h adminHandler) action(fn func(r *http.Request, relay *Relay) (adminActionResult, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := adminCrossOrigin.Check(r); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		if !boff.MayPerform(boff.ViewerOf(r.Context(), nil), h.actionRole) {
+			http.Error(w, "not allowed to control outburst relays", http.StatusForbidden)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		relay := relayByName(r.Form.Get("outbox"))
+		if relay == nil {
+			http.Error(w, fmt.Sprintf("unknown outbox %q", r.Form.Get("outbox")), http.StatusNotFound)
+			return
+		}
+
+		result, err := fn(r, relay)
+		if err != nil {
+			var adminErr adminError
+			if errors.As(err, &adminErr) {
+				http.Error(w, adminErr.Message, adminErr.Status)
+				return
+			}
+
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(r.Context(), "Outburst admin action",
+			slog.String("action", r.URL.Path), slog.String("outbox", relay.Name()))
+
+		result.Outbox = relay.Name()
+		result.Paused = relay.Paused()
+
+		if strings.Contains(r.Header.Get("Accept"), "text/html") {
+			// relative, as the admin handler strips its prefix from the request path
+			w.Header().Set("Location", "../outburst")
+			w.WriteHeader(http.StatusSeeOther)
+			return
+		}
+
+		writeAdminJSON(w, result)
+	}
+}
+
+func allRelayStats(r *http.Request) ([]RelayStats, error) {
+	stats := []RelayStats{}
+
+	for _, relay := range Relays() {
+		relayStats, err := relay.Stats(r.Context(), adminQuarantineLimit)
+		if err != nil {
+			return nil, fmt.Errorf("read stats of relay %q: %w", relay.Name(), err)
+		}
+
+		stats = append(stats, relayStats)
+	}
+
+	return stats, nil
+}
+
+func writeAdminJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func parseRowIDs(values []string) ([]int64, error) {
+	if len(values) == 0 {
+		return nil, fmt.Errorf("no row id given")
+	}
+
+	ids := make([]int64, 0, len(values))
+	for _, value := range values {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid row id %q", value)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// adminEndpoint is the url of an action, relative to the admin page.
+func adminEndpoint(action, outbox string, ids ...int64) string {
+	query := url.Values{"outbox": {outbox}}
+	for _, id := range ids {
+		query.Add("id", strconv.FormatInt(id, 10))
+	}
+
+	return "outburst/" + action + "?" + query.Encode()
+}
+
+// relayBlocks renders the state of one relay.
+func (h adminHandler) relayBlocks(stats RelayStats) []boff.Block {
+	now := clock.GlobalClock.Now()
+
+	var due, scheduled int64
+	for _, topic := range stats.Backlog {
+		due += topic.Due
+		scheduled += topic.Scheduled
+	}
+
+	state, tone := "running", "success"
+	switch {
+	case stats.Paused:
+		state, tone = "paused", "warning"
+	case !stats.Listening:
+		state, tone = "not listening", "danger"
+	}
+
+	queues := "-"
+	if len(stats.WorkerQueues) > 0 {
+		fills := make([]string, len(stats.WorkerQueues))
+		for i, queued := range stats.WorkerQueues {
+			fills[i] = fmt.Sprintf("%d/%d", queued, stats.WorkerQueueCapacity)
+		}
+
+		queues = strings.Join(fills, " ")
+	}
+
+	summary := []boff.SummaryItem{
+		{Label: "State", Value: state, Tone: tone},
+		{Label: "Table", Value: stats.Table},
+		{Label: "Channel", Value: stats.Channel},
+		{Label: "Due rows", Value: strconv.FormatInt(due, 10)},
+		{Label: "Scheduled rows", Value: strconv.FormatInt(scheduled, 10)},
+		{Label: "Oldest due row", Value: formatAge(now, stats.Oldest)},
+		{Label: "Worker queues", Value: queues},
+		{Label: "Quarantined rows", Value: strconv.FormatInt(stats.QuarantinedRows, 10)},
+	}
+
+	var actions []boff.Action
+	if stats.Paused {
+		actions = append(actions, boff.Action{
+			Description:  "Resume publishing and sweep the outbox",
+			ButtonText:   "Resume",
+			Endpoint:     adminEndpoint("resume", stats.Name),
+			RequiredRole: h.actionRole,
+		})
+	} else {
+		actions = append(actions, boff.Action{
+			Description:  "Pause publishing, all rows stay in the outbox",
+			ButtonText:   "Pause",
+			Endpoint:     adminEndpoint("pause", stats.Name),
+			RequiredRole: h.actionRole,
+		}, boff.Action{
+			Description:  "Sweep the outbox now",
+			ButtonText:   "Sweep",
+			Endpoint:     adminEndpoint("sweep", stats.Name),
+			RequiredRole: h.actionRole,
+		})
+	}
+
+	backlogRows := make([]boff.OverviewRow, 0, len(stats.Backlog))
+	for _, topic := range stats.Backlog {
+		backlogRows = append(backlogRows, boff.OverviewRow{Cells: []string{
+			topic.Topic,
+			strconv.FormatInt(topic.Due, 10),
+			strconv.FormatInt(topic.Scheduled, 10),
+			formatAge(now, topic.Oldest),
+		}})
+	}
+
+	errorRows := make([]boff.OverviewRow, 0, len(stats.RecentErrors))
+	for _, relayErr := range stats.RecentErrors {
+		row := "-"
+		if relayErr.RowID != 0 {
+			row = strconv.FormatInt(relayErr.RowID, 10)
+		}
+
+		errorRows = append(errorRows, boff.OverviewRow{Cells: []string{
+			relayErr.Time.Format(adminTimeFormat), row, relayErr.Error,
+		}})
+	}
+
+	quarantinedRows := make([]boff.OverviewRow, 0, len(stats.Quarantined))
+	quarantinedIDs := make([]int64, 0, len(stats.Quarantined))
+	for _, row := range stats.Quarantined {
+		quarantinedIDs = append(quarantinedIDs, row.ID)
+		quarantinedRows = append(quarantinedRows, boff.OverviewRow{Cells: []string{
+			strconv.FormatInt(row.ID, 10),
+			row.Topic,
+			row.Key,
+			strconv.Itoa(row.Attempts),
+			row.LastError,
+			row.QuarantineTime.Format(adminTimeFormat),
+		}})
+	}
+
+	if len(quarantinedIDs) > 0 {
+		actions = append(actions, boff.Action{
+			Description:    fmt.Sprintf("Requeue the %d quarantined rows shown below", len(quarantinedIDs)),
+			ButtonText:     "Requeue",
+			Endpoint:       adminEndpoint("requeue", stats.Name, quarantinedIDs...),
+			ConfirmMessage: fmt.Sprintf("Move %d rows back into %s?", len(quarantinedIDs), stats.Table),
+			ConfirmText:    "Requeue",
+			RequiredRole:   h.actionRole,
+		})
+	}
+
+	return []boff.Block{
+		boff.SummaryCard("Relay "+stats.Name, summary),
+		boff.ActionsBlock(actions),
+		adminHeading("Backlog per topic"),
+		boff.TableBlock([]string{"Topic", "Due", "Scheduled", "Oldest due row"}, backlogRows),
+		adminHeading("Recent errors"),
+		boff.TableBlock([]string{"Time", "Row", "Error"}, errorRows),
+		adminHeading("Quarantined rows"),
+		boff.TableBlock([]string{"Id", "Topic", "Key", "Attempts", "Last error", "Quarantined"}, quarantinedRows),
+	}
+}
+
+const adminTimeFormat = "2006-01-02 15:04:05.000"
+
+func adminHeading(title string) boff.Block {
+	return boff.TemplateBlock{Name: "outburst/heading", Model: title, Template: adminTemplate}
+}
+
+// formatAge formats the time passed since t, "-" if t is nil.
+func formatAge(now time.Time, t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+
+	return now.Sub(*t).Round(time.Second).String()
+}
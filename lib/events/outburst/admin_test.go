@@ -0,0 +1,182 @@
+package outburst
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flachnetz/go-admin"
+	"github.com/flachnetz/startup/v2/lib/boff"
+	"github.com/flachnetz/startup/v2/lib/jwt"
+)
+
+// registerTestRelay registers a relay without a database, enough for the actions
+// that do not touch the outbox.
+func registerTestRelay(t *testing.T, name string) *Relay {
+	relay := &Relay{db: outboxDB{Table: name + "_outbox", Name: name}, status: newRelayStatus()}
+
+	relays.Store(name, relay)
+	t.Cleanup(func() { relays.Delete(name) })
+
+	return relay
+}
+
+func postAdmin(t *testing.T, handler http.Handler, identity *jwt.Identity, accept, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	if identity != nil {
+		req = req.WithContext(jwt.WithIdentity(req.Context(), *identity))
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestAdminActionsRequireRole(t *testing.T) {
+	relay := registerTestRelay(t, "test-admin-role")
+
+	handler := admin.NewAdminHandler("/admin", "test", AdminHandlers(boff.RoleAdmin)...)
+
+	viewers := map[string]*jwt.Identity{
+		"anonymous": nil,
+		"reader":    {Subject: "staff-1", Roles: []string{jwt.RoleRead}},
+	}
+
+	for name, viewer := range viewers {
+		rec := postAdmin(t, handler, viewer, "", "/admin/outburst/pause?outbox=test-admin-role")
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("%s: expected status 403, got %d", name, rec.Code)
+		}
+	}
+
+	if relay.Paused() {
+		t.Fatalf("relay paused without permission")
+	}
+
+	operator := &jwt.Identity{Subject: "staff-2", Roles: []string{jwt.RoleAdmin}}
+
+	rec := postAdmin(t, handler, operator, "", "/admin/outburst/pause?outbox=test-admin-role")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	var result adminActionResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+
+	if !result.Paused || result.Outbox != "test-admin-role" || !relay.Paused() {
+		t.Fatalf("relay not paused: %+v", result)
+	}
+}
+
+func TestAdminActionsRejectCrossOrigin(t *testing.T) {
+	relay := registerTestRelay(t, "test-admin-origin")
+
+	handler := admin.NewAdminHandler("/admin", "test", AdminHandlers("")...)
+
+	operator := &jwt.Identity{Subject: "admin", Roles: []string{jwt.RoleAdmin}}
+
+	req := httptest.NewRequest(http.MethodPost, "http://service.local/admin/outburst/pause?outbox=test-admin-origin", nil)
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	req = req.WithContext(jwt.WithIdentity(req.Context(), *operator))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden || relay.Paused() {
+		t.Fatalf("cross-site action not rejected: %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "http://service.local/admin/outburst/pause?outbox=test-admin-origin", nil)
+	req.Header.Set("Origin", "http://service.local")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || !relay.Paused() {
+		t.Fatalf("same-origin action rejected: %d", rec.Code)
+	}
+}
+
+func TestAdminPauseResumeAndSweep(t *testing.T) {
+	relay := registerTestRelay(t, "test-admin-actions")
+
+	handler := admin.NewAdminHandler("/admin", "test", AdminHandlers("")...)
+
+	// a form posted from the page is redirected back to it
+	rec := postAdmin(t, handler, nil, "text/html,*/*", "/admin/outburst/pause?outbox=test-admin-actions")
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "../outburst" {
+		t.Fatalf("expected redirect to the page, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	if !relay.Paused() {
+		t.Fatalf("relay not paused")
+	}
+
+	rec = postAdmin(t, handler, nil, "", "/admin/outburst/sweep?outbox=test-admin-actions")
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected sweep of a paused relay to fail with 409, got %d", rec.Code)
+	}
+
+	rec = postAdmin(t, handler, nil, "", "/admin/outburst/resume?outbox=test-admin-actions")
+	if rec.Code != http.StatusOK || relay.Paused() {
+		t.Fatalf("relay not resumed: %d", rec.Code)
+	}
+
+	// resuming sweeps up the rows left in the outbox
+	if len(relay.status.sweepRequests) != 1 {
+		t.Fatalf("no sweep requested on resume")
+	}
+
+	// a pending sweep is not requested twice
+	rec = postAdmin(t, handler, nil, "", "/admin/outburst/sweep?outbox=test-admin-actions")
+	if rec.Code != http.StatusOK || len(relay.status.sweepRequests) != 1 {
+		t.Fatalf("unexpected sweep result: %d, %d pending", rec.Code, len(relay.status.sweepRequests))
+	}
+
+	rec = postAdmin(t, handler, nil, "", "/admin/outburst/pause?outbox=unknown")
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 for an unknown outbox, got %d", rec.Code)
+	}
+
+	rec = postAdmin(t, handler, nil, "", "/admin/outburst/requeue?outbox=test-admin-actions&id=x")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for an invalid row id, got %d", rec.Code)
+	}
+}
+
+func TestRecentErrorsKeepsLatest(t *testing.T) {
+	var recent recentErrors
+
+	for id := int64(1); id <= maxRecentErrors+5; id++ {
+		recent.add(id, errors.New("failed"))
+	}
+
+	recent.add(0, &rowError{ID: 99, Err: errors.New("too large")})
+	recent.add(0, errors.New("connection refused"))
+
+	list := recent.list()
+	if len(list) != maxRecentErrors {
+		t.Fatalf("expected %d errors, got %d", maxRecentErrors, len(list))
+	}
+
+	if list[0].RowID != 0 || list[0].Error != "connection refused" {
+		t.Fatalf("latest error not first: %+v", list[0])
+	}
+
+	if list[1].RowID != 99 {
+		t.Fatalf("row of a row error not recorded: %+v", list[1])
+	}
+
+	if list[len(list)-1].RowID != 8 {
+		t.Fatalf("oldest errors not dropped: %+v", list[len(list)-1])
+	}
+}
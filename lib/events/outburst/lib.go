@@ -39,6 +39,17 @@ type Options struct {
 	// Name of the outbox table to drain. Created on startup when it is missing.
 	OutboxTable string
 
+	// Name of the relay, to run several relays in one process. It labels the
+	// metrics (label "outbox") and names the health check. Defaults to the
+	// OutboxTable.
+	Name string
+
+	// Channel the insert trigger notifies on, see events.WriteToOutboxChannel.
+	// Defaults to "kafka-message". Relays of different outbox tables need
+	// different channels, a relay would otherwise look up foreign row ids in
+	// its own table.
+	Channel string
+
 	// Scheduler that the periodic jobs attach to. When nil, outburst builds and
 	// owns its own scheduler.
 	Cron gocron.Scheduler
@@ -50,9 +61,12 @@ type Options struct {
 	//
 	// Every row is dispatched to shard hash(kafka_key)%WorkerCount and each
 	// shard publishes strictly in sequence. Two rows sharing a kafka_key thus
-	// reach Kafka in insertion order — and land on the same partition in that
-	// order — no matter how many workers run. Rows without a key carry no
-	// ordering guarantee and all fall to shard 0.
+	// reach Kafka in the order of their notifications — and land on the same
+	// partition in that order — no matter how many workers run. Rows without a
+	// key carry no ordering guarantee and all fall to shard 0.
+	//
+	// A lost notification leaves its row to the sweeper, which might publish it
+	// after newer rows of its key. Use StrictOrdering if that matters.
 	WorkerCount uint
 
 	// Capacity of each shard's hand-off channel on the NOTIFY path. Defaults to
@@ -61,6 +75,24 @@ type Options struct {
 	// it to bound the memory held in flight.
 	WorkerQueueBuffer uint
 
+	// Publish the rows of a key strictly in the order of their ids, across lost
+	// or reordered notifications, the sweeper and other instances. A row is only
+	// published after all older due rows of its key, and a failing row blocks the
+	// newer rows of its key until it is quarantined. Rows scheduled for later
+	// delivery are not ordered. Requires an idempotent producer, see
+	// "enable.idempotence", so retries can not reorder the messages of a batch.
+	StrictOrdering bool
+
+	// Outbox size above which the outburst health check fails. Disabled if zero.
+	MaxBacklog int64
+
+	// Number of failed attempts after which a row is moved from the outbox into
+	// its quarantine table (see QuarantineTable). Defaults to 10.
+	//
+	// Only failures caused by the row itself count, e.g. an oversized message or
+	// an unknown topic. Timeouts and unreachable brokers never quarantine a row.
+	MaxAttempts uint
+
 	// Turn on verbose debug logging.
 	EnableDebugLogging bool
 
@@ -69,31 +101,88 @@ type Options struct {
 	testDisableIterNotify bool
 }
 
-// debugEnabled gates every debug log call; mirrors Options.EnableDebugLogging.
+// DefaultChannel is the channel a relay listens on if Options.Channel is empty.
+const DefaultChannel = "kafka-message"
+
+// debugEnabled gates every debug log call; set if any relay enables
+// Options.EnableDebugLogging.
 var debugEnabled atomic.Bool
 
-// Prometheus metrics, auto-registered on the default registry.
+// relayStatus is the runtime state of a relay: whether the notify listener
+// currently holds a LISTEN connection and the outbox size last seen by
+// outboxSizeJob, both reported by the health check, plus what the admin page
+// shows and controls.
+type relayStatus struct {
+	listening      atomic.Bool
+	lastOutboxSize atomic.Int64
+
+	// paused relays leave all rows in the outbox, see Relay.Pause.
+	paused atomic.Bool
+
+	// shard queues of the running notify listener, nil while not listening.
+	queues atomic.Pointer[[]chan outboxRef]
+
+	// sweepRequests asks the relay for an extra sweep, see Relay.TriggerSweep.
+	sweepRequests chan struct{}
+
+	recentErrors recentErrors
+}
+
+func newRelayStatus() *relayStatus {
+	return &relayStatus{sweepRequests: make(chan struct{}, 1)}
+}
+
+// relayStatuses holds the *relayStatus of every relay by its name.
+var relayStatuses sync.Map
+
+func statusOf(name string) *relayStatus {
+	if status, ok := relayStatuses.Load(name); ok {
+		return status.(*relayStatus)
+	}
+
+	status, _ := relayStatuses.LoadOrStore(name, newRelayStatus())
+	return status.(*relayStatus)
+}
+
+// Prometheus metrics, auto-registered on the default registry. All metrics are
+// labeled with the name of the relay as "outbox".
 var (
-	outboxSizeGauge = promauto.NewGauge(prometheus.GaugeOpts{
+	outboxSizeGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "outburst_outbox_size",
-		Help: "Current number of undelivered rows in the outbox table.",
-	})
-	vacuumDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Help: "Current number of undelivered rows in the outbox table that are due.",
+	}, []string{"outbox"})
+	scheduledSizeGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "outburst_scheduled_size",
+		Help: "Current number of rows in the outbox table scheduled for later delivery.",
+	}, []string{"outbox"})
+	vacuumDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
 		Name: "outburst_vacuum_duration_seconds",
 		Help: "Wall-clock time spent in the outbox VACUUM job.",
-	})
-	iterationDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+	}, []string{"outbox"})
+	iterationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
 		Name: "outburst_iteration_duration_seconds",
 		Help: "Wall-clock time of a single sweeper pass.",
-	})
-	errorCounter = promauto.NewCounter(prometheus.CounterOpts{
+	}, []string{"outbox"})
+	errorCounter = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "outburst_errors_total",
 		Help: "Total number of failed sweeper passes.",
-	})
+	}, []string{"outbox"})
+	failedAttemptsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "outburst_failed_attempts_total",
+		Help: "Total number of failed attempts to publish a row, caused by the row itself.",
+	}, []string{"outbox"})
+	quarantinedCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "outburst_quarantined_total",
+		Help: "Total number of rows moved into the quarantine table.",
+	}, []string{"outbox"})
+	quarantineSizeGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "outburst_quarantine_size",
+		Help: "Current number of rows in the quarantine table.",
+	}, []string{"outbox"})
 	eventsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "outburst_events_total",
 		Help: "Total number of rows published to Kafka.",
-	}, []string{"topic", "type"})
+	}, []string{"outbox", "topic", "type"})
 )
 
 // debugLog emits a debug log line only while debug logging is enabled.
@@ -106,38 +195,84 @@ func debugLog(ctx context.Context, log *slog.Logger, msg string, args ...any) {
 // Initialize provisions the outbox table when needed and launches the
 // background relay: a LISTEN/NOTIFY consumer plus periodic maintenance jobs. It
 // returns once everything is wired; the relay keeps running until ctx is
-// cancelled.
-func Initialize(ctx context.Context, opts Options) error {
-	debugEnabled.Store(opts.EnableDebugLogging)
+// cancelled or the returned stop function is called. Stop cancels the relay and
+// waits for its workers and running sweeps, so the producer can be closed
+// afterwards.
+//
+// Call Initialize once per outbox to relay several outboxes in one process.
+func Initialize(ctx context.Context, opts Options) (stop func(ctx context.Context) error, err error) {
+	if opts.EnableDebugLogging {
+		debugEnabled.Store(true)
+	}
 
 	if opts.Database == nil {
-		return fmt.Errorf("database must be specified")
+		return nil, fmt.Errorf("database must be specified")
 	}
 
 	if opts.OutboxTable == "" {
-		return fmt.Errorf("no outbox table defined")
+		return nil, fmt.Errorf("no outbox table defined")
 	}
 
 	db := outboxDB{
-		DB:    opts.Database,
-		Table: opts.OutboxTable,
+		DB:          opts.Database,
+		Table:       opts.OutboxTable,
+		Name:        opts.Name,
+		Channel:     opts.Channel,
+		MaxAttempts: orDefault(opts.MaxAttempts, defaultMaxAttempts),
+
+		StrictOrdering: opts.StrictOrdering,
 	}
 
 	if err := ensureOutboxTable(ctx, db); err != nil {
-		return fmt.Errorf("create outbox table: %w", err)
+		return nil, fmt.Errorf("create outbox table: %w", err)
 	}
 
 	if opts.Kafka == nil {
 		slog.Warn("No kafka producer configured, outburst stays idle")
-		return nil
+		return func(context.Context) error { return nil }, nil
 	}
 
-	if err := scheduleJobs(ctx, opts, db, opts.Kafka, orDefault(opts.BatchSize, 128)); err != nil {
-		return fmt.Errorf("schedule cron tasks: %w", err)
+	ctx, cancel := context.WithCancel(ctx)
+	tasks := &relayTasks{}
+
+	if err := scheduleJobs(ctx, opts, db, opts.Kafka, orDefault(opts.BatchSize, 128), tasks); err != nil {
+		cancel()
+		return nil, fmt.Errorf("schedule cron tasks: %w", err)
 	}
 
-	slog.Info("Starting outburst background task")
-	go func() {
+	healthCheckName := "outburst"
+	if opts.Name != "" {
+		healthCheckName = "outburst-" + opts.Name
+	}
+
+	// a re-initialized relay starts with a fresh status
+	status := newRelayStatus()
+	relayStatuses.Store(db.name(), status)
+
+	startup_base.RegisterHealthCheck(startup_base.HealthCheck{
+		Name:  healthCheckName,
+		Check: healthCheck(opts, status),
+	})
+
+	relay := &Relay{db: db, status: status}
+	relays.Store(db.name(), relay)
+
+	// sweeps requested using the admin page
+	go tasks.run(func() {
+		sweep := sweepJob(ctx, db, opts.Kafka, orDefault(opts.BatchSize, 128))
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-status.sweepRequests:
+				sweep()
+			}
+		}
+	})
+
+	slog.Info("Starting outburst background task", slog.String("outbox", db.name()), slog.String("channel", db.channel()))
+	go tasks.run(func() {
 		if opts.testDisableIterNotify {
 			return
 		}
@@ -154,9 +289,98 @@ func Initialize(ctx context.Context, opts Options) error {
 				time.Sleep(100 * time.Millisecond)
 			}
 		}
+	})
+
+	stop = func(ctx context.Context) error {
+		cancel()
+		err := tasks.wait(ctx)
+
+		// a stopped relay is not shown on the admin page anymore
+		relays.CompareAndDelete(db.name(), relay)
+		relayStatuses.CompareAndDelete(db.name(), status)
+
+		return err
+	}
+
+	return stop, nil
+}
+
+// sleepContext waits for the duration. It returns false if the context is done before.
+func sleepContext(ctx context.Context, duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// relayTasks tracks the goroutines and jobs of a relay, so stopping the relay can
+// wait for them. No task is started anymore once the relay is stopping.
+type relayTasks struct {
+	mu       sync.Mutex
+	stopping bool
+	wg       sync.WaitGroup
+}
+
+// run calls fn unless the relay is stopping.
+func (t *relayTasks) run(fn func()) {
+	t.mu.Lock()
+	if t.stopping {
+		t.mu.Unlock()
+		return
+	}
+
+	t.wg.Add(1)
+	t.mu.Unlock()
+
+	defer t.wg.Done()
+	fn()
+}
+
+// wrap returns a function that calls fn using run.
+func (t *relayTasks) wrap(fn func()) func() {
+	return func() { t.run(fn) }
+}
+
+// wait stops starting new tasks and waits until the running tasks finished or
+// the context is done.
+func (t *relayTasks) wait(ctx context.Context) error {
+	t.mu.Lock()
+	t.stopping = true
+	t.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
 	}()
 
-	return nil
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("wait for outburst workers: %w", ctx.Err())
+	}
+}
+
+// healthCheck fails while the notify listener is not connected or the outbox
+// backlog exceeds Options.MaxBacklog.
+func healthCheck(opts Options, status *relayStatus) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if !opts.testDisableIterNotify && !status.listening.Load() {
+			return errors.New("notify listener not connected")
+		}
+
+		if size := status.lastOutboxSize.Load(); opts.MaxBacklog > 0 && size > opts.MaxBacklog {
+			return fmt.Errorf("outbox backlog of %d rows exceeds %d", size, opts.MaxBacklog)
+		}
+
+		return nil
+	}
 }
 
 // orDefault returns value unless it is the zero value, in which case it returns
@@ -170,7 +394,7 @@ func orDefault[T comparable](value, fallback T) T {
 	return fallback
 }
 
-func scheduleJobs(ctx context.Context, opts Options, db outboxDB, producer *kafka.Producer, batchSize uint) error {
+func scheduleJobs(ctx context.Context, opts Options, db outboxDB, producer *kafka.Producer, batchSize uint, tasks *relayTasks) error {
 	scheduler := opts.Cron
 	ownScheduler := scheduler == nil
 	if ownScheduler {
@@ -186,7 +410,7 @@ func scheduleJobs(ctx context.Context, opts Options, db outboxDB, producer *kafk
 	// Reclaim dead tuples roughly every 10-15 minutes.
 	if _, err := scheduler.NewJob(
 		gocron.DurationRandomJob(10*time.Minute, 15*time.Minute),
-		gocron.NewTask(vacuumJob(db)),
+		gocron.NewTask(tasks.wrap(vacuumJob(db))),
 		gocron.WithContext(ctx),
 		gocron.WithSingletonMode(gocron.LimitModeReschedule),
 	); err != nil {
@@ -196,7 +420,7 @@ func scheduleJobs(ctx context.Context, opts Options, db outboxDB, producer *kafk
 	// Report the outbox backlog roughly every 30-45 seconds.
 	if _, err := scheduler.NewJob(
 		gocron.DurationRandomJob(30*time.Second, 45*time.Second),
-		gocron.NewTask(outboxSizeJob(db)),
+		gocron.NewTask(tasks.wrap(outboxSizeJob(db))),
 		gocron.WithContext(ctx),
 		gocron.WithSingletonMode(gocron.LimitModeReschedule),
 	); err != nil {
@@ -207,7 +431,7 @@ func scheduleJobs(ctx context.Context, opts Options, db outboxDB, producer *kafk
 		// Safety net that sweeps up rows any missed NOTIFY left behind.
 		if _, err := scheduler.NewJob(
 			gocron.DurationRandomJob(10*time.Second, 20*time.Second),
-			gocron.NewTask(sweepJob(ctx, db, producer, batchSize)),
+			gocron.NewTask(tasks.wrap(sweepJob(ctx, db, producer, batchSize))),
 			gocron.WithContext(ctx),
 			gocron.WithSingletonMode(gocron.LimitModeReschedule),
 		); err != nil {
@@ -228,14 +452,43 @@ func scheduleJobs(ctx context.Context, opts Options, db outboxDB, producer *kafk
 	return nil
 }
 
+// defaultMaxAttempts is the default of Options.MaxAttempts.
+const defaultMaxAttempts uint = 10
+
 // outboxDB pairs a database handle with the name of the outbox table it serves.
 type outboxDB struct {
 	*sqlx.DB
 	Table string
+
+	// Name of the relay and channel it listens on, see Options.
+	Name    string
+	Channel string
+
+	// Failed attempts after which a row is quarantined.
+	MaxAttempts uint
+
+	// Publish the rows of a key strictly in order, see Options.StrictOrdering.
+	StrictOrdering bool
+}
+
+// name returns the name of the relay, which defaults to the table.
+func (db outboxDB) name() string {
+	return orDefault(db.Name, db.Table)
+}
+
+// channel returns the notification channel of the relay.
+func (db outboxDB) channel() string {
+	return orDefault(db.Channel, DefaultChannel)
 }
 
 func sweepJob(ctx context.Context, db outboxDB, producer *kafka.Producer, batchSize uint) func() {
+	status := statusOf(db.name())
+
 	return func() {
+		if status.paused.Load() {
+			return
+		}
+
 		_ = startup_tracing.Trace(ctx, "sweep", func(ctx context.Context, span trace.Span) error {
 			sweepOutbox(ctx, db, producer, batchSize)
 			return nil
@@ -276,10 +529,14 @@ func runNotifyListener(ctx context.Context, db outboxDB, producer *kafka.Produce
 }
 
 func consumeNotifications(ctx context.Context, conn *pgx.Conn, log *slog.Logger, db outboxDB, producer *kafka.Producer, workerCount, queueBuffer uint) error {
-	if _, err := conn.Exec(ctx, `LISTEN "kafka-message"`); err != nil {
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{db.channel()}.Sanitize()); err != nil {
 		return fmt.Errorf("listen for events: %w", err)
 	}
 
+	status := statusOf(db.name())
+	status.listening.Store(true)
+	defer status.listening.Store(false)
+
 	if workerCount < 1 {
 		workerCount = 1
 	}
@@ -292,23 +549,34 @@ func consumeNotifications(ctx context.Context, conn *pgx.Conn, log *slog.Logger,
 	// by the same goroutine, and therefore published in order, while different
 	// keys make progress in parallel — the property CQRS consumers rely on for
 	// per-aggregate ordering.
-	shards := make([]chan int64, workerCount)
+	shards := make([]chan outboxRef, workerCount)
 	var wg sync.WaitGroup
 	for i := range shards {
 		// A saturated channel back-pressures the LISTEN loop (see
 		// WorkerQueueBuffer).
-		shards[i] = make(chan int64, queueBuffer)
+		shards[i] = make(chan outboxRef, queueBuffer)
 		wg.Add(1)
-		go func(ids <-chan int64) {
+		go func(refs <-chan outboxRef) {
 			defer wg.Done()
-			for id := range ids {
-				forwardGuarded(ctx, log, db, producer, id)
+			for ref := range refs {
+				if status.paused.Load() {
+					// the row stays in the outbox until the relay is resumed
+					debugLog(ctx, log, "Relay paused, skipping row", "id", ref.ID)
+					continue
+				}
+
+				forwardGuarded(ctx, log, db, producer, ref)
 			}
 		}(shards[i])
 	}
+
+	status.queues.Store(&shards)
+
 	defer func() {
-		for _, ids := range shards {
-			close(ids)
+		status.queues.Store(nil)
+
+		for _, refs := range shards {
+			close(refs)
 		}
 		wg.Wait()
 	}()
@@ -326,11 +594,11 @@ func consumeNotifications(ctx context.Context, conn *pgx.Conn, log *slog.Logger,
 			continue
 		}
 
-		shards[shardFor(key, len(shards))] <- id
+		shards[shardFor(key, len(shards))] <- outboxRef{ID: id, Key: key}
 	}
 }
 
-// notifyPayload is the JSON body carried on the "kafka-message" channel. It
+// notifyPayload is the JSON body carried on the notification channel. It
 // bundles the outbox row id with its kafka_key so the listener can pick a shard
 // without a follow-up query. Emit it from the insert trigger, for example:
 //
@@ -355,6 +623,12 @@ func parseNotification(ctx context.Context, log *slog.Logger, payload string) (i
 	return decoded.ID, key, true
 }
 
+// outboxRef references a row of the outbox together with its kafka_key.
+type outboxRef struct {
+	ID  int64          `db:"id"`
+	Key sql.NullString `db:"kafka_key"`
+}
+
 // shardFor picks the worker shard for a kafka_key. Rows with no key carry no
 // ordering guarantee, so they all land on shard 0.
 func shardFor(key sql.NullString, n int) int {
@@ -367,7 +641,7 @@ func shardFor(key sql.NullString, n int) int {
 	return int(digest.Sum32() % uint32(n)) // #nosec G115 -- n is a small positive worker count
 }
 
-func forwardGuarded(ctx context.Context, log *slog.Logger, db outboxDB, producer *kafka.Producer, id int64) {
+func forwardGuarded(ctx context.Context, log *slog.Logger, db outboxDB, producer *kafka.Producer, ref outboxRef) {
 	// Without this, an unexpected panic here would tear down the whole process.
 	// Dev/test still panic loudly; production logs and leaves the rolled-back
 	// row for the sweeper to retry.
@@ -376,14 +650,16 @@ func forwardGuarded(ctx context.Context, log *slog.Logger, db outboxDB, producer
 			if startup_base.IsDevelopment() || startup_base.IsTesting() {
 				panic(r)
 			}
-			log.ErrorContext(ctx, "Recovered from panic while forwarding message", "id", id, "panic", r)
+			log.ErrorContext(ctx, "Recovered from panic while forwarding message", "id", ref.ID, "panic", r)
 		}
 	}()
 
 	_ = startup_tracing.Trace(ctx, "forwardRow", func(ctx context.Context, span trace.Span) error {
-		err := forwardRow(ctx, db, id, producer)
+		err := forwardNotified(ctx, db, producer, ref)
 		if err != nil {
-			log.WarnContext(ctx, "Failed to forward message", "id", id, sl.Error(err))
+			log.WarnContext(ctx, "Failed to forward message", "id", ref.ID, sl.Error(err))
+			statusOf(db.name()).recentErrors.add(ref.ID, err)
+			recordFailure(ctx, log, db, err)
 		}
 		return err
 	})
@@ -396,20 +672,44 @@ func outboxSizeJob(db outboxDB) func() {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		var count int64
-		countQuery := fmt.Sprintf(`SELECT COUNT(*) FROM %s`, db.Table)
-		if err := db.GetContext(ctx, &count, countQuery); err != nil {
+		// rows scheduled for later are not part of the backlog
+		var sizes struct {
+			Due       int64 `db:"due"`
+			Scheduled int64 `db:"scheduled"`
+		}
+
+		countQuery := fmt.Sprintf(`
+			SELECT
+				COUNT(*) FILTER (WHERE not_before IS NULL OR not_before <= $1) AS due,
+				COUNT(*) FILTER (WHERE not_before > $1) AS scheduled
+			FROM %s
+		`, db.Table)
+
+		if err := db.GetContext(ctx, &sizes, countQuery, clock.GlobalClock.Now()); err != nil {
 			log.WarnContext(ctx, "Failed to read outbox size", sl.Error(err))
 			return
 		}
 
-		outboxSizeGauge.Set(float64(count))
-		debugLog(ctx, log, "Outbox size", "count", count)
+		count := sizes.Due
+
+		outboxSizeGauge.WithLabelValues(db.name()).Set(float64(count))
+		scheduledSizeGauge.WithLabelValues(db.name()).Set(float64(sizes.Scheduled))
+		statusOf(db.name()).lastOutboxSize.Store(count)
+		debugLog(ctx, log, "Outbox size", "count", count, "scheduled", sizes.Scheduled)
+
+		var quarantined int64
+		quarantineQuery := fmt.Sprintf(`SELECT COUNT(*) FROM %s`, QuarantineTable(db.Table))
+		if err := db.GetContext(ctx, &quarantined, quarantineQuery); err != nil {
+			log.WarnContext(ctx, "Failed to read quarantine size", sl.Error(err))
+			return
+		}
+
+		quarantineSizeGauge.WithLabelValues(db.name()).Set(float64(quarantined))
 	}
 }
 
 func vacuumJob(db outboxDB) func() {
-	lockID := advisoryLockID("outburst:vacuum")
+	lockID := vacuumLockID(db)
 
 	return func() {
 		log := slog.Default().With("component", "vacuum")
@@ -444,7 +744,7 @@ func vacuumJob(db outboxDB) func() {
 
 		log.InfoContext(ctx, "Running vacuum")
 		start := time.Now()
-		defer func() { vacuumDuration.Observe(time.Since(start).Seconds()) }()
+		defer func() { vacuumDuration.WithLabelValues(db.name()).Observe(time.Since(start).Seconds()) }()
 
 		// VACUUM refuses to run inside a transaction, so issue it directly on
 		// the raw connection.
@@ -456,7 +756,7 @@ func vacuumJob(db outboxDB) func() {
 
 func sweepOutbox(ctx context.Context, db outboxDB, producer *kafka.Producer, batchSize uint) {
 	log := slog.Default().With("component", "outburst")
-	lockID := advisoryLockID("outburst:batchIter")
+	lockID := advisoryLockID("outburst:batchIter:" + db.Table)
 
 	conn, err := db.Connx(ctx)
 	if err != nil {
@@ -491,15 +791,26 @@ func sweepOutbox(ctx context.Context, db outboxDB, producer *kafka.Producer, bat
 			return sweepBatch(ctx, db, producer, limit)
 		})
 
-		iterationDuration.Observe(time.Since(start).Seconds())
+		iterationDuration.WithLabelValues(db.name()).Observe(time.Since(start).Seconds())
 
 		if err != nil {
+			if ctx.Err() != nil {
+				// the relay is stopping
+				return
+			}
+
 			log.WarnContext(ctx, "Sweep failed", sl.Error(err))
 
-			errorCounter.Inc()
+			errorCounter.WithLabelValues(db.name()).Inc()
+			statusOf(db.name()).recentErrors.add(0, err)
+
+			// A row failing over and over must not block the outbox forever.
+			recordFailure(ctx, log, db, err)
 
 			// Back off briefly before retrying.
-			time.Sleep(1 * time.Second)
+			if !sleepContext(ctx, 1*time.Second) {
+				return
+			}
 
 			continue
 		}
@@ -512,9 +823,12 @@ func sweepOutbox(ctx context.Context, db outboxDB, producer *kafka.Producer, bat
 		// A full batch hints there may be more waiting, so keep going after a
 		// short pause. A partial batch means we drained it and can hand control
 		// back to the scheduler.
-		if count == limit {
+		if count >= limit {
 			debugLog(ctx, log, "Published batch to kafka", "count", count)
-			time.Sleep(500 * time.Millisecond)
+			if !sleepContext(ctx, 500*time.Millisecond) {
+				return
+			}
+
 			continue
 		}
 
@@ -539,6 +853,11 @@ func releaseAdvisoryLock(ctx context.Context, conn *sqlx.Conn, lockID int64) err
 	return err
 }
 
+// vacuumLockID is the advisory lock that serializes the vacuum of an outbox table.
+func vacuumLockID(db outboxDB) int64 {
+	return advisoryLockID("outburst:vacuum:" + db.Table)
+}
+
 // advisoryLockID derives a stable 64-bit advisory-lock key from a name.
 func advisoryLockID(name string) int64 {
 	digest := fnv.New64()
@@ -547,7 +866,23 @@ func advisoryLockID(name string) int64 {
 }
 
 func sweepBatch(ctx context.Context, db outboxDB, producer *kafka.Producer, limit uint) (uint, error) {
-	return ql.InNewTransactionWithResult(ctx, db, func(ctx ql.TxContext) (uint, error) {
+	if db.StrictOrdering {
+		return sweepBatchStrict(ctx, db, producer, limit)
+	}
+
+	return sweepUnordered(ctx, db, producer, limit, false)
+}
+
+// sweepUnordered publishes a batch of the oldest rows, skipping rows that are locked
+// by another transaction. With keyless set, only rows without a key are swept.
+//
+// Rows that were delivered are deleted even if other rows of the batch failed, so a
+// single failing row does not publish the rest of the batch again on every retry.
+// The count is the number of delivered rows, the error the first failure.
+func sweepUnordered(ctx context.Context, db outboxDB, producer *kafka.Producer, limit uint, keyless bool) (uint, error) {
+	var publishErr error
+
+	count, err := ql.InNewTransactionWithResult(ctx, db, func(ctx ql.TxContext) (uint, error) {
 		log := slog.Default()
 		debugLog(ctx, log, "Selecting pending rows")
 
@@ -555,12 +890,16 @@ func sweepBatch(ctx context.Context, db outboxDB, producer *kafka.Producer, limi
 			SELECT id, kafka_topic, kafka_key, kafka_value, kafka_header_keys, kafka_header_values
 			FROM %s
 			WHERE create_time < current_timestamp - interval '2' second
+				AND (not_before IS NULL OR not_before <= $2)
+				AND (NOT $3 OR kafka_key IS NULL)
 			ORDER BY id
 			LIMIT $1
 			FOR UPDATE SKIP LOCKED
 		`, db.Table)
 
-		rows, err := ql.Select[Message](ctx, query, limit)
+		// not_before is written using the clock of the application, which might be
+		// shifted by a time jump, so compare it with the same clock.
+		rows, err := ql.Select[Message](ctx, query, limit, clock.GlobalClock.Now(), keyless)
 		if err != nil {
 			return 0, err
 		}
@@ -569,22 +908,23 @@ func sweepBatch(ctx context.Context, db outboxDB, producer *kafka.Producer, limi
 			return 0, nil
 		}
 
-		if err := publishToKafka(ctx, producer, rows, true); err != nil {
-			return 0, fmt.Errorf("send: %w", err)
-		}
-
-		ids := make([]int64, 0, len(rows))
-		for _, row := range rows {
-			ids = append(ids, row.ID)
+		delivered, err := publishToKafka(ctx, db.name(), producer, rows, true, false)
+		if err != nil {
+			publishErr = fmt.Errorf("send: %w", err)
 		}
 
-		deleteStmt := fmt.Sprintf(`DELETE FROM %s WHERE id=ANY($1)`, db.Table)
-		if err := ql.Exec(ctx, deleteStmt, ids); err != nil {
-			return 0, fmt.Errorf("delete rows: %w", err)
+		if err := deleteRows(ctx, db, delivered); err != nil {
+			return 0, err
 		}
 
-		return uint(len(rows)), nil
+		return uint(len(delivered)), nil
 	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return count, publishErr
 }
 
 func forwardRow(ctx context.Context, db outboxDB, id int64, producer *kafka.Producer) error {
@@ -595,21 +935,22 @@ func forwardRow(ctx context.Context, db outboxDB, id int64, producer *kafka.Prod
 		query := fmt.Sprintf(`
 			SELECT id, kafka_topic, kafka_key, kafka_value, kafka_header_keys, kafka_header_values
 			FROM %s
-			WHERE id=$1
+			WHERE id=$1 AND (not_before IS NULL OR not_before <= $2)
 			FOR UPDATE SKIP LOCKED
 		`, db.Table)
 
-		row, err := ql.FirstOrNil[Message](ctx, query, id)
+		row, err := ql.FirstOrNil[Message](ctx, query, id, clock.GlobalClock.Now())
 		if err != nil {
 			return err
 		}
 
 		if row == nil {
-			// Another instance already claimed or deleted this row.
+			// Another instance already claimed or deleted this row, or it is
+			// scheduled for later and left for the sweeper.
 			return nil
 		}
 
-		if err := publishToKafka(ctx, producer, []Message{*row}, false); err != nil {
+		if _, err := publishToKafka(ctx, db.name(), producer, []Message{*row}, false, false); err != nil {
 			return fmt.Errorf("send: %w", err)
 		}
 
@@ -622,7 +963,12 @@ func forwardRow(ctx context.Context, db outboxDB, id int64, producer *kafka.Prod
 	})
 }
 
-func publishToKafka(ctx context.Context, producer *kafka.Producer, rows []Message, batch bool) error {
+// publishToKafka produces the rows and waits for their delivery reports. It returns
+// the ids of the rows whose delivery was confirmed, also if publishing failed, so the
+// caller can delete them instead of publishing them again. A row that fails does not
+// stop the other rows from being produced, unless ordered is set. The error is the
+// first failure.
+func publishToKafka(ctx context.Context, name string, producer *kafka.Producer, rows []Message, batch, ordered bool) ([]int64, error) {
 	debugLog(ctx, slog.Default(), "Publishing rows to kafka", slog.Int("count", len(rows)))
 
 	sendType := "single"
@@ -634,69 +980,137 @@ func publishToKafka(ctx context.Context, producer *kafka.Producer, rows []Messag
 
 	deliveries := make(chan kafka.Event, len(rows))
 
-	return startup_tracing.Trace(ctx, operation, func(ctx context.Context, span trace.Span) error {
-		for _, row := range rows {
-			var key []byte
-			if row.Key.Valid && len(row.Key.String) > 0 {
-				key = []byte(row.Key.String)
+	return startup_tracing.TraceWithResult(ctx, operation, func(ctx context.Context, span trace.Span) ([]int64, error) {
+		var firstErr error
+		fail := func(err error) {
+			if firstErr == nil {
+				firstErr = err
 			}
+		}
 
-			var headers []kafka.Header
-			if len(row.HeaderKeys) > 0 && len(row.HeaderValues) > 0 {
-				for idx, hk := range row.HeaderKeys {
-					headers = append(headers, kafka.Header{Key: hk, Value: []byte(row.HeaderValues[idx])})
-				}
+		var produced int
+		for _, row := range rows {
+			if ordered && firstErr != nil {
+				// rows after a failed row must not overtake it
+				break
 			}
 
-			msg := &kafka.Message{
-				TopicPartition: kafka.TopicPartition{Topic: &row.Topic, Partition: kafka.PartitionAny},
-				Value:          row.Value,
-				Key:            key,
-				Timestamp:      row.Timestamp,
-				Headers:        headers,
+			msg, err := kafkaMessage(row)
+			if err != nil {
+				fail(&rowError{ID: row.ID, Err: err})
+				continue
 			}
 
 			debugLog(ctx, slog.Default(), "Producing message", "id", row.ID)
 
 			if err := producer.Produce(msg, deliveries); err != nil {
-				return fmt.Errorf("produce message %d: %w", row.ID, err)
+				fail(fmt.Errorf("produce message: %w", &rowError{ID: row.ID, Err: err}))
+				continue
 			}
-			eventsCounter.WithLabelValues(row.Topic, sendType).Inc()
+
+			produced++
+			eventsCounter.WithLabelValues(name, row.Topic, sendType).Inc()
 		}
 
 		debugLog(ctx, slog.Default(), "Awaiting delivery reports")
-		for range rows {
+
+		delivered := make([]int64, 0, produced)
+		for range produced {
 			var ev kafka.Event
 			select {
 			case ev = <-deliveries:
 			case <-ctx.Done():
 				// Don't hold the transaction open forever if delivery stalls.
-				return ctx.Err()
+				fail(ctx.Err())
+				return delivered, firstErr
 			}
 
 			debugLog(ctx, slog.Default(), "Delivery report", "event", ev)
 
 			switch e := ev.(type) {
 			case *kafka.Message:
-				if e.TopicPartition.Error != nil {
-					return fmt.Errorf("delivery failed for partition %d: %w",
-						e.TopicPartition.Partition, e.TopicPartition.Error)
+				id, ok := e.Opaque.(int64)
+
+				switch {
+				case e.TopicPartition.Error != nil:
+					err := e.TopicPartition.Error
+					if ok {
+						err = &rowError{ID: id, Err: err}
+					}
+
+					fail(fmt.Errorf("delivery failed for partition %d: %w",
+						e.TopicPartition.Partition, err))
+
+				case ok:
+					delivered = append(delivered, id)
+
+				default:
+					fail(fmt.Errorf("delivery report without row id: %v", e))
 				}
+
 			case kafka.Error:
-				return fmt.Errorf("sending kafka event: %w", e)
+				fail(fmt.Errorf("sending kafka event: %w", e))
 			case error:
-				return fmt.Errorf("sending kafka event: %w", e)
+				fail(fmt.Errorf("sending kafka event: %w", e))
 			default:
 				// Unknown event type: fail closed so the row is retried instead
 				// of being deleted as if it had been delivered.
-				return fmt.Errorf("unexpected delivery event %T: %v", ev, ev)
+				fail(fmt.Errorf("unexpected delivery event %T: %v", ev, ev))
 			}
 		}
-		debugLog(ctx, slog.Default(), "All deliveries confirmed")
-		return nil
+
+		if firstErr == nil {
+			debugLog(ctx, slog.Default(), "All deliveries confirmed")
+		}
+
+		return delivered, firstErr
 	})
 }
 
+// deleteRows deletes published rows from the outbox.
+func deleteRows(ctx ql.TxContext, db outboxDB, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	deleteStmt := fmt.Sprintf(`DELETE FROM %s WHERE id=ANY($1)`, db.Table)
+	if err := ql.Exec(ctx, deleteStmt, ids); err != nil {
+		return fmt.Errorf("delete rows: %w", err)
+	}
+
+	return nil
+}
+
+// kafkaMessage converts an outbox row into a kafka message. The id of the row is
+// carried as Opaque, so a failed delivery can be traced back to its row.
+func kafkaMessage(row Message) (*kafka.Message, error) {
+	if len(row.HeaderKeys) != len(row.HeaderValues) {
+		return nil, fmt.Errorf("got %d header keys but %d header values",
+			len(row.HeaderKeys), len(row.HeaderValues))
+	}
+
+	var key []byte
+	if row.Key.Valid && len(row.Key.String) > 0 {
+		key = []byte(row.Key.String)
+	}
+
+	var headers []kafka.Header
+	for idx, hk := range row.HeaderKeys {
+		headers = append(headers, kafka.Header{Key: hk, Value: []byte(row.HeaderValues[idx])})
+	}
+
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &row.Topic, Partition: kafka.PartitionAny},
+		Value:          row.Value,
+		Key:            key,
+		Timestamp:      row.Timestamp,
+		Headers:        headers,
+		Opaque:         row.ID,
+	}
+
+	return msg, nil
+}
+
 // Message is a single outbox row ready to be published to Kafka.
 type Message struct {
 	ID        int64     `db:"id"`
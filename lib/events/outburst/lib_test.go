@@ -1,11 +1,17 @@
 package outburst
 
 import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"strconv"
 	"testing"
 	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/flachnetz/go-admin"
 	"github.com/flachnetz/pgtest/v2"
 	"github.com/flachnetz/startup/v2/lib/ql"
 	"github.com/flachnetz/startup/v2/lib/testx"
@@ -122,7 +128,7 @@ func TestOutburstNotifyListen(t *testing.T) {
 
 	producer := svc.Kafka.Producer()
 
-	err := Initialize(ctx, Options{
+	stop, err := Initialize(ctx, Options{
 		Kafka:                producer,
 		Database:             svc.DB,
 		OutboxTable:          "outbox",
@@ -140,6 +146,10 @@ func TestOutburstNotifyListen(t *testing.T) {
 	message := svc.Kafka.TestConsumer("foobar").Message()
 	require.Equal(t, []byte("key-a"), message.Key)
 	require.Equal(t, []byte("message-a"), message.Value)
+
+	// stopping waits for the listener and its workers
+	require.NoError(t, stop(ctx))
+	require.Nil(t, relayByName("outbox"))
 }
 
 func TestOutburstBatch(t *testing.T) {
@@ -149,7 +159,7 @@ func TestOutburstBatch(t *testing.T) {
 
 	ctx := t.Context()
 
-	err := Initialize(ctx, Options{
+	_, err := Initialize(ctx, Options{
 		Kafka:                 svc.Kafka.Producer(),
 		Database:              svc.DB,
 		OutboxTable:           "outbox",
@@ -178,7 +188,7 @@ func TestOutburstHeadersAndNilKey(t *testing.T) {
 
 	ctx := t.Context()
 
-	err := Initialize(ctx, Options{
+	_, err := Initialize(ctx, Options{
 		Kafka:                svc.Kafka.Producer(),
 		Database:             svc.DB,
 		OutboxTable:          "outbox",
@@ -219,6 +229,41 @@ func TestForwardRowMissing(t *testing.T) {
 	require.NoError(t, err)
 }
 
+// An outbox table of an older version gets the missing columns and the indexes,
+// and ensuring an up-to-date table again leaves it unchanged.
+func TestEnsureOutboxTableUpgradesOldTable(t *testing.T) {
+	ctx := t.Context()
+
+	db := outboxDB{DB: sqlx.NewDb(pgtest.Connect(t), "pgx"), Table: "outbox", StrictOrdering: true}
+
+	_, err := db.ExecContext(ctx, `
+		CREATE TABLE outbox (
+			id                  bigserial NOT NULL PRIMARY KEY,
+			create_time         timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			kafka_topic         text NOT NULL,
+			kafka_key           text NULL,
+			kafka_value         BYTEA NOT NULL,
+			kafka_header_keys   text[] NOT NULL,
+			kafka_header_values text[] NOT NULL
+		)`)
+	require.NoError(t, err)
+
+	require.NoError(t, ensureOutboxTable(ctx, db))
+	require.NoError(t, ensureOutboxTable(ctx, db))
+
+	var columns []string
+	require.NoError(t, db.SelectContext(ctx, &columns, `
+		SELECT column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'outbox'`))
+	require.Subset(t, columns, []string{"attempts", "last_error", "not_before"})
+
+	var indexes []string
+	require.NoError(t, db.SelectContext(ctx, &indexes, `
+		SELECT indexname FROM pg_indexes
+		WHERE schemaname = current_schema() AND tablename = 'outbox'`))
+	require.Subset(t, indexes, []string{"outbox_scheduled_key", "outbox_key_order"})
+}
+
 // outboxSizeJob must publish the current row count onto the outbox-size gauge.
 func TestOutboxSizeJob(t *testing.T) {
 	svc := setupService(t)
@@ -234,7 +279,7 @@ func TestOutboxSizeJob(t *testing.T) {
 
 	outboxSizeJob(db)()
 
-	require.Equal(t, float64(3), testutil.ToFloat64(outboxSizeGauge))
+	require.Equal(t, float64(3), testutil.ToFloat64(outboxSizeGauge.WithLabelValues("outbox")))
 }
 
 // vacuumJob must run VACUUM and leave the rows untouched; when another caller
@@ -262,11 +307,502 @@ func TestVacuumJob(t *testing.T) {
 	require.NoError(t, err)
 	defer conn.Close()
 
-	locked, err := acquireAdvisoryLock(ctx, conn, advisoryLockID("outburst:vacuum"))
+	locked, err := acquireAdvisoryLock(ctx, conn, vacuumLockID(db))
 	require.NoError(t, err)
 	require.True(t, locked)
 
 	vacuumJob(db)() // returns promptly, no panic
 
-	require.NoError(t, releaseAdvisoryLock(ctx, conn, advisoryLockID("outburst:vacuum")))
+	require.NoError(t, releaseAdvisoryLock(ctx, conn, vacuumLockID(db)))
+}
+
+// A row that fails on every attempt is moved into the quarantine table once it
+// reaches the attempt limit, and can be requeued or discarded from there.
+func TestQuarantinePoisonRow(t *testing.T) {
+	svc := setupService(t)
+
+	ctx := t.Context()
+
+	db := outboxDB{DB: svc.DB, Table: "outbox", MaxAttempts: 2}
+	require.NoError(t, ensureOutboxTable(ctx, db))
+
+	// more header keys than values can never be published
+	svc.InsertOutbox(outboxEntry{
+		Topic:      "foobar",
+		Value:      []byte("x"),
+		HeaderKeys: []string{"h1", "h2"},
+		HeaderVals: []string{"v1"},
+	})
+
+	var id int64
+	require.NoError(t, svc.DB.GetContext(ctx, &id, "SELECT id FROM outbox"))
+
+	log := slog.Default()
+	producer := svc.Kafka.Producer()
+
+	err := forwardRow(ctx, db, id, producer)
+	require.Error(t, err)
+	recordFailure(ctx, log, db, err)
+
+	var attempts int
+	require.NoError(t, svc.DB.GetContext(ctx, &attempts, "SELECT attempts FROM outbox WHERE id = $1", id))
+	require.Equal(t, 1, attempts)
+
+	err = forwardRow(ctx, db, id, producer)
+	require.Error(t, err)
+	recordFailure(ctx, log, db, err)
+
+	quarantined := testx.MustTransactWithResult(t, svc.DB, func(ctx ql.TxContext) ([]QuarantinedMessage, error) {
+		return ListQuarantined(ctx, "outbox", 10)
+	})
+	require.Len(t, quarantined, 1)
+	require.Equal(t, id, quarantined[0].ID)
+	require.Equal(t, 2, quarantined[0].Attempts)
+	require.Contains(t, quarantined[0].LastError, "header values")
+
+	var outboxSize int
+	require.NoError(t, svc.DB.GetContext(ctx, &outboxSize, "SELECT COUNT(*) FROM outbox"))
+	require.Zero(t, outboxSize)
+
+	// requeue starts over with a fresh attempt count
+	requeued := testx.MustTransactWithResult(t, svc.DB, func(ctx ql.TxContext) (int, error) {
+		return RequeueQuarantined(ctx, "outbox", "", id)
+	})
+	require.Equal(t, 1, requeued)
+
+	require.NoError(t, svc.DB.GetContext(ctx, &attempts, "SELECT attempts FROM outbox WHERE id = $1", id))
+	require.Zero(t, attempts)
+
+	// quarantine again and discard for good
+	for range 2 {
+		recordFailure(ctx, log, db, forwardRow(ctx, db, id, producer))
+	}
+
+	discarded := testx.MustTransactWithResult(t, svc.DB, func(ctx ql.TxContext) (int, error) {
+		return DiscardQuarantined(ctx, "outbox", id)
+	})
+	require.Equal(t, 1, discarded)
+
+	var quarantineSize int
+	require.NoError(t, svc.DB.GetContext(ctx, &quarantineSize, "SELECT COUNT(*) FROM outbox_quarantine"))
+	require.Zero(t, quarantineSize)
+}
+
+// A scheduled row is neither forwarded on the notify path nor swept before its
+// not_before time on the global clock.
+func TestScheduledRowWaitsForNotBefore(t *testing.T) {
+	svc := setupService(t)
+
+	svc.Kafka.CreateTopic("foobar", 4)
+
+	clock := testx.MockClock(t)
+
+	ctx := t.Context()
+
+	db := outboxDB{DB: svc.DB, Table: "outbox"}
+	require.NoError(t, ensureOutboxTable(ctx, db))
+
+	var id int64
+	require.NoError(t, svc.DB.GetContext(ctx, &id, `
+		INSERT INTO outbox (create_time, kafka_topic, kafka_key, kafka_value, kafka_header_keys, kafka_header_values, not_before)
+		VALUES (now() - interval '1' minute, 'foobar', 'key-a', 'message-a', '{}', '{}', $1)
+		RETURNING id
+	`, clock.Now().Add(15*time.Minute)))
+
+	producer := svc.Kafka.Producer()
+
+	require.NoError(t, forwardRow(ctx, db, id, producer))
+
+	count, err := sweepBatch(ctx, db, producer, 10)
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	clock.Add(15 * time.Minute)
+
+	count, err = sweepBatch(ctx, db, producer, 10)
+	require.NoError(t, err)
+	require.Equal(t, uint(1), count)
+
+	messages := svc.Consume("foobar", 1)
+	require.Equal(t, []byte("message-a"), messages[0].Value)
+}
+
+// Two relays in one process each drain their own table, notified on their own
+// channel, and report their own metrics.
+func TestOutburstMultipleOutboxes(t *testing.T) {
+	svc := setupService(t)
+
+	svc.Kafka.CreateTopic("foobar", 4)
+	svc.Kafka.CreateTopic("bulk", 4)
+
+	ctx := t.Context()
+
+	for _, opts := range []Options{
+		{OutboxTable: "outbox"},
+		{OutboxTable: "bulk_outbox", Name: "bulk", Channel: "bulk-message", WorkerCount: 1},
+	} {
+		opts.Kafka = svc.Kafka.Producer()
+		opts.Database = svc.DB
+		opts.testDisableIterBatch = true
+
+		_, err := Initialize(ctx, opts)
+		require.NoError(t, err)
+	}
+
+	testx.MustTransact(t, svc.DB, func(ctx ql.TxContext) {
+		err := ql.Exec(ctx, `
+			WITH ids AS (
+				INSERT INTO bulk_outbox (kafka_topic, kafka_key, kafka_value, kafka_header_keys, kafka_header_values)
+				VALUES ('bulk', 'key-b', 'message-b', '{}', '{}')
+				RETURNING id, kafka_key)
+			SELECT pg_notify('bulk-message', json_build_object('id', id, 'key', kafka_key)::text)
+			FROM ids
+		`)
+		require.NoError(t, err)
+	})
+
+	svc.InsertOutbox(outboxEntry{Topic: "foobar", Key: new("key-a"), Value: []byte("message-a")})
+
+	require.Equal(t, []byte("message-b"), svc.Kafka.TestConsumer("bulk").Message().Value)
+	require.Equal(t, []byte("message-a"), svc.Kafka.TestConsumer("foobar").Message().Value)
+
+	require.Equal(t, float64(1), testutil.ToFloat64(eventsCounter.WithLabelValues("bulk", "bulk", "single")))
+}
+
+// The admin page reports the backlog per topic and the quarantined rows of a
+// relay, and requeues quarantined rows into its outbox.
+func TestAdminStatusAndRequeue(t *testing.T) {
+	svc := setupService(t)
+
+	ctx := t.Context()
+
+	db := outboxDB{DB: svc.DB, Table: "outbox", MaxAttempts: 1}
+	require.NoError(t, ensureOutboxTable(ctx, db))
+
+	relays.Store("outbox", &Relay{db: db, status: newRelayStatus()})
+	t.Cleanup(func() { relays.Delete("outbox") })
+
+	svc.InsertOutbox(outboxEntry{Topic: "foobar", Value: []byte("a")})
+	svc.InsertOutbox(outboxEntry{Topic: "foobar", Value: []byte("b")})
+	svc.InsertOutbox(outboxEntry{
+		Topic:      "poison",
+		Value:      []byte("x"),
+		HeaderKeys: []string{"h1"},
+		HeaderVals: []string{},
+	})
+
+	var poisonID int64
+	require.NoError(t, svc.DB.GetContext(ctx, &poisonID, "SELECT id FROM outbox WHERE kafka_topic = 'poison'"))
+
+	recordFailure(ctx, slog.Default(), db, forwardRow(ctx, db, poisonID, svc.Kafka.Producer()))
+
+	handler := admin.NewAdminHandler("/admin", "test", AdminHandlers("")...)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/outburst/status", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var stats []RelayStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	require.Len(t, stats, 1)
+
+	require.Len(t, stats[0].Backlog, 1)
+	require.Equal(t, "foobar", stats[0].Backlog[0].Topic)
+	require.EqualValues(t, 2, stats[0].Backlog[0].Due)
+	require.NotNil(t, stats[0].Oldest)
+
+	require.EqualValues(t, 1, stats[0].QuarantinedRows)
+	require.Equal(t, poisonID, stats[0].Quarantined[0].ID)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/outburst", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "Relay outbox")
+	require.Contains(t, rec.Body.String(), "poison")
+
+	target := "/admin/outburst/requeue?outbox=outbox&id=" + strconv.FormatInt(poisonID, 10)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"requeued":1`)
+
+	var outboxSize int
+	require.NoError(t, svc.DB.GetContext(ctx, &outboxSize, "SELECT COUNT(*) FROM outbox"))
+	require.Equal(t, 3, outboxSize)
+}
+
+// insertOrdered inserts rows of one key that are old enough to be swept and returns
+// their ids. No notification is sent.
+func (t *testServices) insertOrdered(topic, key string, values ...string) []int64 {
+	t.Helper()
+
+	var ids []int64
+	for _, value := range values {
+		var id int64
+		require.NoError(t, t.DB.GetContext(t.Context(), &id, `
+			INSERT INTO outbox (create_time, kafka_topic, kafka_key, kafka_value, kafka_header_keys, kafka_header_values)
+			VALUES (now() - interval '1' minute, $1, $2, $3, '{}', '{}')
+			RETURNING id
+		`, topic, key, []byte(value)))
+
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+func messageValues(messages []kafka.Message) []string {
+	var values []string
+	for _, msg := range messages {
+		values = append(values, string(msg.Value))
+	}
+
+	return values
+}
+
+// With strict ordering a notification publishes the older rows of its key first, so
+// lost and reordered notifications can not reorder the rows of a key.
+func TestStrictOrderingSurvivesNotifyLossAndReordering(t *testing.T) {
+	svc := setupService(t)
+
+	svc.Kafka.CreateTopic("ordered", 4)
+
+	ctx := t.Context()
+
+	db := outboxDB{DB: svc.DB, Table: "outbox", StrictOrdering: true}
+	require.NoError(t, ensureOutboxTable(ctx, db))
+
+	ids := svc.insertOrdered("ordered", "key-a", "a1", "a2", "a3")
+	other := svc.insertOrdered("ordered", "key-b", "b1")
+
+	producer := svc.Kafka.Producer()
+
+	keyA := sql.NullString{String: "key-a", Valid: true}
+	keyB := sql.NullString{String: "key-b", Valid: true}
+
+	// the notifications of a1 and a2 got lost, a3 arrives first
+	require.NoError(t, forwardNotified(ctx, db, producer, outboxRef{ID: ids[2], Key: keyA}))
+
+	// late notifications of rows that are published already do nothing
+	require.NoError(t, forwardNotified(ctx, db, producer, outboxRef{ID: ids[0], Key: keyA}))
+	require.NoError(t, forwardNotified(ctx, db, producer, outboxRef{ID: ids[1], Key: keyA}))
+
+	// other keys are not published along
+	var remaining []int64
+	require.NoError(t, svc.DB.SelectContext(ctx, &remaining, "SELECT id FROM outbox"))
+	require.Equal(t, other, remaining)
+
+	require.NoError(t, forwardNotified(ctx, db, producer, outboxRef{ID: other[0], Key: keyB}))
+
+	var values []string
+	for _, msg := range svc.Consume("ordered", 4) {
+		if string(msg.Key) == "key-a" {
+			values = append(values, string(msg.Value))
+		}
+	}
+
+	require.Equal(t, []string{"a1", "a2", "a3"}, values)
+}
+
+// With strict ordering the sweeper waits for an older row of a key that is in flight
+// in another transaction instead of publishing the newer rows first.
+func TestStrictOrderingSweepWaitsForRowInFlight(t *testing.T) {
+	svc := setupService(t)
+
+	svc.Kafka.CreateTopic("ordered", 4)
+
+	ctx := t.Context()
+
+	db := outboxDB{DB: svc.DB, Table: "outbox", StrictOrdering: true}
+	require.NoError(t, ensureOutboxTable(ctx, db))
+
+	ids := svc.insertOrdered("ordered", "key-a", "a1", "a2")
+
+	// a worker of another instance is publishing a1
+	inFlight, err := svc.DB.BeginTxx(ctx, nil)
+	require.NoError(t, err)
+
+	_, err = inFlight.ExecContext(ctx, "SELECT id FROM outbox WHERE id = $1 FOR UPDATE", ids[0])
+	require.NoError(t, err)
+
+	type sweepResult struct {
+		count uint
+		err   error
+	}
+
+	done := make(chan sweepResult, 1)
+	go func() {
+		count, err := sweepBatch(ctx, db, svc.Kafka.Producer(), 10)
+		done <- sweepResult{count, err}
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("sweep did not wait for the row in flight")
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	// the publish of the other instance failed, a1 is still in the outbox
+	require.NoError(t, inFlight.Rollback())
+
+	result := <-done
+	require.NoError(t, result.err)
+	require.Equal(t, uint(2), result.count)
+
+	require.Equal(t, []string{"a1", "a2"}, messageValues(svc.Consume("ordered", 2)))
+}
+
+// Without strict ordering the sweeper skips the locked row and publishes the newer
+// row of the key first, the reordering strict ordering prevents.
+func TestUnorderedSweepSkipsRowInFlight(t *testing.T) {
+	svc := setupService(t)
+
+	svc.Kafka.CreateTopic("ordered", 4)
+
+	ctx := t.Context()
+
+	db := outboxDB{DB: svc.DB, Table: "outbox"}
+	require.NoError(t, ensureOutboxTable(ctx, db))
+
+	ids := svc.insertOrdered("ordered", "key-a", "a1", "a2")
+
+	inFlight, err := svc.DB.BeginTxx(ctx, nil)
+	require.NoError(t, err)
+
+	defer func() { _ = inFlight.Rollback() }()
+
+	_, err = inFlight.ExecContext(ctx, "SELECT id FROM outbox WHERE id = $1 FOR UPDATE", ids[0])
+	require.NoError(t, err)
+
+	count, err := sweepBatch(ctx, db, svc.Kafka.Producer(), 10)
+	require.NoError(t, err)
+	require.Equal(t, uint(1), count)
+
+	require.Equal(t, []string{"a2"}, messageValues(svc.Consume("ordered", 1)))
+}
+
+// insertPoison inserts a row that can not be converted into a kafka message and is
+// old enough to be swept.
+func (t *testServices) insertPoison(topic, key string) int64 {
+	t.Helper()
+
+	var id int64
+	require.NoError(t, t.DB.GetContext(t.Context(), &id, `
+		INSERT INTO outbox (create_time, kafka_topic, kafka_key, kafka_value, kafka_header_keys, kafka_header_values)
+		VALUES (now() - interval '1' minute, $1, $2, 'poison', '{h1}', '{}')
+		RETURNING id
+	`, topic, key))
+
+	return id
+}
+
+// A failing row does not roll back the rows delivered with it, so retrying the batch
+// does not publish them again.
+func TestSweepDeletesDeliveredRowsOfFailedBatch(t *testing.T) {
+	svc := setupService(t)
+
+	svc.Kafka.CreateTopic("swept", 4)
+
+	ctx := t.Context()
+
+	db := outboxDB{DB: svc.DB, Table: "outbox"}
+	require.NoError(t, ensureOutboxTable(ctx, db))
+
+	svc.insertOrdered("swept", "key-a", "a1", "a2")
+	poisonID := svc.insertPoison("swept", "key-b")
+	svc.insertOrdered("swept", "key-c", "c1")
+
+	produced := func() float64 {
+		return testutil.ToFloat64(eventsCounter.WithLabelValues("outbox", "swept", "batch"))
+	}
+
+	before := produced()
+
+	var rowErr *rowError
+
+	count, err := sweepBatch(ctx, db, svc.Kafka.Producer(), 10)
+	require.ErrorAs(t, err, &rowErr)
+	require.Equal(t, poisonID, rowErr.ID)
+	require.Equal(t, uint(3), count)
+
+	count, err = sweepBatch(ctx, db, svc.Kafka.Producer(), 10)
+	require.ErrorAs(t, err, &rowErr)
+	require.Equal(t, uint(0), count)
+
+	var remaining []int64
+	require.NoError(t, svc.DB.SelectContext(ctx, &remaining, "SELECT id FROM outbox"))
+	require.Equal(t, []int64{poisonID}, remaining)
+
+	require.ElementsMatch(t, []string{"a1", "a2", "c1"}, messageValues(svc.Consume("swept", 3)))
+	require.Equal(t, float64(3), produced()-before)
+}
+
+// With strict ordering a failing row stops the key. The rows in front of it are
+// published once, the rows behind it wait without being published again on retries.
+func TestStrictOrderingStopsKeyAtFailingRow(t *testing.T) {
+	svc := setupService(t)
+
+	svc.Kafka.CreateTopic("ordered", 4)
+
+	ctx := t.Context()
+
+	db := outboxDB{DB: svc.DB, Table: "outbox", StrictOrdering: true}
+	require.NoError(t, ensureOutboxTable(ctx, db))
+
+	first := svc.insertOrdered("ordered", "key-a", "a1")
+	poisonID := svc.insertPoison("ordered", "key-a")
+	last := svc.insertOrdered("ordered", "key-a", "a3")
+
+	produced := func() float64 {
+		return testutil.ToFloat64(eventsCounter.WithLabelValues("outbox", "ordered", "single"))
+	}
+
+	before := produced()
+
+	for range 3 {
+		err := forwardNotified(ctx, db, svc.Kafka.Producer(), outboxRef{ID: last[0], Key: sql.NullString{String: "key-a", Valid: true}})
+
+		var rowErr *rowError
+		require.ErrorAs(t, err, &rowErr)
+		require.Equal(t, poisonID, rowErr.ID)
+
+		recordFailure(ctx, slog.Default(), db, err)
+	}
+
+	var remaining []int64
+	require.NoError(t, svc.DB.SelectContext(ctx, &remaining, "SELECT id FROM outbox ORDER BY id"))
+	require.Equal(t, []int64{poisonID, last[0]}, remaining)
+	require.NotContains(t, remaining, first[0])
+
+	require.Equal(t, []string{"a1"}, messageValues(svc.Consume("ordered", 1)))
+	require.Equal(t, float64(1), produced()-before)
+}
+
+// A row that failed before is retried without the newer rows of its key.
+func TestStrictOrderingRetriesFailedRowAlone(t *testing.T) {
+	svc := setupService(t)
+
+	svc.Kafka.CreateTopic("ordered", 4)
+
+	ctx := t.Context()
+
+	db := outboxDB{DB: svc.DB, Table: "outbox", StrictOrdering: true}
+	require.NoError(t, ensureOutboxTable(ctx, db))
+
+	ids := svc.insertOrdered("ordered", "key-a", "a1", "a2", "a3")
+
+	// the delivery of a2 failed before
+	_, err := svc.DB.ExecContext(ctx, "UPDATE outbox SET attempts = 1 WHERE id = $1", ids[1])
+	require.NoError(t, err)
+
+	count, err := forwardKey(ctx, db, svc.Kafka.Producer(), "key-a", ids[2], true)
+	require.NoError(t, err)
+	require.Equal(t, uint(2), count)
+
+	count, err = forwardKey(ctx, db, svc.Kafka.Producer(), "key-a", ids[2], true)
+	require.NoError(t, err)
+	require.Equal(t, uint(1), count)
+
+	require.Equal(t, []string{"a1", "a2", "a3"}, messageValues(svc.Consume("ordered", 3)))
 }
@@ -0,0 +1,173 @@
+package outburst
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/flachnetz/startup/v2/lib/clock"
+	"github.com/flachnetz/startup/v2/lib/ql"
+)
+
+// forwardNotified forwards a notified row. With strict ordering, all older due rows
+// of its key are forwarded first, so a lost or late notification can not reorder the
+// rows of a key.
+func forwardNotified(ctx context.Context, db outboxDB, producer *kafka.Producer, ref outboxRef) error {
+	if db.StrictOrdering && ref.Key.Valid {
+		_, err := forwardKey(ctx, db, producer, ref.Key.String, ref.ID, false)
+		return err
+	}
+
+	return forwardRow(ctx, db, ref.ID, producer)
+}
+
+// forwardKey publishes the due rows of a key up to the row upTo in the order of their
+// ids, within one transaction. Unlike forwardRow and the unordered sweep it does not
+// skip locked rows but waits for them: an older row of the key that is in flight in
+// another transaction, e.g. in a worker of another instance, is either published and
+// deleted by then or published here first. Returns the number of published rows.
+//
+// No row is produced after a row that fails. The rows in front of it that were
+// delivered are deleted, all others stay in the outbox. A row that failed before is
+// retried without the newer rows of its key, so they are not published again with
+// every attempt until it is published or quarantined.
+//
+// The rows are locked in the order of their ids, so two transactions forwarding the
+// same key can not deadlock.
+func forwardKey(ctx context.Context, db outboxDB, producer *kafka.Producer, key string, upTo int64, batch bool) (uint, error) {
+	var publishErr error
+
+	count, err := ql.InNewTransactionWithResult(ctx, db, func(ctx ql.TxContext) (uint, error) {
+		query := fmt.Sprintf(`
+			SELECT id, kafka_topic, kafka_key, kafka_value, kafka_header_keys, kafka_header_values, attempts
+			FROM %s
+			WHERE kafka_key = $1 AND id <= $2
+				AND (not_before IS NULL OR not_before <= $3)
+			ORDER BY id
+			FOR UPDATE
+		`, db.Table)
+
+		rows, err := ql.Select[keyRow](ctx, query, key, upTo, clock.GlobalClock.Now())
+		if err != nil {
+			return 0, err
+		}
+
+		if len(rows) == 0 {
+			// published by another transaction in the meantime
+			return 0, nil
+		}
+
+		if idx := slices.IndexFunc(rows, func(row keyRow) bool { return row.Attempts > 0 }); idx >= 0 {
+			rows = rows[:idx+1]
+		}
+
+		messages := make([]Message, 0, len(rows))
+		for _, row := range rows {
+			messages = append(messages, row.Message)
+		}
+
+		delivered, err := publishToKafka(ctx, db.name(), producer, messages, batch, true)
+		if err != nil {
+			publishErr = fmt.Errorf("send: %w", err)
+		}
+
+		// a newer row delivered after a failed row is published again after it
+		var published []int64
+		for _, row := range rows {
+			if !slices.Contains(delivered, row.ID) {
+				break
+			}
+
+			published = append(published, row.ID)
+		}
+
+		if err := deleteRows(ctx, db, published); err != nil {
+			return 0, err
+		}
+
+		return uint(len(published)), nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return count, publishErr
+}
+
+// keyRow is a row forwarded by forwardKey.
+type keyRow struct {
+	Message
+
+	Attempts int `db:"attempts"`
+}
+
+// sweepBatchStrict is sweepBatch for Options.StrictOrdering. Rows without a key carry
+// no ordering guarantee and are swept as a batch. The rows of every other key in the
+// batch are forwarded using forwardKey, key by key.
+func sweepBatchStrict(ctx context.Context, db outboxDB, producer *kafka.Producer, limit uint) (uint, error) {
+	count, err := sweepUnordered(ctx, db, producer, limit, true)
+	if err != nil {
+		return count, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, kafka_key
+		FROM %s
+		WHERE create_time < current_timestamp - interval '2' second
+			AND (not_before IS NULL OR not_before <= $2)
+			AND kafka_key IS NOT NULL
+		ORDER BY id
+		LIMIT $1
+	`, db.Table)
+
+	var refs []outboxRef
+	if err := db.SelectContext(ctx, &refs, query, limit, clock.GlobalClock.Now()); err != nil {
+		return count, fmt.Errorf("select pending keys: %w", err)
+	}
+
+	// one failing key must not hold back the other keys
+	var firstErr error
+
+	for _, head := range keyHeads(refs) {
+		forwarded, err := forwardKey(ctx, db, producer, head.Key, head.ID, true)
+		count += forwarded
+
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("forward key %q: %w", head.Key, err)
+		}
+	}
+
+	return count, firstErr
+}
+
+// keyHead is the newest row of a key within a sweep.
+type keyHead struct {
+	Key string
+	ID  int64
+}
+
+// keyHeads returns the newest row of every key in refs. The keys are ordered by
+// their oldest row, so the keys waiting the longest are forwarded first.
+func keyHeads(refs []outboxRef) []keyHead {
+	var heads []keyHead
+
+	index := map[string]int{}
+	for _, ref := range refs {
+		if !ref.Key.Valid {
+			continue
+		}
+
+		idx, ok := index[ref.Key.String]
+		if !ok {
+			index[ref.Key.String] = len(heads)
+			heads = append(heads, keyHead{Key: ref.Key.String, ID: ref.ID})
+			continue
+		}
+
+		heads[idx].ID = max(heads[idx].ID, ref.ID)
+	}
+
+	return heads
+}
@@ -0,0 +1,31 @@
+package outburst
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+func TestKeyHeads(t *testing.T) {
+	key := func(k string) sql.NullString { return sql.NullString{String: k, Valid: true} }
+
+	refs := []outboxRef{
+		{ID: 1, Key: key("b")},
+		{ID: 2, Key: key("a")},
+		{ID: 3},
+		{ID: 4, Key: key("b")},
+		{ID: 5, Key: key("c")},
+		{ID: 6, Key: key("a")},
+	}
+
+	// keys in the order of their oldest row, each up to its newest row
+	expected := []keyHead{{Key: "b", ID: 4}, {Key: "a", ID: 6}, {Key: "c", ID: 5}}
+
+	if heads := keyHeads(refs); !reflect.DeepEqual(heads, expected) {
+		t.Fatalf("got %+v, want %+v", heads, expected)
+	}
+
+	if heads := keyHeads(nil); len(heads) != 0 {
+		t.Fatalf("expected no heads, got %+v", heads)
+	}
+}
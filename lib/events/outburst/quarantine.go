@@ -0,0 +1,203 @@
+package outburst
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/flachnetz/startup/v2/lib/ql"
+	sl "github.com/flachnetz/startup/v2/startup_logging"
+)
+
+// maxErrorLength caps the error stored with a failed row.
+const maxErrorLength = 2048
+
+// rowError is a failure to publish one specific outbox row.
+type rowError struct {
+	ID  int64
+	Err error
+}
+
+func (e *rowError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.ID, e.Err)
+}
+
+func (e *rowError) Unwrap() error {
+	return e.Err
+}
+
+// QuarantineTable returns the name of the table that holds the quarantined rows
+// of the given outbox table.
+func QuarantineTable(outboxTable string) string {
+	return outboxTable + "_quarantine"
+}
+
+// QuarantinedMessage is an outbox row that failed too often and was moved out of the outbox.
+type QuarantinedMessage struct {
+	Message
+
+	Attempts       int       `db:"attempts"`
+	LastError      string    `db:"last_error"`
+	QuarantineTime time.Time `db:"quarantine_time"`
+}
+
+// countsAsAttempt returns false for errors caused by the kafka cluster instead of
+// the row, like timeouts while the brokers are down. Those must never quarantine a row.
+func countsAsAttempt(err error) bool {
+	var kafkaErr kafka.Error
+	if !errors.As(err, &kafkaErr) {
+		return true
+	}
+
+	if kafkaErr.IsTimeout() || kafkaErr.IsRetriable() {
+		return false
+	}
+
+	switch kafkaErr.Code() {
+	case kafka.ErrAllBrokersDown, kafka.ErrTransport, kafka.ErrQueueFull, kafka.ErrMsgTimedOut, kafka.ErrTimedOut:
+		return false
+	default:
+		return true
+	}
+}
+
+// recordFailure counts a failed attempt for the row that caused err, if any, and moves
+// the row into quarantine once it reached the maximum number of attempts.
+func recordFailure(ctx context.Context, log *slog.Logger, db outboxDB, err error) {
+	var rowErr *rowError
+	if !errors.As(err, &rowErr) || !countsAsAttempt(rowErr.Err) {
+		return
+	}
+
+	failedAttemptsCounter.WithLabelValues(db.name()).Inc()
+
+	attempts, quarantined, err := registerAttempt(ctx, db, rowErr)
+	if err != nil {
+		log.WarnContext(ctx, "Failed to record failed attempt", "id", rowErr.ID, sl.Error(err))
+		return
+	}
+
+	if quarantined {
+		quarantinedCounter.WithLabelValues(db.name()).Inc()
+		log.ErrorContext(ctx, "Moved row to quarantine", "id", rowErr.ID, "attempts", attempts, sl.Error(rowErr.Err))
+	}
+}
+
+func registerAttempt(ctx context.Context, db outboxDB, rowErr *rowError) (int, bool, error) {
+	type result struct {
+		attempts    int
+		quarantined bool
+	}
+
+	res, err := ql.InNewTransactionWithResult(ctx, db, func(ctx ql.TxContext) (result, error) {
+		lastError := rowErr.Err.Error()
+		if len(lastError) > maxErrorLength {
+			lastError = lastError[:maxErrorLength]
+		}
+
+		update := fmt.Sprintf(`
+			UPDATE %s
+			SET attempts = attempts + 1, last_error = $2
+			WHERE id = $1
+			RETURNING attempts
+		`, db.Table)
+
+		attempts, err := ql.FirstOrNil[int](ctx, update, rowErr.ID, lastError)
+		if err != nil {
+			return result{}, fmt.Errorf("update attempts: %w", err)
+		}
+
+		if attempts == nil {
+			// the row was forwarded or quarantined in the meantime
+			return result{}, nil
+		}
+
+		if *attempts < int(orDefault(db.MaxAttempts, defaultMaxAttempts)) { // #nosec G115 -- attempt limits are small
+			return result{attempts: *attempts}, nil
+		}
+
+		move := fmt.Sprintf(`
+			WITH moved AS (
+				DELETE FROM %[1]s WHERE id = $1
+				RETURNING id, create_time, kafka_topic, kafka_key, kafka_value, kafka_header_keys, kafka_header_values, attempts, last_error
+			)
+			INSERT INTO %[2]s (id, create_time, kafka_topic, kafka_key, kafka_value, kafka_header_keys, kafka_header_values, attempts, last_error)
+			SELECT * FROM moved
+		`, db.Table, QuarantineTable(db.Table))
+
+		if err := ql.Exec(ctx, move, rowErr.ID); err != nil {
+			return result{}, fmt.Errorf("move row to quarantine: %w", err)
+		}
+
+		return result{attempts: *attempts, quarantined: true}, nil
+	})
+
+	return res.attempts, res.quarantined, err
+}
+
+// ListQuarantined returns up to limit quarantined rows of the outbox table, oldest first.
+func ListQuarantined(ctx ql.TxContext, outboxTable string, limit int) ([]QuarantinedMessage, error) {
+	query := fmt.Sprintf(`
+		SELECT id, create_time, kafka_topic, kafka_key, kafka_value, kafka_header_keys, kafka_header_values,
+			attempts, COALESCE(last_error, '') AS last_error, quarantine_time
+		FROM %s
+		ORDER BY id
+		LIMIT $1
+	`, QuarantineTable(outboxTable))
+
+	return ql.Select[QuarantinedMessage](ctx, query, limit)
+}
+
+// RequeueQuarantined moves the quarantined rows back into the outbox table with a fresh
+// attempt count, e.g. after the topic was created. The rows keep their id and are
+// announced on the channel of the relay, an empty channel means DefaultChannel.
+// Returns the number of requeued rows.
+func RequeueQuarantined(ctx ql.TxContext, outboxTable, channel string, ids ...int64) (int, error) {
+	move := fmt.Sprintf(`
+		WITH moved AS (
+			DELETE FROM %[1]s WHERE id = ANY($1)
+			RETURNING id, create_time, kafka_topic, kafka_key, kafka_value, kafka_header_keys, kafka_header_values
+		)
+		INSERT INTO %[2]s (id, create_time, kafka_topic, kafka_key, kafka_value, kafka_header_keys, kafka_header_values)
+		SELECT * FROM moved
+		RETURNING id
+	`, QuarantineTable(outboxTable), outboxTable)
+
+	requeued, err := ql.Select[int64](ctx, move, ids)
+	if err != nil {
+		return 0, fmt.Errorf("requeue quarantined rows: %w", err)
+	}
+
+	if len(requeued) == 0 {
+		return 0, nil
+	}
+
+	// forward right away instead of waiting for the sweeper
+	notify := fmt.Sprintf(`
+		SELECT pg_notify($2, json_build_object('id', id, 'key', kafka_key)::text)
+		FROM %s
+		WHERE id = ANY($1)
+	`, outboxTable)
+
+	if err := ql.Exec(ctx, notify, requeued, orDefault(channel, DefaultChannel)); err != nil {
+		return 0, fmt.Errorf("notify requeued rows: %w", err)
+	}
+
+	return len(requeued), nil
+}
+
+// DiscardQuarantined deletes the quarantined rows for good. Returns the number of
+// deleted rows.
+func DiscardQuarantined(ctx ql.TxContext, outboxTable string, ids ...int64) (int, error) {
+	stmt := fmt.Sprintf(`DELETE FROM %s WHERE id = ANY($1)`, QuarantineTable(outboxTable))
+
+	discarded, err := ql.ExecAffected(ctx, stmt, ids)
+	if err != nil {
+		return 0, fmt.Errorf("discard quarantined rows: %w", err)
+	}
+
+	return discarded, nil
+}
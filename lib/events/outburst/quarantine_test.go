@@ -0,0 +1,64 @@
+package outburst
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+func TestKafkaMessageRejectsMismatchedHeaders(t *testing.T) {
+	row := Message{ID: 7, Topic: "foobar", HeaderKeys: []string{"a", "b"}, HeaderValues: []string{"1"}}
+	if _, err := kafkaMessage(row); err == nil {
+		t.Fatalf("mismatched headers accepted")
+	}
+
+	row.HeaderValues = append(row.HeaderValues, "2")
+	row.Key = sql.NullString{String: "key-a", Valid: true}
+
+	msg, err := kafkaMessage(row)
+	if err != nil {
+		t.Fatalf("kafka message: %v", err)
+	}
+	if id, _ := msg.Opaque.(int64); id != 7 {
+		t.Fatalf("row id not carried as opaque: %v", msg.Opaque)
+	}
+	if len(msg.Headers) != 2 || string(msg.Key) != "key-a" {
+		t.Fatalf("unexpected message: %v", msg)
+	}
+}
+
+func TestCountsAsAttempt(t *testing.T) {
+	poison := []error{
+		errors.New("got 2 header keys but 1 header values"),
+		kafka.NewError(kafka.ErrMsgSizeTooLarge, "too large", false),
+		kafka.NewError(kafka.ErrUnknownTopicOrPart, "unknown topic", false),
+	}
+	for _, err := range poison {
+		if !countsAsAttempt(err) {
+			t.Errorf("%v does not count as attempt", err)
+		}
+	}
+
+	transient := []error{
+		kafka.NewError(kafka.ErrMsgTimedOut, "timed out", false),
+		kafka.NewError(kafka.ErrAllBrokersDown, "brokers down", false),
+		kafka.NewError(kafka.ErrQueueFull, "queue full", false),
+	}
+	for _, err := range transient {
+		if countsAsAttempt(err) {
+			t.Errorf("%v counts as attempt", err)
+		}
+	}
+}
+
+func TestRowErrorSurvivesWrapping(t *testing.T) {
+	err := fmt.Errorf("send: %w", &rowError{ID: 3, Err: errors.New("boom")})
+
+	var rowErr *rowError
+	if !errors.As(err, &rowErr) || rowErr.ID != 3 {
+		t.Fatalf("row error lost: %v", err)
+	}
+}
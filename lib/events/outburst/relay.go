@@ -0,0 +1,278 @@
+package outburst
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flachnetz/startup/v2/lib/clock"
+	"github.com/flachnetz/startup/v2/lib/ql"
+)
+
+// Relay is the handle of a relay started by Initialize, used by the admin page to
+// inspect and control it. Pausing and sweeping only affect the relay in this
+// process, other instances of the application keep relaying.
+type Relay struct {
+	db     outboxDB
+	status *relayStatus
+}
+
+// relays holds the *Relay of every running relay by its name.
+var relays sync.Map
+
+// Relays returns the relays running in this process, ordered by name.
+func Relays() []*Relay {
+	var result []*Relay
+
+	relays.Range(func(_, value any) bool {
+		result = append(result, value.(*Relay))
+		return true
+	})
+
+	slices.SortFunc(result, func(a, b *Relay) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+
+	return result
+}
+
+// relayByName returns the running relay with the given name, or nil.
+func relayByName(name string) *Relay {
+	if relay, ok := relays.Load(name); ok {
+		return relay.(*Relay)
+	}
+
+	return nil
+}
+
+// Name returns the name of the relay, see Options.Name.
+func (r *Relay) Name() string {
+	return r.db.name()
+}
+
+// Table returns the outbox table drained by the relay.
+func (r *Relay) Table() string {
+	return r.db.Table
+}
+
+// Channel returns the notification channel the relay listens on.
+func (r *Relay) Channel() string {
+	return r.db.channel()
+}
+
+// Paused returns true while the relay is paused.
+func (r *Relay) Paused() bool {
+	return r.status.paused.Load()
+}
+
+// Pause stops publishing rows. Notifications are still consumed, but all rows stay
+// in the outbox until the relay is resumed.
+func (r *Relay) Pause() {
+	r.status.paused.Store(true)
+}
+
+// Resume continues publishing after Pause and sweeps up the rows that were left in
+// the outbox while paused.
+func (r *Relay) Resume() {
+	r.status.paused.Store(false)
+	r.TriggerSweep()
+}
+
+// TriggerSweep asks the relay to sweep the outbox now instead of waiting for the
+// next scheduled sweep. Does nothing while the relay is paused.
+func (r *Relay) TriggerSweep() {
+	select {
+	case r.status.sweepRequests <- struct{}{}:
+	default:
+		// a sweep is already pending
+	}
+}
+
+// Requeue moves quarantined rows back into the outbox, see RequeueQuarantined.
+func (r *Relay) Requeue(ctx context.Context, ids ...int64) (int, error) {
+	return ql.InNewTransactionWithResult(ctx, r.db, func(ctx ql.TxContext) (int, error) {
+		return RequeueQuarantined(ctx, r.db.Table, r.db.channel(), ids...)
+	})
+}
+
+// RelayError is a recent failure of a relay. RowID is zero if the failure is not
+// caused by a single row.
+type RelayError struct {
+	Time  time.Time `json:"time"`
+	RowID int64     `json:"rowId,omitempty"`
+	Error string    `json:"error"`
+}
+
+// maxRecentErrors is the number of failures a relay remembers for the admin page.
+const maxRecentErrors = 20
+
+// recentErrors keeps the latest failures of a relay.
+type recentErrors struct {
+	mu     sync.Mutex
+	errors []RelayError
+}
+
+// add records a failure of the given row, or of the row that caused err. With
+// strict ordering, forwarding a row can fail on an older row of its key.
+func (r *recentErrors) add(id int64, err error) {
+	var rowErr *rowError
+	if errors.As(err, &rowErr) {
+		id = rowErr.ID
+	}
+
+	message := err.Error()
+	if len(message) > maxErrorLength {
+		message = message[:maxErrorLength]
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.errors = append(r.errors, RelayError{Time: clock.GlobalClock.Now(), RowID: id, Error: message})
+	if len(r.errors) > maxRecentErrors {
+		r.errors = slices.Delete(r.errors, 0, len(r.errors)-maxRecentErrors)
+	}
+}
+
+// list returns the recorded failures, latest first.
+func (r *recentErrors) list() []RelayError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := slices.Clone(r.errors)
+	slices.Reverse(result)
+
+	return result
+}
+
+// TopicBacklog is the part of the outbox that waits to be published to one topic.
+type TopicBacklog struct {
+	Topic string `db:"topic" json:"topic"`
+
+	// Rows that are due, and rows scheduled for later delivery.
+	Due       int64 `db:"due" json:"due"`
+	Scheduled int64 `db:"scheduled" json:"scheduled"`
+
+	// Creation time of the oldest due row, nil if no row is due.
+	Oldest *time.Time `db:"oldest" json:"oldest,omitempty"`
+}
+
+// RelayStats is a snapshot of a relay for the admin page.
+type RelayStats struct {
+	Name      string `json:"name"`
+	Table     string `json:"table"`
+	Channel   string `json:"channel"`
+	Paused    bool   `json:"paused"`
+	Listening bool   `json:"listening"`
+
+	Backlog []TopicBacklog `json:"backlog"`
+
+	// Creation time of the oldest due row over all topics, nil if no row is due.
+	Oldest *time.Time `json:"oldest,omitempty"`
+
+	// Number of rows waiting in each shard queue of the notify listener, empty
+	// while the listener is not connected.
+	WorkerQueues        []int `json:"workerQueues"`
+	WorkerQueueCapacity int   `json:"workerQueueCapacity"`
+
+	RecentErrors []RelayError `json:"recentErrors"`
+
+	// The oldest quarantined rows, up to the limit passed to Stats, and the
+	// number of all quarantined rows.
+	Quarantined     []QuarantinedRow `json:"quarantined"`
+	QuarantinedRows int64            `json:"quarantinedRows"`
+}
+
+// QuarantinedRow describes a quarantined row without its payload.
+type QuarantinedRow struct {
+	ID             int64     `json:"id"`
+	Topic          string    `json:"topic"`
+	Key            string    `json:"key,omitempty"`
+	Attempts       int       `json:"attempts"`
+	LastError      string    `json:"lastError"`
+	CreateTime     time.Time `json:"createTime"`
+	QuarantineTime time.Time `json:"quarantineTime"`
+}
+
+// Stats reads the backlog and the quarantined rows from the database and takes a
+// snapshot of the runtime state of the relay. At most quarantineLimit
+// quarantined rows are returned.
+func (r *Relay) Stats(ctx context.Context, quarantineLimit int) (RelayStats, error) {
+	stats := RelayStats{
+		Name:         r.Name(),
+		Table:        r.Table(),
+		Channel:      r.Channel(),
+		Paused:       r.Paused(),
+		Listening:    r.status.listening.Load(),
+		RecentErrors: r.status.recentErrors.list(),
+	}
+
+	if queues := r.status.queues.Load(); queues != nil {
+		for _, queue := range *queues {
+			stats.WorkerQueues = append(stats.WorkerQueues, len(queue))
+			stats.WorkerQueueCapacity = cap(queue)
+		}
+	}
+
+	err := ql.InNewTransaction(ctx, r.db, func(ctx ql.TxContext) error {
+		now := clock.GlobalClock.Now()
+
+		backlogQuery := fmt.Sprintf(`
+			SELECT
+				kafka_topic AS topic,
+				COUNT(*) FILTER (WHERE not_before IS NULL OR not_before <= $1) AS due,
+				COUNT(*) FILTER (WHERE not_before > $1) AS scheduled,
+				MIN(create_time) FILTER (WHERE not_before IS NULL OR not_before <= $1) AS oldest
+			FROM %s
+			GROUP BY kafka_topic
+			ORDER BY kafka_topic
+		`, r.db.Table)
+
+		backlog, err := ql.Select[TopicBacklog](ctx, backlogQuery, now)
+		if err != nil {
+			return fmt.Errorf("read backlog: %w", err)
+		}
+
+		stats.Backlog = backlog
+
+		for _, topic := range backlog {
+			if topic.Oldest != nil && (stats.Oldest == nil || topic.Oldest.Before(*stats.Oldest)) {
+				stats.Oldest = topic.Oldest
+			}
+		}
+
+		countQuery := fmt.Sprintf(`SELECT COUNT(*) FROM %s`, QuarantineTable(r.db.Table))
+
+		count, err := ql.Get[int64](ctx, countQuery)
+		if err != nil {
+			return fmt.Errorf("count quarantined rows: %w", err)
+		}
+
+		stats.QuarantinedRows = *count
+
+		quarantined, err := ListQuarantined(ctx, r.db.Table, quarantineLimit)
+		if err != nil {
+			return fmt.Errorf("list quarantined rows: %w", err)
+		}
+
+		for _, row := range quarantined {
+			stats.Quarantined = append(stats.Quarantined, QuarantinedRow{
+				ID:             row.ID,
+				Topic:          row.Topic,
+				Key:            row.Key.String,
+				Attempts:       row.Attempts,
+				LastError:      row.LastError,
+				CreateTime:     row.Timestamp,
+				QuarantineTime: row.QuarantineTime,
+			})
+		}
+
+		return nil
+	})
+
+	return stats, err
+}
@@ -0,0 +1,50 @@
+package outburst
+
+import (
+	"testing"
+)
+
+func TestOutboxDBDefaults(t *testing.T) {
+	db := outboxDB{Table: "outbox"}
+	if db.name() != "outbox" || db.channel() != DefaultChannel {
+		t.Fatalf("unexpected defaults: name=%q channel=%q", db.name(), db.channel())
+	}
+
+	db = outboxDB{Table: "bulk_outbox", Name: "bulk", Channel: "bulk-message"}
+	if db.name() != "bulk" || db.channel() != "bulk-message" {
+		t.Fatalf("unexpected values: name=%q channel=%q", db.name(), db.channel())
+	}
+}
+
+// Every relay reports its own state, a listening relay must not make another
+// one look healthy.
+func TestHealthCheckPerRelay(t *testing.T) {
+	primary := statusOf("test-primary")
+	bulk := statusOf("test-bulk")
+
+	if statusOf("test-primary") != primary {
+		t.Fatalf("status of a relay is not stable")
+	}
+
+	primaryCheck := healthCheck(Options{MaxBacklog: 10}, primary)
+	bulkCheck := healthCheck(Options{MaxBacklog: 10}, bulk)
+
+	primary.listening.Store(true)
+
+	if err := primaryCheck(t.Context()); err != nil {
+		t.Fatalf("listening relay is unhealthy: %v", err)
+	}
+	if err := bulkCheck(t.Context()); err == nil {
+		t.Fatalf("relay without listener is healthy")
+	}
+
+	bulk.listening.Store(true)
+	bulk.lastOutboxSize.Store(11)
+
+	if err := bulkCheck(t.Context()); err == nil {
+		t.Fatalf("backlog of the bulk relay not reported")
+	}
+	if err := primaryCheck(t.Context()); err != nil {
+		t.Fatalf("backlog of the bulk relay reported by the primary relay: %v", err)
+	}
+}
@@ -4,13 +4,17 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
 
+	"github.com/flachnetz/startup/v2/lib/pg"
 	"github.com/flachnetz/startup/v2/lib/ql"
 )
 
-// ensureOutboxTable creates the outbox table when it does not already exist.
+// ensureOutboxTable creates the outbox table and its quarantine table when they do not
+// already exist.
 func ensureOutboxTable(ctx context.Context, db outboxDB) error {
-	return ql.InNewTransaction(ctx, db, func(ctx ql.TxContext) error {
+	err := ql.InNewTransaction(ctx, db, func(ctx ql.TxContext) error {
 		createTable := fmt.Sprintf(`
 			CREATE TABLE IF NOT EXISTS %s (
 				id                  bigserial NOT NULL PRIMARY KEY,
@@ -25,6 +29,105 @@ func ensureOutboxTable(ctx context.Context, db outboxDB) error {
 			`, db.Table)
 
 		slog.InfoContext(ctx, "Create outbox table", slog.String("table", db.Table))
-		return ql.Exec(ctx, createTable)
+		if err := ql.Exec(ctx, createTable); err != nil {
+			return err
+		}
+
+		// tables created by older versions lack the attempt tracking and scheduling.
+		// ALTER TABLE locks the table exclusively, even if the columns exist.
+		if err := addMissingColumns(ctx, db.Table); err != nil {
+			return err
+		}
+
+		createQuarantine := fmt.Sprintf(`
+			CREATE TABLE IF NOT EXISTS %s (
+				id                  bigint NOT NULL PRIMARY KEY,
+				create_time         timestamp with time zone NOT NULL,
+				quarantine_time     timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+				kafka_topic         text NOT NULL,
+				kafka_key           text NULL,
+				kafka_value         BYTEA NOT NULL,
+				kafka_header_keys   text[] NOT NULL,
+				kafka_header_values text[] NOT NULL,
+
+				attempts            integer NOT NULL,
+				last_error          text NULL
+			)
+			`, QuarantineTable(db.Table))
+
+		if err := ql.Exec(ctx, createQuarantine); err != nil {
+			return fmt.Errorf("create quarantine table: %w", err)
+		}
+
+		return nil
 	})
+
+	if err != nil {
+		return err
+	}
+
+	// indexes are built concurrently outside the transaction, so starting a relay
+	// does not block the writers of the outbox table.
+	_, name, _ := strings.Cut(db.Table, ".")
+	if name == "" {
+		name = db.Table
+	}
+
+	// scheduled rows are cancelled by their key
+	err = pg.CreateIndexConcurrently(ctx, db.DB.DB, db.Table, name+"_scheduled_key",
+		"(kafka_key) WHERE not_before IS NOT NULL")
+	if err != nil {
+		return err
+	}
+
+	if db.StrictOrdering {
+		// strict ordering forwards the rows of a key in the order of their ids
+		err := pg.CreateIndexConcurrently(ctx, db.DB.DB, db.Table, name+"_key_order",
+			"(kafka_key, id) WHERE kafka_key IS NOT NULL")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addMissingColumns adds the columns of newer versions to an existing outbox table.
+func addMissingColumns(ctx ql.TxContext, table string) error {
+	columns := [][2]string{
+		{"attempts", "integer NOT NULL DEFAULT 0"},
+		{"last_error", "text NULL"},
+		{"not_before", "timestamp with time zone NULL"},
+	}
+
+	var names []string
+	for _, column := range columns {
+		names = append(names, column[0])
+	}
+
+	missing, err := pg.MissingColumns(ctx, ctx, table, names...)
+	if err != nil {
+		return err
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	var clauses []string
+	for _, column := range columns {
+		if slices.Contains(missing, column[0]) {
+			clauses = append(clauses, "ADD COLUMN IF NOT EXISTS "+column[0]+" "+column[1])
+		}
+	}
+
+	slog.InfoContext(ctx, "Add missing columns to outbox table",
+		slog.String("table", table), slog.Any("columns", missing))
+
+	if err := ql.Exec(ctx, fmt.Sprintf("ALTER TABLE %s %s", table, strings.Join(clauses, ", "))); err != nil {
+		return fmt.Errorf("add columns: %w", err)
+	}
+
+	return nil
 }
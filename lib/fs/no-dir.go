@@ -27,3 +27,8 @@ func (n NoReadDirFS) Open(name string) (fs.File, error) {
 
 	return file, nil
 }
+
+// Unwrap returns the wrapped file system, which still allows listing directories.
+func (n NoReadDirFS) Unwrap() fs.FS {
+	return n.f
+}
@@ -8,6 +8,7 @@ import (
 	"log/slog"
 	"os"
 	"reflect"
+	"sync"
 	"time"
 
 	"github.com/flachnetz/startup/v2/lib/clock"
@@ -97,6 +98,11 @@ type Service struct {
 
 	// record to send out async
 	queue chan RecordToSend
+
+	// closed to stop the async task, and by the task once it flushed
+	stopOnce sync.Once
+	stop     chan struct{}
+	stopped  chan struct{}
 }
 
 // Option customizes a Service created with New.
@@ -203,6 +203,34 @@ func TestTrackAsyncFlushesQueuedRecords(t *testing.T) {
 	})
 }
 
+func TestStopAsyncFlushesPendingRecords(t *testing.T) {
+	db := testx.NewConnection(t, "history_migrations")
+
+	testx.MustTransactErr(t, db, func(ctx ql.TxContext) error {
+		return CreateTable(ctx, "history")
+	})
+
+	captured := testx.CaptureEvents(t)
+
+	service := New(db, pgx.Identifier{"history"}, &EventSending{
+		EventSender:   captured,
+		EventCreator:  dummyEventCreator,
+		ServiceId:     "test-service",
+		WriteToOutbox: true,
+	})
+
+	service.SendAsync(t.Context())
+
+	service.Track(context.Background(), item{Value: "hello"}, GroupId{"order", "group-1"})
+
+	// stopping flushes the record without waiting for the batcher
+	require.NoError(t, service.StopAsync(t.Context()))
+	require.Len(t, testx.MockEventsGetAll[dummyEvent](t, captured), 1)
+
+	// stopping again does nothing
+	require.NoError(t, service.StopAsync(t.Context()))
+}
+
 // TestRecordsAtRoutesToLocal covers the RecordsAt paths that resolve from the
 // local table without touching Athena: no Athena config, a zero createdTime with
 // data still present locally, and a createdTime newer than the lookup threshold.
@@ -26,11 +26,32 @@ func (h *Service) SendAsync(ctx context.Context) {
 
 	// setup the queue
 	h.queue = make(chan RecordToSend, 512)
+	h.stop = make(chan struct{})
+	h.stopped = make(chan struct{})
 
 	go h.sendAsyncTask(ctx, records)
 }
 
+// StopAsync stops the background task started by SendAsync and flushes the pending
+// records. It returns once the records are flushed or ctx is done.
+func (h *Service) StopAsync(ctx context.Context) error {
+	if h.queue == nil {
+		return nil
+	}
+
+	h.stopOnce.Do(func() { close(h.stop) })
+
+	select {
+	case <-h.stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("flush history records: %w", ctx.Err())
+	}
+}
+
 func (h *Service) sendAsyncTask(ctx context.Context, records []RecordToSend) {
+	defer close(h.stopped)
+
 	b := batcher.New(256, 100*time.Millisecond)
 	for {
 		select {
@@ -39,6 +60,15 @@ func (h *Service) sendAsyncTask(ctx context.Context, records []RecordToSend) {
 			// if the context is done
 			return
 
+		case <-h.stop:
+			// take what is queued right now and flush it, even if ctx is cancelled meanwhile
+			for len(h.queue) > 0 {
+				records = append(records, <-h.queue)
+			}
+
+			h.flush(context.WithoutCancel(ctx), records)
+			return
+
 		case <-b.Await():
 			b.Reset()
 			h.flush(ctx, records)
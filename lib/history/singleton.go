@@ -66,6 +66,16 @@ func InitializeGlobal(ctx context.Context, opts Options) error {
 	return nil
 }
 
+// StopGlobal stops the background task of the global history singleton and flushes
+// the records tracked outside of a transaction, see Service.StopAsync.
+func StopGlobal(ctx context.Context) error {
+	if instance == nil {
+		return nil
+	}
+
+	return instance.StopAsync(ctx)
+}
+
 // Track uses the global history singleton.
 // You need to initialize it using InitializeGlobal first.
 func Track(ctx context.Context, item Item, groupId GroupId, groupIds ...GroupId) {
@@ -2,15 +2,19 @@ package jwt
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/flachnetz/startup/v2/lib/clock"
+	"github.com/flachnetz/startup/v2/startup_base"
 	"github.com/flachnetz/startup/v2/startup_tracing"
 	"github.com/jwx-go/jwkfetch/v4"
 	"github.com/lestrrat-go/httprc/v3"
+	"github.com/lestrrat-go/jwx/v4/jwk"
 	"github.com/lestrrat-go/jwx/v4/jwt"
 )
 
@@ -20,10 +24,17 @@ func NewBuilder() *jwt.Builder {
 	return jwt.NewBuilder()
 }
 
+// MaxKeySetAge is the time after which the health check of a TokenVerifier
+// refreshes the keyset and fails if that is not possible.
+var MaxKeySetAge = 5 * time.Minute
+
 type TokenVerifier struct {
 	Close context.CancelFunc
 	cache *jwkfetch.Cache
 	url   string
+
+	// unix nanos of the last successful refresh of the keyset by the health check
+	refreshed atomic.Int64
 }
 
 func NewTokenVerifier(ctx context.Context, url string) (*TokenVerifier, error) {
@@ -66,9 +77,44 @@ func NewTokenVerifier(ctx context.Context, url string) (*TokenVerifier, error) {
 		url:   url,
 	}
 
+	v.refreshed.Store(time.Now().UnixNano())
+
+	startup_base.RegisterHealthCheck(startup_base.HealthCheck{
+		Name:  "jwks:" + url,
+		Check: v.HealthCheck,
+	})
+
 	return v, nil
 }
 
+// HealthCheck fails if the keyset is empty or could not be refreshed within MaxKeySetAge.
+// The cache keeps serving the last keyset if refreshing in the background fails, so
+// once the keyset is older than MaxKeySetAge, it is refreshed explicitly.
+func (v *TokenVerifier) HealthCheck(ctx context.Context) error {
+	var keySet jwk.Set
+	var err error
+
+	if age := time.Since(time.Unix(0, v.refreshed.Load())); age > MaxKeySetAge {
+		keySet, err = v.cache.Refresh(ctx, v.url)
+		if err != nil {
+			return fmt.Errorf("refresh jwt keyset, last refreshed %s ago: %w", age.Truncate(time.Second), err)
+		}
+
+		v.refreshed.Store(time.Now().UnixNano())
+	} else {
+		keySet, err = v.cache.Lookup(ctx, v.url)
+		if err != nil {
+			return fmt.Errorf("get jwt keyset: %w", err)
+		}
+	}
+
+	if keySet.Len() == 0 {
+		return errors.New("jwt keyset is empty")
+	}
+
+	return nil
+}
+
 func (v *TokenVerifier) Verify(ctx context.Context, rawToken string) (jwt.Token, error) {
 	keySet, err := v.cache.Lookup(ctx, v.url)
 	if err != nil {
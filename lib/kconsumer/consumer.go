@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"log/slog"
 	"runtime/debug"
+	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/flachnetz/startup/v2/startup_base"
 	sl "github.com/flachnetz/startup/v2/startup_logging"
 	"github.com/flachnetz/startup/v2/startup_tracing"
 	"go.opentelemetry.io/otel/trace"
@@ -19,6 +21,10 @@ import (
 // it checks the context for cancellation.
 var DefaultPollTimeout = 100 * time.Millisecond
 
+// DefaultStuckTimeout is the default time after which a consumer that did not
+// poll for new messages is reported as stuck by its health check.
+var DefaultStuckTimeout = 2 * time.Minute
+
 // HandleMessage is the user-provided function called for each consumed message.
 // It receives the raw *kafka.Message so the caller has access to key, headers,
 // and partition/offset metadata.
@@ -37,9 +43,51 @@ func (h HandleMessage) LogValue() slog.Value {
 // Consume shuts the whole consumer down, returning that error. A panic is
 // recovered and reported like any other handler failure, so it never crashes
 // the process or deadlocks the remaining partition workers.
+//
+// While consuming, a readiness check is registered with the
+// startup_base.DefaultHealthRegistry, see HealthCheck. It is removed once Consume returns.
 type PartitionConsumer struct {
 	Topics   []string
 	Consumer *kafka.Consumer
+
+	// Time without polling after which the consumer is reported as stuck.
+	// Defaults to DefaultStuckTimeout.
+	StuckTimeout time.Duration
+
+	// set once the consumer joined the group and got its first assignment
+	joined atomic.Bool
+
+	// unix nanos of the last poll for messages
+	lastPoll atomic.Int64
+
+	// the current call of RunConsumer, stopped by the lifecycle hook
+	run consumerRun
+}
+
+// HealthCheck fails if the consumer never joined its group or has not polled for
+// new messages within StuckTimeout, e.g. because a handler blocks. A consumer
+// without partitions is healthy, as group members are idle if there are more
+// members than partitions, and briefly during every rebalance.
+func (c *PartitionConsumer) HealthCheck(ctx context.Context) error {
+	lastPoll := c.lastPoll.Load()
+	if lastPoll == 0 {
+		return errors.New("consumer not running")
+	}
+
+	stuckTimeout := c.StuckTimeout
+	if stuckTimeout <= 0 {
+		stuckTimeout = DefaultStuckTimeout
+	}
+
+	if since := time.Since(time.Unix(0, lastPoll)); since > stuckTimeout {
+		return fmt.Errorf("consumer stuck, last poll %s ago", since.Truncate(time.Second))
+	}
+
+	if !c.joined.Load() {
+		return errors.New("consumer did not join the group yet")
+	}
+
+	return nil
 }
 
 type partitionWorker struct {
@@ -87,6 +135,7 @@ func (c *PartitionConsumer) Consume(ctx context.Context, handle HandleMessage) e
 			// Should already be empty after the preceding revoke, but drain
 			// defensively in case an assignment arrives without one.
 			workers.DrainAll()
+			c.joined.Store(true)
 		}
 
 		return nil
@@ -101,6 +150,15 @@ func (c *PartitionConsumer) Consume(ctx context.Context, handle HandleMessage) e
 
 	defer workers.Shutdown()
 
+	healthCheckName := "kafka-consumer:" + strings.Join(c.Topics, ",")
+
+	startup_base.RegisterHealthCheck(startup_base.HealthCheck{
+		Name:  healthCheckName,
+		Check: c.HealthCheck,
+	})
+
+	defer startup_base.UnregisterHealthCheck(healthCheckName)
+
 	slog.InfoContext(ctx, "Partition consumer started", slog.Any("topics", c.Topics))
 
 	lastStored := time.Now()
@@ -130,6 +188,8 @@ func (c *PartitionConsumer) Consume(ctx context.Context, handle HandleMessage) e
 			lastStored = time.Now()
 		}
 
+		c.lastPoll.Store(time.Now().UnixNano())
+
 		msg, err := c.Consumer.ReadMessage(DefaultPollTimeout)
 		if err != nil {
 			if ke, ok := errors.AsType[kafka.Error](err); ok {
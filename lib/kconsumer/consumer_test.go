@@ -489,3 +489,37 @@ func messageOf(topic string, partition int, payload string) *kafka.Message {
 		Value: []byte(payload),
 	}
 }
+
+func TestPartitionConsumer_HealthCheck(t *testing.T) {
+	var consumer PartitionConsumer
+	require.ErrorContains(t, consumer.HealthCheck(t.Context()), "not running")
+
+	consumer.lastPoll.Store(time.Now().UnixNano())
+	require.ErrorContains(t, consumer.HealthCheck(t.Context()), "did not join")
+
+	// an idle member of the group without partitions is healthy
+	consumer.joined.Store(true)
+	require.NoError(t, consumer.HealthCheck(t.Context()))
+
+	consumer.lastPoll.Store(time.Now().Add(-2 * DefaultStuckTimeout).UnixNano())
+	require.ErrorContains(t, consumer.HealthCheck(t.Context()), "stuck")
+}
+
+func TestConsumerRun_StopsCurrentRun(t *testing.T) {
+	var run consumerRun
+
+	// a previous run that already finished
+	_, cancelFirst := context.WithCancel(t.Context())
+	close(run.start(cancelFirst))
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := run.start(cancel)
+
+	go func() {
+		<-ctx.Done()
+		close(done)
+	}()
+
+	require.NoError(t, run.stop(t.Context()))
+	require.Error(t, ctx.Err())
+}
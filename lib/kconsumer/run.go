@@ -4,8 +4,10 @@ import (
 	"context"
 	"log/slog"
 	"runtime/debug"
+	"sync"
 	"time"
 
+	"github.com/flachnetz/startup/v2/startup_base"
 	sl "github.com/flachnetz/startup/v2/startup_logging"
 )
 
@@ -14,9 +16,18 @@ import (
 // recovered, its stack is printed, and the consumer is restarted after a short
 // delay. The loop only stops when ctx is canceled or the underlying kafka
 // consumer is closed.
+//
+// The consumer is registered with the startup_base.DefaultLifecycle once and
+// stopped on shutdown before the producers and the database are closed.
 func RunConsumer(ctx context.Context, partitionConsumer *PartitionConsumer, handler HandleMessage) {
 	log := sl.LoggerOf(ctx)
 
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := partitionConsumer.run.start(cancel)
+	defer close(done)
+
 	for {
 		func() {
 			defer func() {
@@ -48,3 +59,60 @@ func RunConsumer(ctx context.Context, partitionConsumer *PartitionConsumer, hand
 		time.Sleep(5 * time.Second)
 	}
 }
+
+// consumerRun tracks the current call of RunConsumer, so the lifecycle hook is only
+// registered once per consumer, even if RunConsumer is called again.
+type consumerRun struct {
+	hookOnce sync.Once
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// start makes cancel the current run and returns the channel to close once the run
+// finished.
+func (r *consumerRun) start(cancel context.CancelFunc) chan struct{} {
+	r.hookOnce.Do(func() {
+		startup_base.RegisterHook(startup_base.Hook{
+			Name: startup_base.HookKafkaConsumer,
+			DependsOn: []string{
+				startup_base.HookEventSender,
+				startup_base.HookKafkaProducer,
+				startup_base.HookOutburst,
+				startup_base.HookTracing,
+				startup_base.HookPostgres,
+			},
+			OnStop: r.stop,
+		})
+	})
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cancel = cancel
+	r.done = make(chan struct{})
+
+	return r.done
+}
+
+// stop cancels the current run and waits for the consumer to drain its workers and
+// commit the offsets.
+func (r *consumerRun) stop(ctx context.Context) error {
+	r.mu.Lock()
+	cancel, done := r.cancel, r.done
+	r.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,113 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Querier is implemented by *sql.DB and *sql.Tx, their sqlx counterparts and ql.TxContext.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// MissingColumns returns the columns that the table does not have. The table name
+// might be schema qualified, otherwise it is looked up in the current schema.
+//
+// Use it to only run an ALTER TABLE if required: even an ADD COLUMN IF NOT EXISTS
+// takes an ACCESS EXCLUSIVE lock on the table, if the column exists or not.
+func MissingColumns(ctx context.Context, db Querier, table string, columns ...string) ([]string, error) {
+	schema, name := splitTableName(table)
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT column_name FROM information_schema.columns
+		WHERE table_schema = COALESCE(NULLIF($1, ''), current_schema()) AND table_name = $2
+		`, schema, name)
+	if err != nil {
+		return nil, fmt.Errorf("query columns of %q: %w", table, err)
+	}
+
+	defer rows.Close()
+
+	existing := map[string]bool{}
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, fmt.Errorf("scan column of %q: %w", table, err)
+		}
+
+		existing[column] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query columns of %q: %w", table, err)
+	}
+
+	var missing []string
+	for _, column := range columns {
+		if !existing[column] {
+			missing = append(missing, column)
+		}
+	}
+
+	return missing, nil
+}
+
+// CreateIndexConcurrently creates the index on table using CREATE INDEX CONCURRENTLY,
+// if it does not exist yet. The definition is the part after the table name, e.g.
+// "(kafka_key) WHERE not_before IS NOT NULL". The index is created in the schema of the
+// table, so index must not be schema qualified.
+//
+// Building the index concurrently does not block writes to the table, but can not run
+// in a transaction. If the build fails, postgres leaves an invalid index behind. It is
+// reported, but not dropped, as it might also belong to a build that is still running.
+func CreateIndexConcurrently(ctx context.Context, db *sql.DB, table, index, definition string) error {
+	schema, _ := splitTableName(table)
+
+	var valid bool
+	err := db.QueryRowContext(ctx, `
+		SELECT idx.indisvalid FROM pg_index idx
+			JOIN pg_class cls ON cls.oid = idx.indexrelid
+			JOIN pg_namespace ns ON ns.oid = cls.relnamespace
+		WHERE ns.nspname = COALESCE(NULLIF($1, ''), current_schema()) AND cls.relname = $2
+		`, schema, strings.ToLower(index)).Scan(&valid)
+
+	switch {
+	case err == nil && valid:
+		return nil
+
+	case err == nil:
+		slog.WarnContext(ctx, "Index is invalid, drop it to build it again",
+			slog.String("table", table), slog.String("index", index))
+
+		return nil
+
+	case !errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("lookup index %q: %w", index, err)
+	}
+
+	slog.InfoContext(ctx, "Create index", slog.String("table", table), slog.String("index", index))
+
+	stmt := fmt.Sprintf("CREATE INDEX CONCURRENTLY IF NOT EXISTS %s ON %s %s", index, table, definition)
+	if _, err := db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("create index %q: %w", index, err)
+	}
+
+	return nil
+}
+
+// splitTableName splits an unquoted, optionally schema qualified table name. Names are
+// folded to lower case, as postgres does for unquoted identifiers.
+func splitTableName(table string) (schema, name string) {
+	table = strings.ToLower(table)
+
+	schema, name, ok := strings.Cut(table, ".")
+	if !ok {
+		return "", table
+	}
+
+	return schema, name
+}
@@ -0,0 +1,228 @@
+package ql
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	pgxstd "github.com/jackc/pgx/v5/stdlib"
+)
+
+// maxParameters is the maximum number of parameters postgres accepts in a single statement.
+const maxParameters = 65535
+
+// InsertOption configures InsertBatch.
+type InsertOption func(*insertOptions)
+
+type insertOptions struct {
+	onConflict string
+	omit       []string
+	batchSize  int
+}
+
+// OnConflict adds an ON CONFLICT clause to the insert statements,
+// e.g. OnConflict("(id) DO NOTHING").
+func OnConflict(clause string) InsertOption {
+	return func(opts *insertOptions) {
+		opts.onConflict = clause
+	}
+}
+
+// OmitColumns does not insert the given columns, e.g. to use the default value of a
+// serial primary key.
+func OmitColumns(columns ...string) InsertOption {
+	return func(opts *insertOptions) {
+		opts.omit = append(opts.omit, columns...)
+	}
+}
+
+// BatchSize limits the number of rows per statement. The number of rows is always
+// limited so that a statement does not exceed the parameter limit of postgres.
+func BatchSize(rows int) InsertOption {
+	return func(opts *insertOptions) {
+		opts.batchSize = rows
+	}
+}
+
+// InsertBatch inserts the rows into the table using multi-row INSERT statements. The columns
+// are taken from the `db` tags of the struct T, like sqlx does when scanning. Fields without
+// a tag use the lower case field name, fields tagged with `db:"-"` are ignored.
+//
+// Returns the number of inserted rows, which might be less than the number of rows
+// if an ON CONFLICT clause skipped some of them.
+func InsertBatch[T any](ctx TxContext, table pgx.Identifier, rows []T, opts ...InsertOption) (int, error) {
+	var options insertOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	columns, err := insertColumnsOf(reflect.TypeFor[T]())
+	if err != nil {
+		return 0, err
+	}
+
+	columns = slices.DeleteFunc(columns, func(c insertColumn) bool {
+		return slices.Contains(options.omit, c.name)
+	})
+
+	if len(columns) == 0 {
+		return 0, errors.New("insert batch: no columns to insert")
+	}
+
+	chunkSize := maxParameters / len(columns)
+	if options.batchSize > 0 {
+		chunkSize = min(chunkSize, options.batchSize)
+	}
+
+	var inserted int
+
+	for chunk := range slices.Chunk(rows, chunkSize) {
+		stmt, args := buildInsert(table, columns, options.onConflict, chunk)
+
+		affected, err := ExecAffected(ctx, stmt, args...)
+		if err != nil {
+			return inserted, fmt.Errorf("insert batch into %s: %w", table.Sanitize(), err)
+		}
+
+		inserted += affected
+	}
+
+	return inserted, nil
+}
+
+type insertColumn struct {
+	name  string
+	index []int
+}
+
+// insertColumnsOf returns the columns of a struct type, as mapped by sqlx.
+func insertColumnsOf(typ reflect.Type) ([]insertColumn, error) {
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("insert batch: expected a struct, got %s", typ)
+	}
+
+	var columns []insertColumn
+
+	for _, field := range reflect.VisibleFields(typ) {
+		if !field.IsExported() || len(field.Index) > 1 && !isEmbeddedPath(typ, field.Index) {
+			continue
+		}
+
+		tag := field.Tag.Get("db")
+		if tag == "-" {
+			continue
+		}
+
+		// embedded structs without a tag contribute their fields
+		if field.Anonymous && tag == "" && field.Type.Kind() == reflect.Struct {
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		columns = append(columns, insertColumn{name: name, index: field.Index})
+	}
+
+	return columns, nil
+}
+
+// isEmbeddedPath returns true if all fields on the path to a nested field are
+// untagged embedded structs.
+func isEmbeddedPath(typ reflect.Type, index []int) bool {
+	for _, idx := range index[:len(index)-1] {
+		field := typ.Field(idx)
+		if !field.Anonymous || field.Tag.Get("db") != "" || field.Type.Kind() != reflect.Struct {
+			return false
+		}
+
+		typ = field.Type
+	}
+
+	return true
+}
+
+func buildInsert[T any](table pgx.Identifier, columns []insertColumn, onConflict string, rows []T) (string, []any) {
+	var b strings.Builder
+
+	b.WriteString("INSERT INTO ")
+	b.WriteString(table.Sanitize())
+	b.WriteString(" (")
+
+	for idx, column := range columns {
+		if idx > 0 {
+			b.WriteString(", ")
+		}
+
+		b.WriteString(pgx.Identifier{column.name}.Sanitize())
+	}
+
+	b.WriteString(") VALUES ")
+
+	args := make([]any, 0, len(rows)*len(columns))
+
+	for rowIdx, row := range rows {
+		if rowIdx > 0 {
+			b.WriteString(", ")
+		}
+
+		value := reflect.ValueOf(row)
+
+		b.WriteByte('(')
+		for idx, column := range columns {
+			if idx > 0 {
+				b.WriteString(", ")
+			}
+
+			args = append(args, value.FieldByIndex(column.index).Interface())
+
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(len(args)))
+		}
+		b.WriteByte(')')
+	}
+
+	if onConflict != "" {
+		b.WriteString(" ON CONFLICT ")
+		b.WriteString(onConflict)
+	}
+
+	return b.String(), args
+}
+
+// CopyFrom copies the rows into the table using the postgres COPY protocol, which is the
+// fastest way to insert a lot of rows. The copy runs within the transaction of the context.
+// It requires a transaction started by this package on a pgx connection pool.
+//
+// Use pgx.CopyFromRows or pgx.CopyFromSlice to create the source.
+func CopyFrom(ctx TxContext, table pgx.Identifier, columns []string, rows pgx.CopyFromSource) (int64, error) {
+	txCtx, ok := ctx.(*txContext)
+	if !ok || txCtx.conn == nil {
+		return 0, errors.New("copy from: connection of the transaction is not known")
+	}
+
+	var copied int64
+
+	err := txCtx.conn.Raw(func(driverConn any) error {
+		conn, ok := driverConn.(*pgxstd.Conn)
+		if !ok {
+			return fmt.Errorf("copy from: expected a pgx connection, got %T", driverConn)
+		}
+
+		var err error
+		copied, err = conn.Conn().CopyFrom(ctx, table, columns, rows)
+		return err
+	})
+
+	if err != nil {
+		return copied, fmt.Errorf("copy into %s: %w", table.Sanitize(), err)
+	}
+
+	return copied, nil
+}
@@ -0,0 +1,105 @@
+package ql
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+)
+
+type batchBase struct {
+	Id int64 `db:"id"`
+}
+
+type batchRow struct {
+	batchBase
+
+	Name     string `db:"name"`
+	Quantity int
+	Ignored  string `db:"-"`
+	hidden   string
+}
+
+func TestInsertBatch(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	rows := []batchRow{
+		{batchBase: batchBase{Id: 1}, Name: "a", Quantity: 10, hidden: "x"},
+		{batchBase: batchBase{Id: 2}, Name: "b", Quantity: 20},
+		{batchBase: batchBase{Id: 3}, Name: "c", Quantity: 30},
+	}
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		inserted, err := InsertBatch(ctx, pgx.Identifier{"shop", "items"}, rows, OnConflict("(id) DO NOTHING"), BatchSize(2))
+		require.Equal(t, 2, inserted, "fake database reports one affected row per statement")
+		return err
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"BEGIN",
+		`INSERT INTO "shop"."items" ("id", "name", "quantity") VALUES ($1, $2, $3), ($4, $5, $6) ON CONFLICT (id) DO NOTHING [1] [a] [10] [2] [b] [20]`,
+		`INSERT INTO "shop"."items" ("id", "name", "quantity") VALUES ($1, $2, $3) ON CONFLICT (id) DO NOTHING [3] [c] [30]`,
+		"COMMIT",
+	}, fake.Statements())
+}
+
+func TestInsertBatchOmitColumns(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		_, err := InsertBatch(ctx, pgx.Identifier{"items"}, []batchRow{{Name: "a"}}, OmitColumns("id", "quantity"))
+		return err
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, `INSERT INTO "items" ("name") VALUES ($1) [a]`, fake.Statements()[1])
+}
+
+func TestInsertBatchRespectsParameterLimit(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	rows := make([]batchRow, 50_000)
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		_, err := InsertBatch(ctx, pgx.Identifier{"items"}, rows)
+		return err
+	})
+
+	require.NoError(t, err)
+
+	statements := fake.Statements()
+	require.Len(t, statements, 2+3)
+
+	// 65535 parameters allow 21845 rows with three columns each
+	for _, stmt := range statements[1:3] {
+		require.Contains(t, stmt, fmt.Sprintf("$%d)", maxParameters))
+		require.NotContains(t, stmt, fmt.Sprintf("$%d)", maxParameters+1))
+	}
+
+	require.True(t, strings.HasPrefix(statements[3], "INSERT"))
+}
+
+func TestInsertBatchRequiresStruct(t *testing.T) {
+	_, db := newFakeDB(t)
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		_, err := InsertBatch(ctx, pgx.Identifier{"items"}, []int{1, 2})
+		return err
+	})
+
+	require.ErrorContains(t, err, "expected a struct")
+}
+
+func TestCopyFromRequiresPgxConnection(t *testing.T) {
+	_, db := newFakeDB(t)
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		_, err := CopyFrom(ctx, pgx.Identifier{"items"}, []string{"id"}, pgx.CopyFromRows([][]any{{1}}))
+		return err
+	})
+
+	require.ErrorContains(t, err, "expected a pgx connection")
+}
@@ -2,20 +2,33 @@ package ql
 
 import (
 	"context"
+	"errors"
 
 	"github.com/jmoiron/sqlx"
 )
 
 type txContextKey struct{}
 
-func newTxContext(ctx context.Context, tx *sqlx.Tx, hooks *hooks) TxContext {
-	return &txContext{ctx, tx, hooks}
+func newTxContext(ctx context.Context, tx *sqlx.Tx, conn *sqlx.Conn, hooks *hooks, options *txOptions) *txContext {
+	return &txContext{Context: ctx, Tx: tx, conn: conn, hooks: hooks, options: options, savepoints: new(int)}
 }
 
 type txContext struct {
 	context.Context
 	*sqlx.Tx
 	*hooks
+
+	// the connection the transaction runs on, if known
+	conn *sqlx.Conn
+
+	// the options the transaction was started with
+	options *txOptions
+
+	// number of savepoints created in the transaction, shared with all nested contexts
+	savepoints *int
+
+	// the savepoint this context belongs to, nil outside a savepoint
+	savepoint *Savepoint
 }
 
 func (c *txContext) WithContext(ctx context.Context) TxContext {
@@ -33,11 +46,19 @@ func (c *txContext) Value(key any) any {
 }
 
 func (c *txContext) CommitAndChain() error {
+	if c.savepoint != nil {
+		return errors.New("commit and chain is not supported within a savepoint")
+	}
+
+	if err := c.runBeforeCommit(c); err != nil {
+		return err
+	}
+
 	if err := Exec(c, "COMMIT AND CHAIN"); err != nil {
 		return err
 	}
 
-	c.RunOnCommit()
+	c.runCommitted(c)
 
 	return nil
 }
@@ -16,11 +16,31 @@ type Tx interface {
 	sqlx.ExtContext
 }
 
+// Hooks run code at the end of a transaction. Hooks of the same kind run in
+// registration order, except OnRollback hooks which run in reverse order.
+// A panicking hook is logged and does not prevent the other hooks from running.
+//
+// On commit, the order is: BeforeCommit, COMMIT, OnCommit, AfterCompletion(true).
+// On rollback, the order is: ROLLBACK, OnRollback, AfterCompletion(false).
 type Hooks interface {
 	// OnCommit schedules some side effect that is only run if the transaction
 	// commits successfully. The Action is run after the transaction is committed and must
 	// not access the database again.
 	OnCommit(action Action)
+
+	// OnRollback schedules an Action that is only run if the transaction is rolled back,
+	// e.g. to release resources reserved during the transaction. The Action must not
+	// access the database again.
+	OnRollback(action Action)
+
+	// BeforeCommit schedules a check that runs right before the transaction is committed.
+	// The check can still access the database. If it returns an error, the transaction
+	// is rolled back and the error is returned to the caller.
+	BeforeCommit(check func() error)
+
+	// AfterCompletion schedules an action that runs after the transaction was either
+	// committed or rolled back.
+	AfterCompletion(action func(committed bool))
 }
 
 type TxContext interface {
@@ -32,9 +52,15 @@ type TxContext interface {
 	// WithContext returns a new TxContext with the given "real" context.
 	WithContext(ctx context.Context) TxContext
 
-	// CommitAndChain performs a commit, runs all OnCommit hooks and creates a new transaction
-	// using the postgres `COMMIT AND CHAIN` command.
+	// CommitAndChain performs a commit, runs all hooks and creates a new transaction
+	// using the postgres `COMMIT AND CHAIN` command. Hooks registered afterward belong
+	// to the new transaction.
 	CommitAndChain() error
+
+	// Savepoint creates a savepoint within the transaction. Work done using the context
+	// of the savepoint can be rolled back without affecting the rest of the transaction.
+	// See InNestedTransaction for a simpler way to use savepoints.
+	Savepoint() (*Savepoint, error)
 }
 
 // WithTimeout is a wrapper around context.WithTimeout
@@ -0,0 +1,178 @@
+package ql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// fakeDB is a database driver that records all statements. It returns no rows
+// unless configured otherwise and fails statements as configured.
+type fakeDB struct {
+	mu         sync.Mutex
+	statements []string
+
+	// fail returns an error to fail the statement with, or nil.
+	fail func(query string) error
+
+	// rows returns the columns and rows to return for a query.
+	rows func(query string, args []driver.NamedValue) ([]string, [][]driver.Value)
+}
+
+func newFakeDB(t *testing.T) (*fakeDB, *sqlx.DB) {
+	fake := &fakeDB{}
+
+	db := sqlx.NewDb(sql.OpenDB(fake), "postgres")
+	t.Cleanup(func() { _ = db.Close() })
+
+	return fake, db
+}
+
+// Statements returns all recorded statements.
+func (f *fakeDB) Statements() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string(nil), f.statements...)
+}
+
+func (f *fakeDB) record(query string) error {
+	f.mu.Lock()
+	f.statements = append(f.statements, query)
+	fail := f.fail
+	f.mu.Unlock()
+
+	if fail != nil {
+		return fail(query)
+	}
+
+	return nil
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{db: f}, nil
+}
+
+func (f *fakeDB) Driver() driver.Driver {
+	return nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, driver.ErrSkip
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	query := "BEGIN"
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) {
+		query += " ISOLATION LEVEL " + sql.IsolationLevel(opts.Isolation).String()
+	}
+
+	if opts.ReadOnly {
+		query += " READ ONLY"
+	}
+
+	if err := c.db.record(query); err != nil {
+		return nil, err
+	}
+
+	return fakeTx{db: c.db}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.db.record(withArgs(query, args)); err != nil {
+		return nil, err
+	}
+
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.db.record(query); err != nil {
+		return nil, err
+	}
+
+	rows := &fakeRows{}
+	if c.db.rows != nil {
+		rows.columns, rows.values = c.db.rows(query, args)
+	}
+
+	return rows, nil
+}
+
+type fakeTx struct {
+	db *fakeDB
+}
+
+func (tx fakeTx) Commit() error {
+	return tx.db.record("COMMIT")
+}
+
+func (tx fakeTx) Rollback() error {
+	return tx.db.record("ROLLBACK")
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+
+	return nil
+}
+
+// withArgs appends the arguments of the statement to the query, if any.
+func withArgs(query string, args []driver.NamedValue) string {
+	var b strings.Builder
+	b.WriteString(query)
+
+	for _, arg := range args {
+		_, _ = fmt.Fprintf(&b, " [%v]", arg.Value)
+	}
+
+	return b.String()
+}
+
+// failOn fails all statements starting with the given prefix.
+func failOn(prefix string, err error) func(query string) error {
+	return func(query string) error {
+		if strings.HasPrefix(query, prefix) {
+			return err
+		}
+
+		return nil
+	}
+}
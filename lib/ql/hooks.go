@@ -1,17 +1,108 @@
 package ql
 
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"slices"
+
+	sl "github.com/flachnetz/startup/v2/startup_logging"
+)
+
 type hooks struct {
-	onCommit []Action
+	beforeCommit    []func() error
+	onCommit        []Action
+	onRollback      []Action
+	afterCompletion []func(committed bool)
 }
 
 func (oc *hooks) OnCommit(action Action) {
 	oc.onCommit = append(oc.onCommit, action)
 }
 
-func (oc *hooks) RunOnCommit() {
+func (oc *hooks) OnRollback(action Action) {
+	oc.onRollback = append(oc.onRollback, action)
+}
+
+func (oc *hooks) BeforeCommit(check func() error) {
+	oc.beforeCommit = append(oc.beforeCommit, check)
+}
+
+func (oc *hooks) AfterCompletion(action func(committed bool)) {
+	oc.afterCompletion = append(oc.afterCompletion, action)
+}
+
+// empty returns true if no hook with side effects after the transaction was registered.
+func (oc *hooks) empty() bool {
+	return len(oc.onCommit) == 0 && len(oc.onRollback) == 0 && len(oc.afterCompletion) == 0
+}
+
+// runBeforeCommit runs the BeforeCommit hooks in registration order and
+// stops at the first failing hook. Hooks may register further hooks.
+func (oc *hooks) runBeforeCommit(ctx context.Context) error {
+	for idx := 0; idx < len(oc.beforeCommit); idx++ {
+		if err := runHook(ctx, "BeforeCommit", oc.beforeCommit[idx]); err != nil {
+			return fmt.Errorf("before commit: %w", err)
+		}
+	}
+
+	oc.beforeCommit = nil
+
+	return nil
+}
+
+// runCommitted runs the OnCommit hooks followed by the AfterCompletion hooks,
+// each in registration order. All hooks are cleared afterward.
+func (oc *hooks) runCommitted(ctx context.Context) {
 	for _, action := range oc.onCommit {
-		action()
+		_ = runHook(ctx, "OnCommit", func() error { action(); return nil })
 	}
 
-	oc.onCommit = oc.onCommit[:0]
+	oc.runAfterCompletion(ctx, true)
+}
+
+// runRolledBack runs the OnRollback hooks in reverse registration order, like
+// deferred functions, followed by the AfterCompletion hooks. All hooks are cleared afterward.
+func (oc *hooks) runRolledBack(ctx context.Context) {
+	for _, action := range slices.Backward(oc.onRollback) {
+		_ = runHook(ctx, "OnRollback", func() error { action(); return nil })
+	}
+
+	oc.runAfterCompletion(ctx, false)
+}
+
+func (oc *hooks) runAfterCompletion(ctx context.Context, committed bool) {
+	for _, action := range oc.afterCompletion {
+		_ = runHook(ctx, "AfterCompletion", func() error { action(committed); return nil })
+	}
+
+	*oc = hooks{}
+}
+
+// runHook runs a single hook. A panic in the hook is logged and returned as error,
+// so it does not affect other hooks.
+func runHook(ctx context.Context, kind string, hook func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic in %s hook: %v", kind, r)
+
+			sl.LoggerOf(ctx).ErrorContext(ctx, "Transaction hook panicked",
+				slog.String("hook", kind),
+				slog.Any("panic", r),
+				slog.String("stack", string(debug.Stack())))
+		}
+	}()
+
+	return hook()
+}
+
+// releaseInto moves the hooks to the parent hooks, once a savepoint is released.
+func (oc *hooks) releaseInto(parent *hooks) {
+	parent.beforeCommit = append(parent.beforeCommit, oc.beforeCommit...)
+	parent.onCommit = append(parent.onCommit, oc.onCommit...)
+	parent.onRollback = append(parent.onRollback, oc.onRollback...)
+	parent.afterCompletion = append(parent.afterCompletion, oc.afterCompletion...)
+
+	*oc = hooks{}
 }
@@ -0,0 +1,171 @@
+package ql
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHooksOrderOnCommit(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	var calls []string
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		ctx.AfterCompletion(func(committed bool) { calls = append(calls, "after-completion", boolString(committed)) })
+		ctx.OnRollback(func() { calls = append(calls, "on-rollback") })
+		ctx.OnCommit(func() { calls = append(calls, "on-commit-1") })
+		ctx.OnCommit(func() { calls = append(calls, "on-commit-2") })
+		ctx.BeforeCommit(func() error {
+			calls = append(calls, "before-commit")
+			return Exec(ctx, "UPDATE")
+		})
+
+		return nil
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, []string{"before-commit", "on-commit-1", "on-commit-2", "after-completion", "true"}, calls)
+	require.Equal(t, []string{"BEGIN", "UPDATE", "COMMIT"}, fake.Statements())
+}
+
+func TestHooksOrderOnRollback(t *testing.T) {
+	_, db := newFakeDB(t)
+
+	errFailed := errors.New("failed")
+
+	var calls []string
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		ctx.AfterCompletion(func(committed bool) { calls = append(calls, "after-completion", boolString(committed)) })
+		ctx.OnRollback(func() { calls = append(calls, "on-rollback-1") })
+		ctx.OnRollback(func() { calls = append(calls, "on-rollback-2") })
+		ctx.OnCommit(func() { calls = append(calls, "on-commit") })
+		ctx.BeforeCommit(func() error { calls = append(calls, "before-commit"); return nil })
+
+		return errFailed
+	})
+
+	require.ErrorIs(t, err, errFailed)
+	require.Equal(t, []string{"on-rollback-2", "on-rollback-1", "after-completion", "false"}, calls)
+}
+
+func TestBeforeCommitAbortsCommit(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	errVeto := errors.New("veto")
+
+	var rolledBack bool
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		ctx.OnRollback(func() { rolledBack = true })
+		ctx.OnCommit(func() { t.Fatal("must not commit") })
+		ctx.BeforeCommit(func() error { return errVeto })
+		return nil
+	})
+
+	require.ErrorIs(t, err, errVeto)
+	require.True(t, rolledBack)
+	require.Equal(t, []string{"BEGIN", "ROLLBACK"}, fake.Statements())
+}
+
+func TestHooksPanicIsolation(t *testing.T) {
+	_, db := newFakeDB(t)
+
+	var calls []string
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		ctx.OnCommit(func() { panic("first hook") })
+		ctx.OnCommit(func() { calls = append(calls, "on-commit") })
+		ctx.AfterCompletion(func(bool) { calls = append(calls, "after-completion") })
+		return nil
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, []string{"on-commit", "after-completion"}, calls)
+
+	// a panic in BeforeCommit aborts the commit
+	err = InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		ctx.OnCommit(func() { t.Fatal("must not commit") })
+		ctx.BeforeCommit(func() error { panic("veto") })
+		return nil
+	})
+
+	require.ErrorContains(t, err, "panic in BeforeCommit hook: veto")
+}
+
+func TestHooksRunOnPanicInTransaction(t *testing.T) {
+	_, db := newFakeDB(t)
+
+	var rolledBack bool
+
+	require.Panics(t, func() {
+		_ = InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+			ctx.OnRollback(func() { rolledBack = true })
+			panic("user code")
+		})
+	})
+
+	require.True(t, rolledBack)
+}
+
+func TestHooksWithCommitAndChain(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	var calls []string
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		ctx.BeforeCommit(func() error { calls = append(calls, "before-commit-1"); return nil })
+		ctx.OnCommit(func() { calls = append(calls, "on-commit-1") })
+		ctx.OnRollback(func() { calls = append(calls, "on-rollback-1") })
+		ctx.AfterCompletion(func(committed bool) { calls = append(calls, "after-completion-1", boolString(committed)) })
+
+		if err := ctx.CommitAndChain(); err != nil {
+			return err
+		}
+
+		// hooks of the chained transaction
+		ctx.OnCommit(func() { calls = append(calls, "on-commit-2") })
+		ctx.OnRollback(func() { calls = append(calls, "on-rollback-2") })
+
+		return errors.New("rollback chained transaction")
+	})
+
+	require.Error(t, err)
+	require.Equal(t, []string{
+		"before-commit-1", "on-commit-1", "after-completion-1", "true",
+		"on-rollback-2",
+	}, calls)
+
+	require.Equal(t, []string{"BEGIN", "COMMIT AND CHAIN", "ROLLBACK"}, fake.Statements())
+}
+
+func TestHooksOfRolledBackSavepoint(t *testing.T) {
+	_, db := newFakeDB(t)
+
+	var calls []string
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		_ = InNestedTransaction(ctx, db, func(ctx TxContext) error {
+			ctx.OnCommit(func() { calls = append(calls, "nested-on-commit") })
+			ctx.OnRollback(func() { calls = append(calls, "nested-on-rollback") })
+			return errors.New("failed")
+		})
+
+		calls = append(calls, "outer")
+
+		return nil
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, []string{"nested-on-rollback", "outer"}, calls)
+}
+
+func boolString(value bool) string {
+	if value {
+		return "true"
+	}
+
+	return "false"
+}
@@ -0,0 +1,147 @@
+package ql
+
+import (
+	"database/sql"
+	"fmt"
+	"iter"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Rows returns an iterator over the rows of the given query. Structs are scanned
+// like Select does, other types are scanned from a single column.
+//
+// The query runs once the iteration starts and the rows are closed once the
+// iteration stops. An error stops the iteration after it was yielded.
+//
+//	for item, err := range ql.Rows[Item](ctx, "SELECT * FROM item") {
+//	    if err != nil {
+//	        return err
+//	    }
+//	    ...
+//	}
+func Rows[T any](ctx TxContext, query string, args ...any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		rows, err := ctx.QueryxContext(ctx, query, args...)
+		if err != nil {
+			var zero T
+			yield(zero, err)
+			return
+		}
+
+		defer rows.Close()
+
+		_, _ = yieldRows(rows, yield)
+	}
+}
+
+var cursorCount atomic.Uint64
+
+// Cursor returns an iterator over the rows of the given query using a server side cursor.
+// The rows are fetched in batches of fetchSize rows, so only a batch is held in memory at
+// any time. Use this to scan tables with millions of rows. See Rows on how rows are scanned.
+//
+// A cursor only lives within a transaction, so the transaction must be kept open
+// during the iteration. A fetchSize below one fetches the rows one by one.
+func Cursor[T any](ctx TxContext, fetchSize int, query string, args ...any) iter.Seq2[T, error] {
+	fetchSize = max(1, fetchSize)
+
+	return func(yield func(T, error) bool) {
+		var zero T
+
+		name := fmt.Sprintf("ql_cursor_%d", cursorCount.Add(1))
+
+		if err := Exec(ctx, "DECLARE "+name+" NO SCROLL CURSOR FOR "+query, args...); err != nil {
+			yield(zero, fmt.Errorf("declare cursor: %w", err))
+			return
+		}
+
+		// the cursor is closed with the transaction anyway, ignore any error
+		defer func() { _ = Exec(ctx, "CLOSE "+name) }()
+
+		fetch := fmt.Sprintf("FETCH FORWARD %d FROM %s", fetchSize, name)
+
+		for {
+			rows, err := ctx.QueryxContext(ctx, fetch)
+			if err != nil {
+				yield(zero, fmt.Errorf("fetch from cursor: %w", err))
+				return
+			}
+
+			count, more := yieldRows(rows, yield)
+			_ = rows.Close()
+
+			if !more || count == 0 || count < fetchSize {
+				return
+			}
+		}
+	}
+}
+
+// yieldRows yields all rows and returns the number of rows yielded. Returns false
+// if the iteration should stop.
+func yieldRows[T any](rows *sqlx.Rows, yield func(T, error) bool) (int, bool) {
+	var count int
+
+	for rows.Next() {
+		value, err := scanRow[T](rows)
+		if err != nil {
+			yield(value, err)
+			return count, false
+		}
+
+		count++
+
+		if !yield(value, nil) {
+			return count, false
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		var zero T
+		yield(zero, err)
+		return count, false
+	}
+
+	return count, true
+}
+
+var (
+	typeScanner = reflect.TypeFor[sql.Scanner]()
+	typeTime    = reflect.TypeFor[time.Time]()
+)
+
+// scanRow scans a struct or a pointer to a struct using StructScan and everything
+// else from a single column.
+func scanRow[T any](rows *sqlx.Rows) (T, error) {
+	var value T
+
+	typ := reflect.TypeFor[T]()
+
+	isPointer := typ.Kind() == reflect.Pointer
+	if isPointer {
+		typ = typ.Elem()
+	}
+
+	scannable := typ.Kind() != reflect.Struct ||
+		typ == typeTime ||
+		reflect.PointerTo(typ).Implements(typeScanner)
+
+	if scannable {
+		err := rows.Scan(&value)
+		return value, err
+	}
+
+	if isPointer {
+		// like sqlx.Select, allocate the struct and scan into it
+		ptr := reflect.New(typ)
+		err := rows.StructScan(ptr.Interface())
+		return ptr.Interface().(T), err
+	}
+
+	err := rows.StructScan(&value)
+	return value, err
+}
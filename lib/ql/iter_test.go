@@ -0,0 +1,228 @@
+package ql
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type iterItem struct {
+	Id   int64  `db:"id"`
+	Name string `db:"name"`
+}
+
+func TestRowsIteratesStructs(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	fake.rows = func(query string, args []driver.NamedValue) ([]string, [][]driver.Value) {
+		return []string{"id", "name"}, [][]driver.Value{{int64(1), "a"}, {int64(2), "b"}}
+	}
+
+	var items []iterItem
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		for item, err := range Rows[iterItem](ctx, "SELECT id, name FROM item") {
+			if err != nil {
+				return err
+			}
+
+			items = append(items, item)
+		}
+
+		return nil
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, []iterItem{{1, "a"}, {2, "b"}}, items)
+}
+
+func TestRowsIteratesStructPointers(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	fake.rows = func(query string, args []driver.NamedValue) ([]string, [][]driver.Value) {
+		return []string{"id", "name"}, [][]driver.Value{{int64(1), "a"}, {int64(2), "b"}}
+	}
+
+	var items []*iterItem
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		for item, err := range Rows[*iterItem](ctx, "SELECT id, name FROM item") {
+			if err != nil {
+				return err
+			}
+
+			items = append(items, item)
+		}
+
+		return nil
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, []*iterItem{{1, "a"}, {2, "b"}}, items)
+
+	// every row gets its own struct
+	require.NotSame(t, items[0], items[1])
+}
+
+func TestRowsScansPointersToScalars(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	fake.rows = func(query string, args []driver.NamedValue) ([]string, [][]driver.Value) {
+		return []string{"name"}, [][]driver.Value{{"a"}, {nil}}
+	}
+
+	var names []*string
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		for name, err := range Rows[*string](ctx, "SELECT name FROM item") {
+			if err != nil {
+				return err
+			}
+
+			names = append(names, name)
+		}
+
+		return nil
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, []*string{new("a"), nil}, names)
+}
+
+func TestRowsStopsOnBreak(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	fake.rows = func(query string, args []driver.NamedValue) ([]string, [][]driver.Value) {
+		return []string{"id"}, [][]driver.Value{{int64(1)}, {int64(2)}, {int64(3)}}
+	}
+
+	var ids []int64
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		for id, err := range Rows[int64](ctx, "SELECT id FROM item") {
+			if err != nil {
+				return err
+			}
+
+			ids = append(ids, id)
+			if id == 2 {
+				break
+			}
+		}
+
+		return nil
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, []int64{1, 2}, ids)
+}
+
+func TestRowsYieldsQueryError(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	errQuery := errors.New("query failed")
+	fake.fail = failOn("SELECT", errQuery)
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		for _, err := range Rows[int64](ctx, "SELECT id FROM item") {
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	require.ErrorIs(t, err, errQuery)
+}
+
+func TestCursorFetchesInBatches(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	remaining := []int64{1, 2, 3, 4, 5}
+	fake.rows = func(query string, args []driver.NamedValue) ([]string, [][]driver.Value) {
+		var values [][]driver.Value
+		for len(values) < 2 && len(remaining) > 0 {
+			values = append(values, []driver.Value{remaining[0]})
+			remaining = remaining[1:]
+		}
+
+		return []string{"id"}, values
+	}
+
+	var ids []int64
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		for id, err := range Cursor[int64](ctx, 2, "SELECT id FROM item WHERE id > $1", 0) {
+			if err != nil {
+				return err
+			}
+
+			ids = append(ids, id)
+		}
+
+		return nil
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, []int64{1, 2, 3, 4, 5}, ids)
+
+	statements := fake.Statements()
+	require.Len(t, statements, 7)
+	require.Regexp(t, `^DECLARE ql_cursor_\d+ NO SCROLL CURSOR FOR SELECT id FROM item WHERE id > \$1 \[0\]$`, statements[1])
+
+	cursorName := strings.Fields(statements[1])[1]
+	require.Equal(t, []string{
+		"FETCH FORWARD 2 FROM " + cursorName,
+		"FETCH FORWARD 2 FROM " + cursorName,
+		"FETCH FORWARD 2 FROM " + cursorName,
+		"CLOSE " + cursorName,
+		"COMMIT",
+	}, statements[2:])
+}
+
+func TestCursorWithoutFetchSizeStops(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	remaining := []int64{1, 2}
+	fake.rows = func(query string, args []driver.NamedValue) ([]string, [][]driver.Value) {
+		var values [][]driver.Value
+		if len(remaining) > 0 {
+			values = append(values, []driver.Value{remaining[0]})
+			remaining = remaining[1:]
+		}
+
+		return []string{"id"}, values
+	}
+
+	var ids []int64
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		for id, err := range Cursor[int64](ctx, 0, "SELECT id FROM item") {
+			if err != nil {
+				return err
+			}
+
+			ids = append(ids, id)
+		}
+
+		return nil
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, []int64{1, 2}, ids)
+
+	// the rows are fetched one by one until the cursor is exhausted
+	statements := fake.Statements()
+	cursorName := strings.Fields(statements[1])[1]
+	require.Equal(t, []string{
+		"FETCH FORWARD 1 FROM " + cursorName,
+		"FETCH FORWARD 1 FROM " + cursorName,
+		"FETCH FORWARD 1 FROM " + cursorName,
+		"CLOSE " + cursorName,
+		"COMMIT",
+	}, statements[2:])
+}
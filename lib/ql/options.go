@@ -0,0 +1,172 @@
+package ql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ErrIncompatibleTransaction is returned by InAnyTransaction if the existing
+// transaction does not satisfy the requested options.
+var ErrIncompatibleTransaction = errors.New("existing transaction is incompatible with the requested options")
+
+// TxOption configures a transaction started by InNewTransaction.
+type TxOption func(*txOptions)
+
+type txOptions struct {
+	retry *RetryPolicy
+
+	isolation  sql.IsolationLevel
+	readOnly   bool
+	deferrable bool
+
+	statementTimeout         time.Duration
+	lockTimeout              time.Duration
+	idleInTransactionTimeout time.Duration
+
+	applicationName string
+}
+
+func newTxOptions(opts []TxOption) txOptions {
+	var options txOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return options
+}
+
+// WithRetry retries the transaction according to the policy, if it fails
+// with a retryable error. See RetryPolicy.
+func WithRetry(policy RetryPolicy) TxOption {
+	return func(opts *txOptions) {
+		opts.retry = &policy
+	}
+}
+
+// WithIsolation starts the transaction with the given isolation level.
+func WithIsolation(level sql.IsolationLevel) TxOption {
+	return func(opts *txOptions) {
+		opts.isolation = level
+	}
+}
+
+// ReadOnly starts a READ ONLY transaction.
+func ReadOnly() TxOption {
+	return func(opts *txOptions) {
+		opts.readOnly = true
+	}
+}
+
+// Deferrable starts a SERIALIZABLE READ ONLY DEFERRABLE transaction. Such a transaction
+// might block when starting, but will never fail with a serialization failure.
+func Deferrable() TxOption {
+	return func(opts *txOptions) {
+		opts.isolation = sql.LevelSerializable
+		opts.readOnly = true
+		opts.deferrable = true
+	}
+}
+
+// WithStatementTimeout sets the statement_timeout for the transaction.
+func WithStatementTimeout(timeout time.Duration) TxOption {
+	return func(opts *txOptions) {
+		opts.statementTimeout = timeout
+	}
+}
+
+// WithLockTimeout sets the lock_timeout for the transaction.
+func WithLockTimeout(timeout time.Duration) TxOption {
+	return func(opts *txOptions) {
+		opts.lockTimeout = timeout
+	}
+}
+
+// WithIdleInTransactionTimeout sets the idle_in_transaction_session_timeout for the transaction.
+func WithIdleInTransactionTimeout(timeout time.Duration) TxOption {
+	return func(opts *txOptions) {
+		opts.idleInTransactionTimeout = timeout
+	}
+}
+
+// WithApplicationName sets the application_name for the transaction, to find it
+// in pg_stat_activity.
+func WithApplicationName(name string) TxOption {
+	return func(opts *txOptions) {
+		opts.applicationName = name
+	}
+}
+
+// sqlTxOptions returns the options to pass to BeginTxx.
+func (opts txOptions) sqlTxOptions() *sql.TxOptions {
+	if opts.isolation == sql.LevelDefault && !opts.readOnly {
+		return nil
+	}
+
+	return &sql.TxOptions{Isolation: opts.isolation, ReadOnly: opts.readOnly}
+}
+
+// settings returns the configuration parameters to set locally in the transaction.
+func (opts txOptions) settings() [][2]string {
+	var settings [][2]string
+
+	addTimeout := func(name string, timeout time.Duration) {
+		if timeout > 0 {
+			settings = append(settings, [2]string{name, strconv.FormatInt(timeout.Milliseconds(), 10)})
+		}
+	}
+
+	addTimeout("statement_timeout", opts.statementTimeout)
+	addTimeout("lock_timeout", opts.lockTimeout)
+	addTimeout("idle_in_transaction_session_timeout", opts.idleInTransactionTimeout)
+
+	if opts.applicationName != "" {
+		settings = append(settings, [2]string{"application_name", opts.applicationName})
+	}
+
+	return settings
+}
+
+// apply configures the freshly started transaction.
+func (opts txOptions) apply(ctx TxContext) error {
+	if opts.deferrable {
+		if err := Exec(ctx, "SET TRANSACTION DEFERRABLE"); err != nil {
+			return fmt.Errorf("set transaction deferrable: %w", err)
+		}
+	}
+
+	for _, setting := range opts.settings() {
+		if err := Exec(ctx, "SELECT set_config($1, $2, true)", setting[0], setting[1]); err != nil {
+			return fmt.Errorf("set %s: %w", setting[0], err)
+		}
+	}
+
+	return nil
+}
+
+// checkCompatible returns an error if a transaction started with the current options can
+// not be used by code requesting the given options. Timeouts, the application name and
+// the retry policy are owned by the existing transaction and are not compared.
+func (opts txOptions) checkCompatible(requested txOptions) error {
+	if effectiveIsolation(opts.isolation) < effectiveIsolation(requested.isolation) {
+		return fmt.Errorf("%w: isolation level is %s, requested %s",
+			ErrIncompatibleTransaction, effectiveIsolation(opts.isolation), requested.isolation)
+	}
+
+	if opts.readOnly && !requested.readOnly {
+		return fmt.Errorf("%w: transaction is read only", ErrIncompatibleTransaction)
+	}
+
+	return nil
+}
+
+// effectiveIsolation maps the default isolation level to the postgres default.
+func effectiveIsolation(level sql.IsolationLevel) sql.IsolationLevel {
+	if level == sql.LevelDefault {
+		return sql.LevelReadCommitted
+	}
+
+	return level
+}
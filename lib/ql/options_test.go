@@ -0,0 +1,95 @@
+package ql
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInNewTransactionWithOptions(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		return Exec(ctx, "SELECT 1")
+	},
+		WithIsolation(sql.LevelRepeatableRead),
+		WithStatementTimeout(1500*time.Millisecond),
+		WithLockTimeout(time.Second),
+		WithIdleInTransactionTimeout(time.Minute),
+		WithApplicationName("billing-job"),
+	)
+
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"BEGIN ISOLATION LEVEL Repeatable Read",
+		"SELECT set_config($1, $2, true) [statement_timeout] [1500]",
+		"SELECT set_config($1, $2, true) [lock_timeout] [1000]",
+		"SELECT set_config($1, $2, true) [idle_in_transaction_session_timeout] [60000]",
+		"SELECT set_config($1, $2, true) [application_name] [billing-job]",
+		"SELECT 1",
+		"COMMIT",
+	}, fake.Statements())
+}
+
+func TestInNewTransactionDeferrable(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error { return nil }, Deferrable())
+
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"BEGIN ISOLATION LEVEL Serializable READ ONLY",
+		"SET TRANSACTION DEFERRABLE",
+		"COMMIT",
+	}, fake.Statements())
+}
+
+func TestInNewTransactionFailingOptionRollsBack(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	fake.fail = failOn("SELECT set_config", sql.ErrConnDone)
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		t.Fatal("must not run if the options can not be applied")
+		return nil
+	}, WithStatementTimeout(time.Second))
+
+	require.ErrorIs(t, err, sql.ErrConnDone)
+	require.Equal(t, []string{"BEGIN", "SELECT set_config($1, $2, true) [statement_timeout] [1000]", "ROLLBACK"}, fake.Statements())
+}
+
+func TestInAnyTransactionValidatesExistingTransaction(t *testing.T) {
+	_, db := newFakeDB(t)
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		// the default isolation is read committed
+		require.NoError(t, InAnyTransaction(ctx, db, noopTx, WithIsolation(sql.LevelReadCommitted)))
+
+		err := InAnyTransaction(ctx, db, noopTx, WithIsolation(sql.LevelSerializable))
+		require.ErrorIs(t, err, ErrIncompatibleTransaction)
+
+		// settings are owned by the existing transaction
+		require.NoError(t, InAnyTransaction(ctx, db, noopTx, WithStatementTimeout(time.Second)))
+
+		return nil
+	})
+
+	require.NoError(t, err)
+
+	err = InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		require.NoError(t, InAnyTransaction(ctx, db, noopTx, ReadOnly(), WithIsolation(sql.LevelRepeatableRead)))
+
+		err := InAnyTransaction(ctx, db, noopTx)
+		require.ErrorIs(t, err, ErrIncompatibleTransaction)
+
+		return nil
+	}, ReadOnly(), WithIsolation(sql.LevelSerializable))
+
+	require.NoError(t, err)
+}
+
+func noopTx(ctx TxContext) error {
+	return nil
+}
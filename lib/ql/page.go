@@ -0,0 +1,304 @@
+package ql
+
+import (
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrInvalidCursor = errors.New("invalid page cursor")
+
+// PageColumn is one column of the order of a paginated query.
+type PageColumn struct {
+	// Name of the column in the result of the query. Must match the db tag of a field.
+	Name       string
+	Descending bool
+}
+
+// PageRequest describes which page of a query to load.
+type PageRequest struct {
+	// OrderBy are the columns the rows are ordered by. Together, they must be unique
+	// for every row, e.g. the creation time followed by the primary key. The columns
+	// must not be NULL.
+	OrderBy []PageColumn
+
+	// Cursor is a cursor from a previous Page. Empty for the first page.
+	Cursor string
+
+	// Limit is the maximum number of rows on a page.
+	Limit int
+}
+
+// Page is one page of rows loaded by SelectPage.
+type Page[T any] struct {
+	Items []T
+
+	// NextCursor loads the following page. Empty on the last page.
+	NextCursor string
+
+	// PrevCursor loads the preceding page. Empty on the first page.
+	PrevCursor string
+}
+
+type pageCursor struct {
+	Values   []cursorValue `json:"v"`
+	Backward bool          `json:"b,omitempty"`
+}
+
+// cursorValue is the value of an order column in a cursor. Times keep their type
+// and offset, so they are compared by their wall clock with a timestamp column and
+// by their instant with a timestamptz column.
+type cursorValue struct {
+	Text *string    `json:"s,omitempty"`
+	Time *time.Time `json:"t,omitempty"`
+}
+
+func (v cursorValue) arg() any {
+	if v.Time != nil {
+		return *v.Time
+	}
+
+	return *v.Text
+}
+
+// SelectPage loads one page of the query using keyset pagination. Unlike OFFSET, the cost of
+// loading a page does not grow with the number of pages before it. The query is used as a
+// subquery, it can filter but its own ordering is replaced by the ordering of the request.
+//
+// Cursors are opaque strings that can be passed to the client, e.g. as query parameter.
+func SelectPage[T any](ctx TxContext, req PageRequest, query string, args ...any) (Page[T], error) {
+	if len(req.OrderBy) == 0 {
+		return Page[T]{}, errors.New("select page: no order columns")
+	}
+
+	limit := max(req.Limit, 1)
+
+	columns, err := pageColumnsOf[T](req.OrderBy)
+	if err != nil {
+		return Page[T]{}, err
+	}
+
+	var cursor pageCursor
+	if req.Cursor != "" {
+		cursor, err = decodePageCursor(req.Cursor, len(req.OrderBy))
+		if err != nil {
+			return Page[T]{}, err
+		}
+	}
+
+	stmt, args := buildPageQuery(req.OrderBy, cursor, limit, query, args)
+
+	items, err := Select[T](ctx, stmt, args...)
+	if err != nil {
+		return Page[T]{}, err
+	}
+
+	hasMore := len(items) > limit
+	if hasMore {
+		items = items[:limit]
+	}
+
+	if cursor.Backward {
+		slices.Reverse(items)
+	}
+
+	page := Page[T]{Items: items}
+	if len(items) == 0 {
+		return page, nil
+	}
+
+	// we came from the other direction, so there are rows in that direction
+	hasNext := hasMore || cursor.Backward
+	hasPrev := hasMore && cursor.Backward || !cursor.Backward && req.Cursor != ""
+
+	if hasNext {
+		page.NextCursor, err = encodePageCursor(req.OrderBy, columns, items[len(items)-1], false)
+		if err != nil {
+			return Page[T]{}, err
+		}
+	}
+
+	if hasPrev {
+		page.PrevCursor, err = encodePageCursor(req.OrderBy, columns, items[0], true)
+		if err != nil {
+			return Page[T]{}, err
+		}
+	}
+
+	return page, nil
+}
+
+func buildPageQuery(orderBy []PageColumn, cursor pageCursor, limit int, query string, args []any) (string, []any) {
+	var b strings.Builder
+
+	b.WriteString("SELECT * FROM (")
+	b.WriteString(query)
+	b.WriteString(") AS page")
+
+	args = slices.Clone(args)
+
+	if len(cursor.Values) > 0 {
+		// expands to: (a > $1) OR (a = $1 AND b > $2) OR ...
+		b.WriteString(" WHERE ")
+
+		for idx, column := range orderBy {
+			if idx > 0 {
+				b.WriteString(" OR ")
+			}
+
+			b.WriteByte('(')
+
+			for prevIdx := range idx {
+				b.WriteString(pgx.Identifier{orderBy[prevIdx].Name}.Sanitize())
+				b.WriteString(" = $")
+				b.WriteString(strconv.Itoa(len(args) + prevIdx + 1))
+				b.WriteString(" AND ")
+			}
+
+			operator := " > $"
+			if column.Descending != cursor.Backward {
+				operator = " < $"
+			}
+
+			b.WriteString(pgx.Identifier{column.Name}.Sanitize())
+			b.WriteString(operator)
+			b.WriteString(strconv.Itoa(len(args) + idx + 1))
+			b.WriteByte(')')
+		}
+
+		for _, value := range cursor.Values {
+			args = append(args, value.arg())
+		}
+	}
+
+	b.WriteString(" ORDER BY ")
+
+	for idx, column := range orderBy {
+		if idx > 0 {
+			b.WriteString(", ")
+		}
+
+		b.WriteString(pgx.Identifier{column.Name}.Sanitize())
+
+		// walk backwards by reversing the order
+		if column.Descending != cursor.Backward {
+			b.WriteString(" DESC")
+		}
+	}
+
+	// one more row tells if there is another page
+	b.WriteString(" LIMIT ")
+	b.WriteString(strconv.Itoa(limit + 1))
+
+	return b.String(), args
+}
+
+// pageColumnsOf returns the field indices of the order columns in T.
+func pageColumnsOf[T any](orderBy []PageColumn) ([][]int, error) {
+	fields, err := insertColumnsOf(reflect.TypeFor[T]())
+	if err != nil {
+		return nil, fmt.Errorf("select page: %w", err)
+	}
+
+	var indices [][]int
+	for _, column := range orderBy {
+		idx := slices.IndexFunc(fields, func(c insertColumn) bool { return c.name == column.Name })
+		if idx < 0 {
+			return nil, fmt.Errorf("select page: no field for order column %q", column.Name)
+		}
+
+		indices = append(indices, fields[idx].index)
+	}
+
+	return indices, nil
+}
+
+func encodePageCursor[T any](orderBy []PageColumn, columns [][]int, item T, backward bool) (string, error) {
+	value := reflect.ValueOf(item)
+
+	cursor := pageCursor{Backward: backward}
+	for idx, index := range columns {
+		// NULL compares neither greater nor less, the next page would skip rows
+		columnValue, ok := cursorValueOf(value.FieldByIndex(index))
+		if !ok {
+			return "", fmt.Errorf("select page: order column %q is NULL", orderBy[idx].Name)
+		}
+
+		cursor.Values = append(cursor.Values, columnValue)
+	}
+
+	encoded, err := json.Marshal(cursor)
+	if err != nil {
+		return "", fmt.Errorf("select page: encode cursor: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(encoded), nil
+}
+
+func decodePageCursor(encoded string, columnCount int) (pageCursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return pageCursor{}, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+
+	var cursor pageCursor
+	if err := json.Unmarshal(decoded, &cursor); err != nil {
+		return pageCursor{}, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+
+	if len(cursor.Values) != columnCount {
+		return pageCursor{}, fmt.Errorf("%w: expected %d values", ErrInvalidCursor, columnCount)
+	}
+
+	for _, value := range cursor.Values {
+		if (value.Text == nil) == (value.Time == nil) {
+			return pageCursor{}, fmt.Errorf("%w: invalid value", ErrInvalidCursor)
+		}
+	}
+
+	return cursor, nil
+}
+
+// cursorValueOf returns the value of an order column for a cursor. Values other than
+// times are formatted in the text format of postgres. Returns false for NULL.
+func cursorValueOf(field reflect.Value) (cursorValue, bool) {
+	if field.Kind() == reflect.Pointer {
+		if field.IsNil() {
+			return cursorValue{}, false
+		}
+
+		field = field.Elem()
+	}
+
+	value := field.Interface()
+
+	if valuer, ok := value.(driver.Valuer); ok {
+		if v, err := valuer.Value(); err == nil {
+			value = v
+		}
+	}
+
+	var text string
+
+	switch value := value.(type) {
+	case nil:
+		return cursorValue{}, false
+	case time.Time:
+		return cursorValue{Time: &value}, true
+	case []byte:
+		text = string(value)
+	default:
+		text = fmt.Sprint(value)
+	}
+
+	return cursorValue{Text: &text}, true
+}
@@ -0,0 +1,151 @@
+package ql
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type pageItem struct {
+	Created time.Time `db:"created"`
+	Id      int64     `db:"id"`
+}
+
+var pageOrder = []PageColumn{{Name: "created", Descending: true}, {Name: "id"}}
+
+func pageRows(items ...pageItem) func(string, []driver.NamedValue) ([]string, [][]driver.Value) {
+	return func(string, []driver.NamedValue) ([]string, [][]driver.Value) {
+		var values [][]driver.Value
+		for _, item := range items {
+			values = append(values, []driver.Value{item.Created, item.Id})
+		}
+
+		return []string{"created", "id"}, values
+	}
+}
+
+func TestSelectPageFirstPage(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake.rows = pageRows(pageItem{base, 1}, pageItem{base, 2}, pageItem{base, 3})
+
+	page, err := InNewTransactionWithResult(t.Context(), db, func(ctx TxContext) (Page[pageItem], error) {
+		return SelectPage[pageItem](ctx, PageRequest{OrderBy: pageOrder, Limit: 2}, "SELECT * FROM item WHERE kind = $1", "a")
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, []pageItem{{base, 1}, {base, 2}}, page.Items)
+	require.NotEmpty(t, page.NextCursor)
+	require.Empty(t, page.PrevCursor)
+
+	require.Contains(t, fake.Statements(),
+		`SELECT * FROM (SELECT * FROM item WHERE kind = $1) AS page ORDER BY "created" DESC, "id" LIMIT 3`)
+}
+
+func TestSelectPageFollowsCursors(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	next, err := encodePageCursor(pageOrder, [][]int{{0}, {1}}, pageItem{base, 2}, false)
+	require.NoError(t, err)
+
+	fake, db := newFakeDB(t)
+
+	var args []driver.NamedValue
+	fake.rows = func(query string, queryArgs []driver.NamedValue) ([]string, [][]driver.Value) {
+		args = queryArgs
+		return pageRows(pageItem{base, 3}, pageItem{base, 4})(query, queryArgs)
+	}
+
+	page, err := InNewTransactionWithResult(t.Context(), db, func(ctx TxContext) (Page[pageItem], error) {
+		return SelectPage[pageItem](ctx, PageRequest{OrderBy: pageOrder, Cursor: next, Limit: 2}, "SELECT * FROM item")
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, []pageItem{{base, 3}, {base, 4}}, page.Items)
+	require.Empty(t, page.NextCursor)
+	require.NotEmpty(t, page.PrevCursor)
+
+	require.Contains(t, fake.Statements(),
+		`SELECT * FROM (SELECT * FROM item) AS page WHERE ("created" < $1) OR ("created" = $1 AND "id" > $2) ORDER BY "created" DESC, "id" LIMIT 3`)
+
+	require.Len(t, args, 2)
+	require.Equal(t, base, args[0].Value)
+	require.Equal(t, "2", args[1].Value)
+
+	// walking back reverses the order and the rows
+	fake.rows = pageRows(pageItem{base, 2}, pageItem{base, 1})
+
+	prev, err := InNewTransactionWithResult(t.Context(), db, func(ctx TxContext) (Page[pageItem], error) {
+		return SelectPage[pageItem](ctx, PageRequest{OrderBy: pageOrder, Cursor: page.PrevCursor, Limit: 2}, "SELECT * FROM item")
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, []pageItem{{base, 1}, {base, 2}}, prev.Items)
+	require.NotEmpty(t, prev.NextCursor)
+	require.Empty(t, prev.PrevCursor)
+
+	require.Contains(t, fake.Statements(),
+		`SELECT * FROM (SELECT * FROM item) AS page WHERE ("created" > $1) OR ("created" = $1 AND "id" < $2) ORDER BY "created", "id" DESC LIMIT 3`)
+}
+
+func TestSelectPageRejectsInvalidCursor(t *testing.T) {
+	_, db := newFakeDB(t)
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		_, err := SelectPage[pageItem](ctx, PageRequest{OrderBy: pageOrder, Cursor: "not a cursor"}, "SELECT * FROM item")
+		return err
+	})
+
+	require.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestSelectPageRequiresOrderField(t *testing.T) {
+	_, db := newFakeDB(t)
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		_, err := SelectPage[pageItem](ctx, PageRequest{OrderBy: []PageColumn{{Name: "name"}}}, "SELECT * FROM item")
+		return err
+	})
+
+	require.ErrorContains(t, err, `no field for order column "name"`)
+}
+
+func TestSelectPageKeepsTimeOffset(t *testing.T) {
+	// the wall clock of a timestamp column must survive the cursor
+	created := time.Date(2026, 1, 1, 10, 0, 0, 0, time.FixedZone("", 2*60*60))
+
+	next, err := encodePageCursor(pageOrder, [][]int{{0}, {1}}, pageItem{created, 2}, false)
+	require.NoError(t, err)
+
+	cursor, err := decodePageCursor(next, 2)
+	require.NoError(t, err)
+
+	value := cursor.Values[0].arg().(time.Time)
+	require.True(t, created.Equal(value))
+	require.Equal(t, "2026-01-01 10:00:00 +0200", value.Format("2006-01-02 15:04:05 -0700"))
+}
+
+type nullablePageItem struct {
+	Name *string `db:"name"`
+	Id   int64   `db:"id"`
+}
+
+func TestSelectPageRejectsNullOrderValue(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	fake.rows = func(string, []driver.NamedValue) ([]string, [][]driver.Value) {
+		return []string{"name", "id"}, [][]driver.Value{{nil, int64(1)}, {nil, int64(2)}}
+	}
+
+	order := []PageColumn{{Name: "name"}, {Name: "id"}}
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		_, err := SelectPage[nullablePageItem](ctx, PageRequest{OrderBy: order, Limit: 1}, "SELECT * FROM item")
+		return err
+	})
+
+	require.ErrorContains(t, err, `order column "name" is NULL`)
+}
@@ -0,0 +1,42 @@
+package ql
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ReplicaRouter is a TxStarter that can route read only transactions to a
+// replica, like startup_postgres.ReplicaSet.
+type ReplicaRouter interface {
+	TxStarter
+
+	// Replica returns the database to run a read only transaction on.
+	Replica() *sqlx.DB
+}
+
+// InReadOnlyTransaction calls InReadOnlyTransactionWithResult without returning a result.
+func InReadOnlyTransaction(ctx context.Context, db TxStarter, fun func(ctx TxContext) error, opts ...TxOption) error {
+	_, err := InReadOnlyTransactionWithResult(ctx, db, func(ctx TxContext) (any, error) {
+		return nil, fun(ctx)
+	}, opts...)
+	return err
+}
+
+// InReadOnlyTransactionWithResult runs the operation in a read only transaction. If db is a
+// ReplicaRouter, the transaction runs on a replica. Keep in mind that a replica might lag behind
+// the primary, so data written shortly before might not be visible yet.
+//
+// If the context already contains a transaction, the operation joins that transaction,
+// like InAnyTransactionWithResult does.
+func InReadOnlyTransactionWithResult[R any](ctx context.Context, db TxStarter, fun func(ctx TxContext) (R, error), opts ...TxOption) (R, error) {
+	opts = append(opts, ReadOnly())
+
+	if TxContextFromContext(ctx) == nil {
+		if router, ok := db.(ReplicaRouter); ok {
+			db = router.Replica()
+		}
+	}
+
+	return InAnyTransactionWithResult(ctx, db, fun, opts...)
+}
@@ -0,0 +1,58 @@
+package ql
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+type testRouter struct {
+	*sqlx.DB
+	replica *sqlx.DB
+}
+
+func (r testRouter) Replica() *sqlx.DB {
+	return r.replica
+}
+
+func TestInReadOnlyTransactionUsesReplica(t *testing.T) {
+	primary, primaryDB := newFakeDB(t)
+	replica, replicaDB := newFakeDB(t)
+
+	router := testRouter{DB: primaryDB, replica: replicaDB}
+
+	err := InReadOnlyTransaction(t.Context(), router, func(ctx TxContext) error {
+		return Exec(ctx, "SELECT 1")
+	})
+
+	require.NoError(t, err)
+	require.Empty(t, primary.Statements())
+	require.Equal(t, []string{"BEGIN READ ONLY", "SELECT 1", "COMMIT"}, replica.Statements())
+}
+
+func TestInReadOnlyTransactionJoinsExistingTransaction(t *testing.T) {
+	primary, primaryDB := newFakeDB(t)
+	replica, replicaDB := newFakeDB(t)
+
+	router := testRouter{DB: primaryDB, replica: replicaDB}
+
+	err := InNewTransaction(t.Context(), router, func(ctx TxContext) error {
+		return InReadOnlyTransaction(ctx, router, func(ctx TxContext) error {
+			return Exec(ctx, "SELECT 1")
+		})
+	})
+
+	require.NoError(t, err)
+	require.Empty(t, replica.Statements())
+	require.Equal(t, []string{"BEGIN", "SELECT 1", "COMMIT"}, primary.Statements())
+}
+
+func TestInReadOnlyTransactionWithoutRouter(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	err := InReadOnlyTransaction(t.Context(), db, func(ctx TxContext) error { return nil })
+
+	require.NoError(t, err)
+	require.Equal(t, []string{"BEGIN READ ONLY", "COMMIT"}, fake.Statements())
+}
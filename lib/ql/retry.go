@@ -0,0 +1,97 @@
+package ql
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	pt "github.com/flachnetz/startup/v2/startup_postgres"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// RetryPolicy describes how often and when a failed transaction is run again.
+//
+// The transaction function is run again from the start in a new transaction, so it
+// must not have side effects outside the database. A transaction that registered
+// OnCommit hooks is never retried, as these usually indicate such side effects.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+
+	// MinBackoff is the time to wait before the first retry. The backoff doubles with
+	// every retry up to MaxBackoff. The actual wait time is jittered.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// Retryable decides if a transaction failing with the given error is retried.
+	// Defaults to RetryableSerializationFailure.
+	Retryable func(err *pgconn.PgError) bool
+}
+
+// DefaultRetryPolicy retries serialization failures and deadlocks.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	MinBackoff:  10 * time.Millisecond,
+	MaxBackoff:  500 * time.Millisecond,
+}
+
+// RetryableSerializationFailure returns true for serialization failures (40001)
+// and detected deadlocks (40P01).
+func RetryableSerializationFailure(err *pgconn.PgError) bool {
+	return pt.ErrIsSerializationFailure(err)
+}
+
+func (p *RetryPolicy) shouldRetry(attempt int, err error) bool {
+	if p == nil || attempt >= p.MaxAttempts {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = RetryableSerializationFailure
+	}
+
+	return retryable(pgErr)
+}
+
+// backoff returns the time to wait after the given failed attempt.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := p.MinBackoff
+	for range attempt - 1 {
+		if p.MaxBackoff > 0 && backoff >= p.MaxBackoff {
+			break
+		}
+
+		backoff *= 2
+	}
+
+	if p.MaxBackoff > 0 {
+		backoff = min(backoff, p.MaxBackoff)
+	}
+
+	if backoff <= 0 {
+		return 0
+	}
+
+	// jitter between half and the full backoff, so that conflicting
+	// transactions do not run into each other again
+	return backoff/2 + rand.N(backoff/2+1)
+}
+
+func (p *RetryPolicy) wait(ctx context.Context, attempt int) error {
+	timer := time.NewTimer(p.backoff(attempt))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,123 @@
+package ql
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+var testRetryPolicy = RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+func TestInNewTransactionRetriesSerializationFailure(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	var attempts int
+	fake.fail = func(query string) error {
+		if query == "COMMIT" && attempts < 2 {
+			return &pgconn.PgError{Code: "40001"}
+		}
+
+		return nil
+	}
+
+	result, err := InNewTransactionWithResult(t.Context(), db, func(ctx TxContext) (int, error) {
+		attempts++
+		return attempts, Exec(ctx, "UPDATE")
+	}, WithRetry(testRetryPolicy))
+
+	require.NoError(t, err)
+	require.Equal(t, 2, result)
+	require.Equal(t, []string{"BEGIN", "UPDATE", "COMMIT", "BEGIN", "UPDATE", "COMMIT"}, fake.Statements())
+}
+
+func TestInNewTransactionRetriesDeadlockUpToMaxAttempts(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	fake.fail = failOn("UPDATE", &pgconn.PgError{Code: "40P01"})
+
+	var attempts int
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		attempts++
+		return Exec(ctx, "UPDATE")
+	}, WithRetry(testRetryPolicy))
+
+	var pgErr *pgconn.PgError
+	require.ErrorAs(t, err, &pgErr)
+	require.Equal(t, "40P01", pgErr.Code)
+	require.Equal(t, 3, attempts)
+}
+
+func TestInNewTransactionDoesNotRetryOtherErrors(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	fake.fail = failOn("UPDATE", &pgconn.PgError{Code: "23505"})
+
+	var attempts int
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		attempts++
+		return Exec(ctx, "UPDATE")
+	}, WithRetry(testRetryPolicy))
+
+	require.Error(t, err)
+	require.Equal(t, 1, attempts)
+
+	// and without a policy, nothing is retried
+	attempts = 0
+	fake.fail = failOn("UPDATE", &pgconn.PgError{Code: "40001"})
+
+	err = InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		attempts++
+		return Exec(ctx, "UPDATE")
+	})
+
+	require.Error(t, err)
+	require.Equal(t, 1, attempts)
+}
+
+func TestInNewTransactionDoesNotRetryWithOnCommitHooks(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	fake.fail = failOn("COMMIT", &pgconn.PgError{Code: "40001"})
+
+	var attempts int
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		attempts++
+		ctx.OnCommit(func() { t.Fatal("must not run after a failed commit") })
+		return nil
+	}, WithRetry(testRetryPolicy))
+
+	require.Error(t, err)
+	require.Equal(t, 1, attempts)
+}
+
+func TestRetryPolicyCustomPredicate(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	errLockTimeout := &pgconn.PgError{Code: "55P03"}
+	fake.fail = failOn("UPDATE", errLockTimeout)
+
+	policy := testRetryPolicy
+	policy.Retryable = func(err *pgconn.PgError) bool { return err.Code == "55P03" }
+
+	var attempts int
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		attempts++
+		return Exec(ctx, "UPDATE")
+	}, WithRetry(policy))
+
+	require.True(t, errors.Is(err, errLockTimeout))
+	require.Equal(t, 3, attempts)
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{MinBackoff: 10 * time.Millisecond, MaxBackoff: 40 * time.Millisecond}
+
+	for range 100 {
+		require.InDelta(t, 7.5*float64(time.Millisecond), float64(policy.backoff(1)), 2.5*float64(time.Millisecond))
+		require.InDelta(t, 15*float64(time.Millisecond), float64(policy.backoff(2)), 5*float64(time.Millisecond))
+		require.InDelta(t, 30*float64(time.Millisecond), float64(policy.backoff(10)), 10*float64(time.Millisecond))
+	}
+}
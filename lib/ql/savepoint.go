@@ -0,0 +1,155 @@
+package ql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	sl "github.com/flachnetz/startup/v2/startup_logging"
+)
+
+var ErrSavepointDone = errors.New("savepoint already released or rolled back")
+
+// Savepoint is a nested transaction, created using TxContext.Savepoint. It must be
+// finished by calling either Release or Rollback.
+//
+// Hooks registered using the context of the savepoint become part of the surrounding
+// transaction once the savepoint is released. If the savepoint is rolled back, its
+// OnRollback and AfterCompletion hooks run right away and all other hooks are discarded.
+type Savepoint struct {
+	name   string
+	parent *txContext
+	ctx    *txContext
+	done   bool
+}
+
+func (c *txContext) Savepoint() (*Savepoint, error) {
+	*c.savepoints++
+	name := fmt.Sprintf("ql_savepoint_%d", *c.savepoints)
+
+	if err := Exec(c, "SAVEPOINT "+name); err != nil {
+		return nil, fmt.Errorf("create savepoint: %w", err)
+	}
+
+	sp := &Savepoint{name: name, parent: c}
+
+	sp.ctx = &txContext{
+		Context:    c.Context,
+		Tx:         c.Tx,
+		conn:       c.conn,
+		hooks:      &hooks{},
+		options:    c.options,
+		savepoints: c.savepoints,
+		savepoint:  sp,
+	}
+
+	return sp, nil
+}
+
+// Context returns the context to run the work of the savepoint with.
+func (sp *Savepoint) Context() TxContext {
+	return sp.ctx
+}
+
+// Release keeps the work done within the savepoint as part of the surrounding transaction.
+func (sp *Savepoint) Release() error {
+	if sp.done {
+		return ErrSavepointDone
+	}
+
+	sp.done = true
+
+	if err := Exec(sp.parent, "RELEASE SAVEPOINT "+sp.name); err != nil {
+		return fmt.Errorf("release savepoint: %w", err)
+	}
+
+	sp.ctx.hooks.releaseInto(sp.parent.hooks)
+
+	return nil
+}
+
+// Rollback reverts all work done within the savepoint. The surrounding
+// transaction can still be used afterward.
+func (sp *Savepoint) Rollback() error {
+	if sp.done {
+		return ErrSavepointDone
+	}
+
+	sp.done = true
+
+	// the work of the savepoint is gone, even if the statements below fail
+	defer sp.ctx.hooks.runRolledBack(sp.parent)
+
+	if err := Exec(sp.parent, "ROLLBACK TO SAVEPOINT "+sp.name); err != nil {
+		return fmt.Errorf("rollback to savepoint: %w", err)
+	}
+
+	// a rollback keeps the savepoint, we do not need it anymore
+	if err := Exec(sp.parent, "RELEASE SAVEPOINT "+sp.name); err != nil {
+		return fmt.Errorf("release savepoint: %w", err)
+	}
+
+	return nil
+}
+
+// InNestedTransaction calls InNestedTransactionWithResult without returning a result.
+func InNestedTransaction(ctx context.Context, db TxStarter, fun func(ctx TxContext) error) error {
+	_, err := InNestedTransactionWithResult(ctx, db, func(ctx TxContext) (any, error) {
+		return nil, fun(ctx)
+	})
+	return err
+}
+
+// InNestedTransactionWithResult runs the given operation within a savepoint of the transaction
+// in the context. If the operation fails, only the work done within the savepoint is rolled back
+// and the surrounding transaction can continue. If no transaction exists, a new transaction
+// will be created.
+//
+// Errors are handled like in InNewTransactionWithResult: sql.ErrNoRows and errors wrapped
+// in NoRollback do not roll back the savepoint.
+func InNestedTransactionWithResult[R any](ctx context.Context, db TxStarter, fun func(ctx TxContext) (R, error)) (R, error) {
+	tx, ok := ctx.Value(txContextKey{}).(*txContext)
+	if !ok || tx == nil {
+		return InNewTransactionWithResult(ctx, db, fun)
+	}
+
+	// keep the callers context, but use the transaction of the context
+	tx = tx.WithContext(ctx).(*txContext)
+
+	sp, err := tx.Savepoint()
+	if err != nil {
+		var defaultValue R
+		return defaultValue, err
+	}
+
+	// set to true once the users code ran
+	var userCodeOk bool
+	defer func() {
+		if !userCodeOk {
+			// There was a panic in the users code, discard the savepoint
+			if err := sp.Rollback(); err != nil {
+				sl.LoggerOf(ctx).WarnContext(ctx, "Rollback to savepoint during panic failed", sl.Error(err))
+			}
+		}
+	}()
+
+	res, err := fun(sp.Context())
+
+	userCodeOk = true
+
+	err, rollback := requiresTxRollback(err)
+
+	if rollback {
+		if rerr := sp.Rollback(); rerr != nil {
+			err = rollbackError{err: err, rerr: rerr}
+		}
+
+		return res, err
+	}
+
+	if rerr := sp.Release(); rerr != nil {
+		err = commitError{err: err, cerr: rerr}
+	}
+
+	return res, err
+}
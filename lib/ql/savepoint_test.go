@@ -0,0 +1,168 @@
+package ql
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInNestedTransactionReleasesSavepoint(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	var hooksRun []string
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		ctx.OnCommit(func() { hooksRun = append(hooksRun, "outer") })
+
+		return InNestedTransaction(ctx, db, func(ctx TxContext) error {
+			ctx.OnCommit(func() { hooksRun = append(hooksRun, "nested") })
+			return Exec(ctx, "INSERT 1")
+		})
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, []string{"BEGIN", "SAVEPOINT ql_savepoint_1", "INSERT 1", "RELEASE SAVEPOINT ql_savepoint_1", "COMMIT"}, fake.Statements())
+	require.Equal(t, []string{"outer", "nested"}, hooksRun)
+}
+
+func TestInNestedTransactionRollsBackSavepoint(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	errNested := errors.New("nested failed")
+
+	var hooksRun []string
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		err := InNestedTransaction(ctx, db, func(ctx TxContext) error {
+			ctx.OnCommit(func() { hooksRun = append(hooksRun, "nested") })
+			return errNested
+		})
+
+		require.ErrorIs(t, err, errNested)
+
+		// the outer transaction continues
+		return Exec(ctx, "INSERT 2")
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"BEGIN",
+		"SAVEPOINT ql_savepoint_1",
+		"ROLLBACK TO SAVEPOINT ql_savepoint_1",
+		"RELEASE SAVEPOINT ql_savepoint_1",
+		"INSERT 2",
+		"COMMIT",
+	}, fake.Statements())
+
+	require.Empty(t, hooksRun, "hooks of a rolled back savepoint must be discarded")
+}
+
+func TestInNestedTransactionKeepsSavepointOnNoRows(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	errKept := errors.New("kept")
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		err := InNestedTransaction(ctx, db, func(ctx TxContext) error {
+			return sql.ErrNoRows
+		})
+		require.ErrorIs(t, err, sql.ErrNoRows)
+
+		err = InNestedTransaction(ctx, db, func(ctx TxContext) error {
+			return NoRollback(errKept)
+		})
+		require.ErrorIs(t, err, errKept)
+
+		return nil
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"BEGIN",
+		"SAVEPOINT ql_savepoint_1",
+		"RELEASE SAVEPOINT ql_savepoint_1",
+		"SAVEPOINT ql_savepoint_2",
+		"RELEASE SAVEPOINT ql_savepoint_2",
+		"COMMIT",
+	}, fake.Statements())
+}
+
+func TestInNestedTransactionNested(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	var hooksRun []string
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		return InNestedTransaction(ctx, db, func(ctx TxContext) error {
+			ctx.OnCommit(func() { hooksRun = append(hooksRun, "first") })
+
+			_ = InNestedTransaction(ctx, db, func(ctx TxContext) error {
+				ctx.OnCommit(func() { hooksRun = append(hooksRun, "second") })
+				return errors.New("failed")
+			})
+
+			return nil
+		})
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"BEGIN",
+		"SAVEPOINT ql_savepoint_1",
+		"SAVEPOINT ql_savepoint_2",
+		"ROLLBACK TO SAVEPOINT ql_savepoint_2",
+		"RELEASE SAVEPOINT ql_savepoint_2",
+		"RELEASE SAVEPOINT ql_savepoint_1",
+		"COMMIT",
+	}, fake.Statements())
+
+	require.Equal(t, []string{"first"}, hooksRun)
+}
+
+func TestInNestedTransactionWithoutTransaction(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	err := InNestedTransaction(t.Context(), db, func(ctx TxContext) error {
+		return Exec(ctx, "INSERT 1")
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, []string{"BEGIN", "INSERT 1", "COMMIT"}, fake.Statements())
+}
+
+func TestSavepointFinishOnce(t *testing.T) {
+	_, db := newFakeDB(t)
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		sp, err := ctx.Savepoint()
+		require.NoError(t, err)
+
+		require.NoError(t, sp.Release())
+		require.ErrorIs(t, sp.Rollback(), ErrSavepointDone)
+
+		require.Error(t, sp.Context().CommitAndChain())
+
+		return nil
+	})
+
+	require.NoError(t, err)
+}
+
+func TestInNestedTransactionFailsToCreateSavepoint(t *testing.T) {
+	fake, db := newFakeDB(t)
+
+	errSavepoint := errors.New("no savepoint for you")
+	fake.fail = failOn("SAVEPOINT", errSavepoint)
+
+	err := InNewTransaction(t.Context(), db, func(ctx TxContext) error {
+		return InNestedTransaction(ctx, db, func(ctx TxContext) error {
+			t.Fatal("must not run without a savepoint")
+			return nil
+		})
+	})
+
+	require.ErrorIs(t, err, errSavepoint)
+	require.Equal(t, []string{"BEGIN", "SAVEPOINT ql_savepoint_1", "ROLLBACK"}, fake.Statements())
+}
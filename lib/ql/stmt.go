@@ -89,6 +89,7 @@ func ExecAffected(ctx TxContext, stmt string, args ...any) (int, error) {
 // Iter returns a typed iterator over the rows of the given query. It is the callers
 // responsibility to close the returned iterator.
 // Most of the time, you want to use Select. Only use this, if you are expecting millions of rows.
+// See Rows and Cursor for iterators that can be used with a range loop.
 func Iter[T any](ctx TxContext, query string, args ...any) (*QueryIter[T], error) {
 	rows, err := ctx.QueryxContext(ctx, query, args...)
 	if err != nil {
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"log/slog"
 
 	"github.com/hashicorp/go-multierror"
 
@@ -35,10 +36,10 @@ func NoRollback(err error) error {
 }
 
 // InNewTransaction calls InNewTransactionWithResult without returning a result
-func InNewTransaction(ctx context.Context, db TxStarter, fun func(ctx TxContext) error) error {
+func InNewTransaction(ctx context.Context, db TxStarter, fun func(ctx TxContext) error, opts ...TxOption) error {
 	_, err := InNewTransactionWithResult(ctx, db, func(ctx TxContext) (any, error) {
 		return nil, fun(ctx)
-	})
+	}, opts...)
 	return err
 }
 
@@ -53,13 +54,18 @@ func InNewTransaction(ctx context.Context, db TxStarter, fun func(ctx TxContext)
 //
 // If the context already contains a transaction then ErrTransactionExistInContext will be returned as
 // error and the actual operation will not be executed.
-func InNewTransactionWithResult[R any](ctx context.Context, db TxStarter, fun func(ctx TxContext) (R, error)) (R, error) {
+//
+// Use WithRetry to run the function again in a new transaction, if the transaction
+// fails with a serialization failure or a deadlock.
+func InNewTransactionWithResult[R any](ctx context.Context, db TxStarter, fun func(ctx TxContext) (R, error), opts ...TxOption) (R, error) {
 	if tx := TxContextFromContext(ctx); tx != nil {
 		// must not have an existing transaction in context
 		var defaultValue R
 		return defaultValue, ErrTransactionExistInContext
 	}
 
+	options := newTxOptions(opts)
+
 	// warn if we're going into a second transaction within the same goroutine
 	// at the same time
 	defer reentrantWarn(ctx)()
@@ -68,17 +74,53 @@ func InNewTransactionWithResult[R any](ctx context.Context, db TxStarter, fun fu
 	ctx = startTraceTransaction(ctx)
 	defer endTraceTransaction(ctx)
 
-	var hooks hooks
+	for attempt := 1; ; attempt++ {
+		result := runTransaction(ctx, db, fun, &options)
+		if !options.retry.shouldRetry(attempt, result.cause) {
+			return result.value, result.err
+		}
+
+		if result.hadCommitHooks {
+			sl.LoggerOf(ctx).WarnContext(ctx,
+				"Not retrying transaction, OnCommit hooks were registered",
+				slog.Int("attempt", attempt), sl.Error(result.cause))
+
+			return result.value, result.err
+		}
+
+		traceTransactionRetry(ctx, attempt, result.cause)
+
+		if werr := options.retry.wait(ctx, attempt); werr != nil {
+			return result.value, result.err
+		}
+	}
+}
 
+// txResult is the outcome of running a transaction once.
+type txResult[R any] struct {
+	value R
+	err   error
+
+	// the error that failed the transaction, if any
+	cause error
+
+	// true if OnCommit hooks were registered during the transaction
+	hadCommitHooks bool
+}
+
+// runTransaction runs the function in a new transaction and runs the hooks
+// once the transaction completed.
+func runTransaction[R any](ctx context.Context, db TxStarter, fun func(ctx TxContext) (R, error), options *txOptions) txResult[R] {
 	// begin the transaction
-	tx, closeConn, err := beginTx(ctx, db)
+	tx, conn, closeConn, err := beginTx(ctx, db, options.sqlTxOptions())
 	if err != nil {
-		var defaultValue R
-		return defaultValue, err
+		return txResult[R]{err: err}
 	}
 
 	defer closeConn()
 
+	var hooks hooks
+
 	// set to true once the users code ran
 	var userCodeOk bool
 	defer func() {
@@ -90,78 +132,117 @@ func InNewTransactionWithResult[R any](ctx context.Context, db TxStarter, fun fu
 				// the issue.
 				sl.LoggerOf(ctx).WarnContext(ctx, "Rollback during panic failed", sl.Error(err))
 			}
+
+			hooks.runRolledBack(ctx)
 		}
 	}()
 
+	txCtx := newTxContext(ctx, tx, conn, &hooks, options)
+
 	// run the users transaction code
-	res, err := fun(newTxContext(ctx, tx, &hooks))
+	var res R
+	err = options.apply(txCtx)
+	if err == nil {
+		res, err = fun(txCtx)
+	}
+
+	// check if the user wants to rollback
+	err, rollback := requiresTxRollback(err)
+
+	if !rollback {
+		// the hooks may still veto the commit
+		if herr := hooks.runBeforeCommit(txCtx); herr != nil {
+			err, rollback = herr, true
+		}
+	}
 
 	// if we panic now, we dont do anything
 	userCodeOk = true
 
-	// check if the user wants to rollback
-	err, rollback := requiresTxRollback(err)
+	result := txResult[R]{value: res, err: err, hadCommitHooks: len(hooks.onCommit) > 0}
 
 	if rollback {
+		result.cause = err
+
 		// we need to perform a rollback
 		rerr := tx.Rollback()
 		if rerr != nil && !errors.Is(rerr, sql.ErrTxDone) {
-			err = rollbackError{err: err, rerr: rerr}
+			result.err = rollbackError{err: err, rerr: rerr}
 		}
 
-		return res, err
+		hooks.runRolledBack(ctx)
+
+		return result
 	}
 
 	// everything is fine, customer wants to commit
 	cerr := tx.Commit()
-	if cerr != nil && !errors.Is(cerr, sql.ErrTxDone) {
-		err = commitError{err: err, cerr: cerr}
-	}
 
-	// if we committed with no errors, we can run the commit hooks
-	if cerr == nil {
-		hooks.RunOnCommit()
+	switch {
+	case cerr == nil:
+		// if we committed with no errors, we can run the commit hooks
+		hooks.runCommitted(ctx)
+
+	case errors.Is(cerr, sql.ErrTxDone):
+		// the user rolled back the transaction by themselves
+		hooks.runRolledBack(ctx)
+
+	default:
+		result.err = commitError{err: err, cerr: cerr}
+		result.cause = cerr
+
+		hooks.runRolledBack(ctx)
 	}
 
-	return res, err
+	return result
 }
 
 func noop() {}
 
-func acquireConnection(ctx context.Context, db *sqlx.DB) (*sqlx.Conn, error) {
+// connStarter is a pool that hands out single connections, like *sqlx.DB.
+type connStarter interface {
+	Connx(ctx context.Context) (*sqlx.Conn, error)
+}
+
+func acquireConnection(ctx context.Context, db connStarter) (*sqlx.Conn, error) {
 	ctx = startTraceAcquireConnection(ctx)
 	defer endTraceAcquireConnection(ctx)
 
 	return db.Connx(ctx)
 }
 
-func beginTx(ctx context.Context, txStarter TxStarter) (*sqlx.Tx, func(), error) {
+// beginTx starts a transaction. The connection of the transaction is returned if
+// known, it is required for CopyFrom.
+func beginTx(ctx context.Context, txStarter TxStarter, opts *sql.TxOptions) (*sqlx.Tx, *sqlx.Conn, func(), error) {
 	switch pool := txStarter.(type) {
-	case *sqlx.DB:
+	case *sqlx.Conn:
+		tx, err := pool.BeginTxx(ctx, opts)
+		return tx, pool, noop, err
+
+	case connStarter:
 		// get the connection from the pool
 		conn, err := acquireConnection(ctx, pool)
 		if err != nil {
-			return nil, nil, fmt.Errorf("get connection from pool: %w", err)
+			return nil, nil, nil, fmt.Errorf("get connection from pool: %w", err)
 		}
 
 		// and begin a connection on this trace
-		tx, err := conn.BeginTxx(ctx, nil)
+		tx, err := conn.BeginTxx(ctx, opts)
 		if err != nil {
 			// we still own the connection, so we need to close it
 			if errClose := conn.Close(); errClose != nil {
 				err = multierror.Append(err, errClose)
 			}
 
-			return nil, nil, fmt.Errorf("start transaction: %w", err)
+			return nil, nil, nil, fmt.Errorf("start transaction: %w", err)
 		}
 
 		closeConn := func() { _ = conn.Close() }
-		return tx, closeConn, nil
+		return tx, conn, closeConn, nil
 
 	default:
-		// probably already a sqlx.Conn, so we can just use it
-		tx, err := txStarter.BeginTxx(ctx, nil)
-		return tx, noop, err
+		tx, err := txStarter.BeginTxx(ctx, opts)
+		return tx, nil, noop, err
 	}
 }
 
@@ -199,6 +280,15 @@ func endTraceTransaction(ctx context.Context) {
 	tracer.TransactionEnd(ctx)
 }
 
+func traceTransactionRetry(ctx context.Context, attempt int, err error) {
+	tracer, ok := pt.GetTracer().(pt.TransactionRetryTracer)
+	if !ok {
+		return
+	}
+
+	tracer.TransactionRetry(ctx, attempt, err)
+}
+
 func startTraceAcquireConnection(ctx context.Context) context.Context {
 	tracer := pt.GetTracer()
 	if tracer == nil {
@@ -241,24 +331,35 @@ func InExistingTransactionWithResult[R any](ctx context.Context, fun func(ctx Tx
 }
 
 // InAnyTransaction calls InAnyTransactionWithResult without returning a result.
-func InAnyTransaction(ctx context.Context, db TxStarter, fun func(ctx TxContext) error) error {
+func InAnyTransaction(ctx context.Context, db TxStarter, fun func(ctx TxContext) error, opts ...TxOption) error {
 	_, err := InAnyTransactionWithResult(ctx, db, func(ctx TxContext) (any, error) {
 		return nil, fun(ctx)
-	})
+	}, opts...)
 	return err
 }
 
 // InAnyTransactionWithResult checks the context for an existing transaction created by InNewTransactionWithResult.
 // If a transaction exists it will run the given operation in the transaction context.
-// If no transaction exists, a new transaction will be created.
+// If no transaction exists, a new transaction will be created using the given options.
+//
+// When joining an existing transaction, ErrIncompatibleTransaction is returned if the existing
+// transaction has a weaker isolation level than requested or is read only while the caller
+// did not ask for a read only transaction.
 //
 // See InNewTransactionWithResult regarding error handling.
-func InAnyTransactionWithResult[R any](ctx context.Context, db TxStarter, fun func(ctx TxContext) (R, error)) (R, error) {
+func InAnyTransactionWithResult[R any](ctx context.Context, db TxStarter, fun func(ctx TxContext) (R, error), opts ...TxOption) (R, error) {
 	tx := TxContextFromContext(ctx)
 	if tx != nil {
+		if existing, ok := tx.(*txContext); ok {
+			if err := existing.options.checkCompatible(newTxOptions(opts)); err != nil {
+				var defaultValue R
+				return defaultValue, err
+			}
+		}
+
 		return InExistingTransactionWithResult[R](ctx, fun)
 	} else {
-		return InNewTransactionWithResult[R](ctx, db, fun)
+		return InNewTransactionWithResult[R](ctx, db, fun, opts...)
 	}
 }
 
@@ -40,6 +40,15 @@ func ParseCommandLine(ctx context.Context, opts any) error {
 }
 
 // ParseCommandLineWithOptions Parses command line.
+//
+// Option values are resolved with the precedence defaults < config file < env < flags.
+// Config files are selected with --config or CONFIG_FILE, see applyConfigFiles.
+// Secret options can be read from files, see resolveSecretFiles.
+//
+// After initialization the startup_base.DefaultLifecycle is started. With
+// --stop-on-signal, it stops all registered components in dependency order once
+// SIGINT or SIGTERM is received. After a signal the process exits unless something,
+// like the http server, waits for the lifecycle to be done.
 func ParseCommandLineWithOptions(ctx context.Context, opts any, options flags.Options) error {
 	if reflect.ValueOf(opts).Kind() != reflect.Pointer {
 		return errors.New("options parameter must be pointer")
@@ -65,87 +74,108 @@ func ParseCommandLineWithOptions(ctx context.Context, opts any, options flags.Op
 	parser := flags.NewParser(opts, options)
 	parser.NamespaceDelimiter = "-"
 
-	args, err := parser.Parse()
+	// make --config and --print-config known options for every options struct
+	var configOpts configFileOptions
+	if _, err := parser.AddGroup("Configuration file", "", &configOpts); err != nil {
+		return fmt.Errorf("add config file options: %w", err)
+	}
+
+	// values from config files are installed as option defaults
+	fileValues, err := applyConfigFiles(parser, options)
 	if err != nil {
 		return err
 	}
 
-	if len(args) > 0 && (options&flags.IgnoreUnknown) != flags.None {
-		log.Warn("Found ignored arguments", slog.Any("args", args))
+	args, err := parser.Parse()
+
+	// with --print-config, missing required options are reported after printing
+	if err != nil && !(configOpts.PrintConfig && isRequiredError(err)) {
+		return err
 	}
 
-	// validate all input values after argument parsing
-	v := validator.New()
+	startup_base.SetEffectiveConfig(effectiveConfig(parser, fileValues))
 
-	// validate host:port values
-	_ = v.RegisterValidation("hostport", func(fl validator.FieldLevel) bool {
-		value := fl.Field().Interface().(string)
-		_, _, err := net.SplitHostPort(value)
-		return err == nil
-	})
+	if configOpts.PrintConfig {
+		// print before validating, the configuration helps to find a missing or invalid option
+		printErr := writeEffectiveConfig(os.Stdout, startup_base.EffectiveConfig())
+		startup_base.FatalOnError(printErr, "Print effective configuration")
 
-	if err := v.Struct(opts); err != nil {
-		return fmt.Errorf("validate options struct: %w", err)
+		if err == nil {
+			err = resolveAndValidate(parser, opts)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		os.Exit(0)
 	}
 
-	seen := make(map[reflect.Type]reflect.Value)
+	if err := resolveAndValidate(parser, opts); err != nil {
+		return err
+	}
 
-	// now do the initialization for all fields
-	value := reflect.ValueOf(opts).Elem()
-	for fieldValue := range fieldsIter(value) {
-		if fieldValue.Kind() != reflect.Struct {
-			continue
-		}
+	if len(args) > 0 && (options&flags.IgnoreUnknown) != flags.None {
+		log.Warn("Found ignored arguments", slog.Any("args", args))
+	}
 
-		// we remember the values we've seen so we can inject those into
-		// the Initializer() functions
-		seen[fieldValue.Type()] = fieldValue
-		seen[reflect.PointerTo(fieldValue.Type())] = fieldValue.Addr()
+	// operational commands, like --postgres-migrate=status, run instead of the application
+	ran, err := runCommands(reflect.ValueOf(opts).Elem())
+	if err != nil {
+		return err
+	}
 
-		if init := findInitializerMethod(fieldValue); init.IsValid() {
-			var inputValues []reflect.Value
+	if ran {
+		os.Exit(0)
+	}
 
-			initType := init.Type()
-			for in := range initType.Ins() {
-				var inputValue reflect.Value
+	// now do the initialization for all fields
+	if err := initializeOptions(ctx, reflect.ValueOf(opts).Elem()); err != nil {
+		return err
+	}
 
-				switch {
-				case in == reflect.TypeFor[context.Context]():
-					inputValue = reflect.ValueOf(ctx)
+	// start all registered components
+	if err := startup_base.DefaultLifecycle.Start(ctx); err != nil {
+		return fmt.Errorf("start lifecycle: %w", err)
+	}
 
-				case seen[in].IsValid():
-					inputValue = seen[in]
+	if configOpts.StopOnSignal {
+		// the lifecycle is process wide, cancelling ctx must not stop it
+		startup_base.DefaultLifecycle.StopOnSignal(context.WithoutCancel(ctx))
+	}
 
-				case in.Kind() == reflect.Pointer:
-					// get T instead of *T
-					inType := in.Elem()
+	if configOpts.SecretReloadInterval > 0 {
+		startup_base.WatchSecretFiles(ctx, configOpts.SecretReloadInterval)
+	}
 
-					// pointers indicate optional values
-					if value := seen[inType]; value.IsValid() {
-						// set inputValue to (*T)(&value)
-						inputValue = value.Addr()
-					} else {
-						// set inputValue to (*T)(nil)
-						inputValue = reflect.New(in).Elem()
-					}
+	return nil
+}
 
-				default:
-					startup_base.Panicf("Can not find value of type %q to inject into %q",
-						in.String(), fieldValue.Type())
-				}
+// runCommands calls the RunCommand method of all struct fields in opts. It returns true if
+// one of them ran a command, the application must not start up then.
+func runCommands(opts reflect.Value) (bool, error) {
+	var ran bool
 
-				inputValues = append(inputValues, inputValue)
-			}
+	for fieldValue := range fieldsIter(opts) {
+		method := findMethod(fieldValue, "RunCommand")
+		if !method.IsValid() {
+			continue
+		}
 
-			if _, ok := fieldValue.Interface().(startup_base.BaseOptions); !ok {
-				log.Info("Calling Initialize()", slog.String("type", fieldValue.Type().String()))
-			}
+		runCommand, ok := method.Interface().(func() (bool, error))
+		if !ok {
+			return false, fmt.Errorf("%s.RunCommand() must return (bool, error)", fieldValue.Type())
+		}
 
-			init.Call(inputValues)
+		fieldRan, err := runCommand()
+		if err != nil {
+			return false, err
 		}
+
+		ran = ran || fieldRan
 	}
 
-	return nil
+	return ran, nil
 }
 
 func propagateInputs(opts any) {
@@ -158,6 +188,34 @@ func propagateInputs(opts any) {
 	}
 }
 
+// resolveAndValidate reads secret option values from files and validates the options struct.
+func resolveAndValidate(parser *flags.Parser, opts any) error {
+	if err := resolveSecretFiles(parser, opts); err != nil {
+		return err
+	}
+
+	v := validator.New()
+
+	// validate host:port values
+	_ = v.RegisterValidation("hostport", func(fl validator.FieldLevel) bool {
+		value := fl.Field().Interface().(string)
+		_, _, err := net.SplitHostPort(value)
+		return err == nil
+	})
+
+	if err := v.Struct(opts); err != nil {
+		return fmt.Errorf("validate options struct: %w", err)
+	}
+
+	return nil
+}
+
+// isRequiredError returns true if parsing failed because a required option is missing.
+func isRequiredError(err error) bool {
+	flagsErr, ok := errors.AsType[*flags.Error](err)
+	return ok && flagsErr.Type == flags.ErrRequired
+}
+
 func fieldsIter(value reflect.Value) iter.Seq[reflect.Value] {
 	return func(yield func(reflect.Value) bool) {
 		if value.Kind() != reflect.Struct {
@@ -187,9 +245,14 @@ func fieldsIter(value reflect.Value) iter.Seq[reflect.Value] {
 }
 
 func findInitializerMethod(v reflect.Value) reflect.Value {
-	m := v.MethodByName("Initialize")
+	return findMethod(v, "Initialize")
+}
+
+// findMethod finds the method with the given name on v or on a pointer to v.
+func findMethod(v reflect.Value, name string) reflect.Value {
+	m := v.MethodByName(name)
 	if !m.IsValid() && v.CanAddr() {
-		m = v.Addr().MethodByName("Initialize")
+		m = v.Addr().MethodByName(name)
 	}
 
 	if !m.IsValid() {
@@ -206,9 +269,9 @@ func findInitializerMethod(v reflect.Value) reflect.Value {
 			if !sf.Anonymous {
 				continue
 			}
-			em := field.MethodByName("Initialize")
+			em := field.MethodByName(name)
 			if !em.IsValid() && field.CanAddr() {
-				em = field.Addr().MethodByName("Initialize")
+				em = field.Addr().MethodByName(name)
 			}
 			if em.IsValid() && m.Pointer() == em.Pointer() {
 				return reflect.Value{}
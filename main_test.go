@@ -214,3 +214,39 @@ func TestFieldsIter_EmbeddedInitializedBeforeParent(t *testing.T) {
 			gcIdx, midIdx, structOrder)
 	}
 }
+
+type commandOptions struct {
+	ran bool
+	err error
+}
+
+func (o *commandOptions) RunCommand() (bool, error) {
+	return o.ran, o.err
+}
+
+func TestRunCommands(t *testing.T) {
+	var opts struct {
+		Idle    commandOptions
+		Command commandOptions
+	}
+
+	ran, err := runCommands(reflect.ValueOf(&opts).Elem())
+	if err != nil || ran {
+		t.Fatalf("expected no command to run, got %v, %v", ran, err)
+	}
+
+	opts.Command.ran = true
+
+	ran, err = runCommands(reflect.ValueOf(&opts).Elem())
+	if err != nil || !ran {
+		t.Fatalf("expected a command to run, got %v, %v", ran, err)
+	}
+
+	errCommand := errors.New("command failed")
+	opts.Command.err = errCommand
+
+	_, err = runCommands(reflect.ValueOf(&opts).Elem())
+	if !errors.Is(err, errCommand) {
+		t.Fatalf("expected the error of the command, got %v", err)
+	}
+}
@@ -0,0 +1,210 @@
+package startup
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/flachnetz/startup/v2/startup_base"
+	"github.com/jessevdk/go-flags"
+)
+
+const fileReferencePrefix = "file://"
+
+// secretFileEnvKey returns the name of the environment variable holding a path to a
+// file with the value of the option, as configured with the `file-env` tag.
+// The env namespace of the option applies to the file-env key too.
+func secretFileEnvKey(option *flags.Option) string {
+	fileEnv := option.Field().Tag.Get("file-env")
+	if fileEnv == "" {
+		return ""
+	}
+
+	namespace := strings.TrimSuffix(option.EnvKeyWithNamespace(), option.EnvDefaultKey)
+	return namespace + fileEnv
+}
+
+// secretFileOf returns the path of the file the option value should be read from.
+// The value is taken from the file referenced in the `file-env` variable, if the
+// option was not set as flag or using its own environment variable. Otherwise, a
+// value in the form of file:///path/to/secret is used as reference to a file.
+// Only options with a `secret` or `file-env` tag support file references.
+func secretFileOf(option *flags.Option) (string, bool) {
+	fileEnvKey := secretFileEnvKey(option)
+	if fileEnvKey == "" && option.Field().Tag.Get("secret") != "true" {
+		return "", false
+	}
+
+	if fileEnvKey != "" {
+		setExplicitly := option.IsSet() && !option.IsSetDefault() ||
+			option.EnvKeyWithNamespace() != "" && isEnvSet(option.EnvKeyWithNamespace())
+
+		if path := os.Getenv(fileEnvKey); path != "" && !setExplicitly {
+			return path, true
+		}
+	}
+
+	value, ok := option.Value().(string)
+	if !ok || !strings.HasPrefix(value, fileReferencePrefix) {
+		return "", false
+	}
+
+	return strings.TrimPrefix(value, fileReferencePrefix), true
+}
+
+// resolveSecretFiles replaces file references in option values with the content
+// of the referenced file. Must be called after the parser ran. The files are
+// registered with the fields in opts backing the options, so rotated values can be
+// picked up using startup_base.ReloadableSecret.
+//
+// For secret []string options, each value in the form of file:///path/to/secret is
+// replaced. The `file-env` tag is only supported for string options.
+func resolveSecretFiles(parser *flags.Parser, opts any) error {
+	for _, option := range optionsIter(parser.Command.Group) {
+		var err error
+		if _, isSlice := option.Value().([]string); isSlice {
+			err = resolveSecretFileValues(parser, opts, option)
+		} else {
+			err = resolveSecretFile(parser, opts, option)
+		}
+
+		if err != nil {
+			return fmt.Errorf("option --%s: %w", option.LongNameWithNamespace(), err)
+		}
+	}
+
+	return nil
+}
+
+func resolveSecretFile(parser *flags.Parser, opts any, option *flags.Option) error {
+	path, ok := secretFileOf(option)
+	if !ok {
+		return nil
+	}
+
+	if _, isString := option.Value().(string); !isString {
+		return errors.New("file references are only supported for string options")
+	}
+
+	value, err := startup_base.ReadSecretFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := option.Set(&value); err != nil {
+		return err
+	}
+
+	if field, ok := optionField(reflect.ValueOf(opts), parser.NamespaceDelimiter, option).(*string); ok {
+		startup_base.RegisterSecretFile(field, path)
+	}
+
+	return nil
+}
+
+// resolveSecretFileValues replaces the file references in the values of a secret []string option.
+func resolveSecretFileValues(parser *flags.Parser, opts any, option *flags.Option) error {
+	if option.Field().Tag.Get("secret") != "true" {
+		return nil
+	}
+
+	if secretFileEnvKey(option) != "" {
+		return errors.New("file-env is only supported for string options")
+	}
+
+	values, ok := optionField(reflect.ValueOf(opts), parser.NamespaceDelimiter, option).(*[]string)
+	if !ok {
+		return nil
+	}
+
+	for idx, value := range *values {
+		path, ok := strings.CutPrefix(value, fileReferencePrefix)
+		if !ok {
+			continue
+		}
+
+		value, err := startup_base.ReadSecretFile(path)
+		if err != nil {
+			return err
+		}
+
+		(*values)[idx] = value
+		startup_base.RegisterSecretFile(&(*values)[idx], path)
+	}
+
+	return nil
+}
+
+// optionField returns a pointer to the field in opts that backs the option, or nil
+// if the option is not part of opts. go-flags does not expose the field, so it is
+// found by its long name, including the namespaces of the groups it is part of.
+func optionField(opts reflect.Value, delimiter string, option *flags.Option) any {
+	longName := option.LongNameWithNamespace()
+	if longName == "" {
+		return nil
+	}
+
+	for field, name := range optionNamesIter(opts, delimiter, "") {
+		if name != longName {
+			continue
+		}
+
+		if !field.CanAddr() || !field.CanInterface() {
+			// part of an unexported embedded struct
+			return nil
+		}
+
+		return field.Addr().Interface()
+	}
+
+	return nil
+}
+
+// optionNamesIter yields the fields of options in the struct behind value, together
+// with their long name. Like in go-flags, only fields with a `group` tag start a new
+// namespace.
+func optionNamesIter(value reflect.Value, delimiter, namespace string) iter.Seq2[reflect.Value, string] {
+	return func(yield func(reflect.Value, string) bool) {
+		for value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				return
+			}
+
+			value = value.Elem()
+		}
+
+		if value.Kind() != reflect.Struct {
+			return
+		}
+
+		for sf, field := range value.Fields() {
+			if sf.PkgPath != "" && !sf.Anonymous || sf.Tag.Get("no-flag") != "" {
+				continue
+			}
+
+			if long := sf.Tag.Get("long"); long != "" {
+				if !yield(field, namespace+long) {
+					return
+				}
+
+				continue
+			}
+
+			nested := namespace
+			if _, isGroup := sf.Tag.Lookup("group"); isGroup {
+				if ns := sf.Tag.Get("namespace"); ns != "" {
+					nested = namespace + ns + delimiter
+				}
+			}
+
+			for sub, name := range optionNamesIter(field, delimiter, nested) {
+				if !yield(sub, name) {
+					return
+				}
+			}
+		}
+	}
+}
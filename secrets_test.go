@@ -0,0 +1,176 @@
+package startup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/flachnetz/startup/v2/startup_base"
+	"github.com/stretchr/testify/require"
+)
+
+type secretsTestOptions struct {
+	Password string `long:"password" env:"TEST_PASSWORD" secret:"true"`
+	Token    string `long:"token" env:"TEST_TOKEN" file-env:"TEST_TOKEN_FILE"`
+	Address  string `long:"address" env:"TEST_ADDRESS"`
+}
+
+func TestSecretFileReference(t *testing.T) {
+	withCleanConfigEnv(t)
+
+	dir := t.TempDir()
+	password := writeConfigFile(t, dir, "password", "hunter2\n")
+	address := writeConfigFile(t, dir, "address", "localhost")
+
+	old := os.Args
+	os.Args = []string{"cmd", "--password=file://" + password, "--address=file://" + address}
+	t.Cleanup(func() { os.Args = old })
+
+	var opts secretsTestOptions
+	require.NoError(t, ParseCommandLine(t.Context(), &opts))
+
+	require.Equal(t, "hunter2", opts.Password)
+
+	// options that are neither secret nor have a file-env are not resolved
+	require.Equal(t, "file://"+address, opts.Address)
+
+	// the effective config shows the reference, not the secret
+	values := effectiveConfigByName(t)
+	require.Equal(t, "file://"+password, values["password"].Value)
+	require.Equal(t, startup_base.ConfigSourceFlag, values["password"].Source)
+}
+
+func TestSecretFileEnv(t *testing.T) {
+	withEmptyArgs(t)
+	withCleanConfigEnv(t)
+
+	token := writeConfigFile(t, t.TempDir(), "token", "secret-token\n")
+	t.Setenv("TEST_TOKEN_FILE", token)
+
+	var opts secretsTestOptions
+	require.NoError(t, ParseCommandLine(t.Context(), &opts))
+
+	require.Equal(t, "secret-token", opts.Token)
+
+	values := effectiveConfigByName(t)
+	require.Equal(t, "file://"+token, values["token"].Value)
+	require.Equal(t, "TEST_TOKEN_FILE", values["token"].Env)
+	require.Equal(t, startup_base.ConfigSourceEnv, values["token"].Source)
+
+	// the value from the environment takes precedence over the file
+	t.Setenv("TEST_TOKEN", "direct-token")
+
+	opts = secretsTestOptions{}
+	require.NoError(t, ParseCommandLine(t.Context(), &opts))
+	require.Equal(t, "direct-token", opts.Token)
+}
+
+func TestSecretFileMissing(t *testing.T) {
+	withCleanConfigEnv(t)
+
+	old := os.Args
+	os.Args = []string{"cmd", "--password=file:///does/not/exist"}
+	t.Cleanup(func() { os.Args = old })
+
+	var opts secretsTestOptions
+	err := ParseCommandLine(t.Context(), &opts)
+	require.ErrorContains(t, err, "/does/not/exist")
+}
+
+func TestSecretFileRegisteredWithOption(t *testing.T) {
+	withCleanConfigEnv(t)
+
+	dir := t.TempDir()
+	password := writeConfigFile(t, dir, "password", "same-secret")
+	token := writeConfigFile(t, dir, "token", "same-secret")
+
+	old := os.Args
+	os.Args = []string{"cmd", "--password=file://" + password, "--address=same-secret"}
+	t.Cleanup(func() { os.Args = old })
+
+	t.Setenv("TEST_TOKEN_FILE", token)
+
+	var opts secretsTestOptions
+	require.NoError(t, ParseCommandLine(t.Context(), &opts))
+
+	currentPassword := startup_base.ReloadableSecret(&opts.Password)
+	currentToken := startup_base.ReloadableSecret(&opts.Token)
+	currentAddress := startup_base.ReloadableSecret(&opts.Address)
+
+	// rotating one file does not affect options with the same value
+	writeConfigFile(t, dir, "token", "rotated-secret")
+
+	startup_base.WatchSecretFiles(t.Context(), 10*time.Millisecond)
+
+	require.Eventually(t, func() bool { return currentToken() == "rotated-secret" }, time.Second, 10*time.Millisecond)
+	require.Equal(t, "same-secret", currentPassword())
+	require.Equal(t, "same-secret", currentAddress())
+}
+
+func TestSecretFileRegisteredWithNestedOption(t *testing.T) {
+	withCleanConfigEnv(t)
+
+	url := writeConfigFile(t, t.TempDir(), "url", "postgres://first")
+
+	old := os.Args
+	os.Args = []string{"cmd", "--db-url=file://" + url}
+	t.Cleanup(func() { os.Args = old })
+
+	var opts struct {
+		Database *struct {
+			URL string `long:"url" secret:"true"`
+		} `group:"Database" namespace:"db"`
+	}
+
+	require.NoError(t, ParseCommandLine(t.Context(), &opts))
+	require.Equal(t, "postgres://first", opts.Database.URL)
+
+	current := startup_base.ReloadableSecret(&opts.Database.URL)
+
+	writeConfigFile(t, filepath.Dir(url), "url", "postgres://second")
+	startup_base.WatchSecretFiles(t.Context(), 10*time.Millisecond)
+
+	require.Eventually(t, func() bool { return current() == "postgres://second" }, time.Second, 10*time.Millisecond)
+}
+
+func TestSecretFileWithChoices(t *testing.T) {
+	withEmptyArgs(t)
+	withCleanConfigEnv(t)
+
+	mode := writeConfigFile(t, t.TempDir(), "mode", "strict\n")
+	t.Setenv("TEST_MODE_FILE", mode)
+
+	var opts struct {
+		Mode string `long:"mode" env:"TEST_MODE" default:"lenient" choice:"lenient" choice:"strict" file-env:"TEST_MODE_FILE"`
+	}
+
+	require.NoError(t, ParseCommandLine(t.Context(), &opts))
+	require.Equal(t, "strict", opts.Mode)
+}
+
+func TestSecretFileReferenceInSlice(t *testing.T) {
+	withCleanConfigEnv(t)
+
+	replica := writeConfigFile(t, t.TempDir(), "replica", "postgres://first")
+
+	old := os.Args
+	os.Args = []string{"cmd", "--db-replica=file://" + replica, "--db-replica=postgres://plain"}
+	t.Cleanup(func() { os.Args = old })
+
+	var opts struct {
+		Database struct {
+			Replicas []string `long:"replica" secret:"true"`
+		} `group:"Database" namespace:"db"`
+	}
+
+	require.NoError(t, ParseCommandLine(t.Context(), &opts))
+	require.Equal(t, []string{"postgres://first", "postgres://plain"}, opts.Database.Replicas)
+
+	current := startup_base.ReloadableSecret(&opts.Database.Replicas[0])
+
+	writeConfigFile(t, filepath.Dir(replica), "replica", "postgres://second")
+	startup_base.WatchSecretFiles(t.Context(), 10*time.Millisecond)
+
+	require.Eventually(t, func() bool { return current() == "postgres://second" }, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,44 @@
+package startup_base
+
+import (
+	"sync/atomic"
+)
+
+// ConfigSource describes where the effective value of an option came from.
+type ConfigSource string
+
+const (
+	ConfigSourceDefault ConfigSource = "default"
+	ConfigSourceFile    ConfigSource = "file"
+	ConfigSourceEnv     ConfigSource = "env"
+	ConfigSourceFlag    ConfigSource = "flag"
+)
+
+// ConfigValue is the effective value of a single option after the command line was parsed.
+// Values of options tagged with `secret:"true"` are redacted.
+type ConfigValue struct {
+	Group  string       `json:"group,omitempty"`
+	Name   string       `json:"name"`
+	Env    string       `json:"env,omitempty"`
+	Value  string       `json:"value"`
+	Source ConfigSource `json:"source"`
+	Secret bool         `json:"secret,omitempty"`
+}
+
+var effectiveConfig atomic.Pointer[[]ConfigValue]
+
+// SetEffectiveConfig stores the effective configuration. This is called by
+// startup.ParseCommandLineWithOptions once all options are resolved.
+func SetEffectiveConfig(values []ConfigValue) {
+	effectiveConfig.Store(&values)
+}
+
+// EffectiveConfig returns the effective configuration of the application,
+// or nil if the command line was not parsed yet.
+func EffectiveConfig() []ConfigValue {
+	if values := effectiveConfig.Load(); values != nil {
+		return *values
+	}
+
+	return nil
+}
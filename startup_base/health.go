@@ -0,0 +1,250 @@
+package startup_base
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type HealthCheckType int
+
+const (
+	// HealthReadiness checks decide if the application can serve traffic.
+	HealthReadiness HealthCheckType = iota
+
+	// HealthLiveness checks decide if the application needs to be restarted.
+	// Liveness checks are part of the readiness too.
+	HealthLiveness
+)
+
+type HealthStatus string
+
+const (
+	HealthStatusOK      HealthStatus = "ok"
+	HealthStatusFailing HealthStatus = "failing"
+)
+
+// DefaultHealthCheckTimeout is used if a HealthCheck does not define a timeout.
+var DefaultHealthCheckTimeout = 2 * time.Second
+
+// DefaultHealthCheckCacheDuration is used if a HealthCheck does not define how long
+// its result should be cached.
+var DefaultHealthCheckCacheDuration = 5 * time.Second
+
+// HealthCheck checks one component of the application.
+type HealthCheck struct {
+	// Name of the check. A check registered with the same name replaces the previous one.
+	Name string
+
+	Type HealthCheckType
+
+	// Timeout for a single execution of Check. Defaults to DefaultHealthCheckTimeout.
+	Timeout time.Duration
+
+	// Duration the result is cached. Defaults to DefaultHealthCheckCacheDuration.
+	CacheDuration time.Duration
+
+	// Check returns an error if the component is not healthy.
+	Check func(ctx context.Context) error
+}
+
+// HealthCheckResult is the result of a single HealthCheck.
+type HealthCheckResult struct {
+	Name      string       `json:"name"`
+	Status    HealthStatus `json:"status"`
+	Error     string       `json:"error,omitempty"`
+	LatencyMs float64      `json:"latencyMs,omitempty"`
+	CheckedAt time.Time    `json:"checkedAt,omitzero"`
+}
+
+// HealthReport is the result of all checks of a type.
+type HealthReport struct {
+	Status HealthStatus        `json:"status"`
+	Checks []HealthCheckResult `json:"checks"`
+}
+
+// Healthy returns true if all checks succeeded.
+func (r HealthReport) Healthy() bool {
+	return r.Status == HealthStatusOK
+}
+
+// Redacted returns a copy of the report with only the name and status of each
+// check. Error messages might contain hosts or addresses of backing services and
+// should not be served to unauthenticated clients.
+func (r HealthReport) Redacted() HealthReport {
+	checks := make([]HealthCheckResult, 0, len(r.Checks))
+	for _, check := range r.Checks {
+		checks = append(checks, HealthCheckResult{Name: check.Name, Status: check.Status})
+	}
+
+	return HealthReport{Status: r.Status, Checks: checks}
+}
+
+type healthEntry struct {
+	check HealthCheck
+
+	mu         sync.Mutex
+	result     HealthCheckResult
+	validUntil time.Time
+}
+
+// HealthRegistry holds the health checks of the application.
+type HealthRegistry struct {
+	mu      sync.Mutex
+	entries []*healthEntry
+
+	draining atomic.Bool
+}
+
+// DefaultHealthRegistry is the registry the startup modules register their checks
+// with. It is served by startup_http on /health/live and /health/ready, see --http-health-path.
+var DefaultHealthRegistry = &HealthRegistry{}
+
+// RegisterHealthCheck registers the check with the DefaultHealthRegistry.
+func RegisterHealthCheck(check HealthCheck) {
+	DefaultHealthRegistry.Register(check)
+}
+
+// UnregisterHealthCheck removes the check with the given name from the DefaultHealthRegistry.
+func UnregisterHealthCheck(name string) {
+	DefaultHealthRegistry.Unregister(name)
+}
+
+// Register adds a check to the registry. An existing check with the same name is replaced.
+func (r *HealthRegistry) Register(check HealthCheck) {
+	if check.Timeout <= 0 {
+		check.Timeout = DefaultHealthCheckTimeout
+	}
+
+	if check.CacheDuration <= 0 {
+		check.CacheDuration = DefaultHealthCheckCacheDuration
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry := &healthEntry{check: check}
+
+	idx := slices.IndexFunc(r.entries, func(e *healthEntry) bool { return e.check.Name == check.Name })
+	if idx >= 0 {
+		r.entries[idx] = entry
+	} else {
+		r.entries = append(r.entries, entry)
+	}
+}
+
+// Unregister removes the check with the given name, e.g. once the component it checks was closed.
+func (r *HealthRegistry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = slices.DeleteFunc(r.entries, func(e *healthEntry) bool { return e.check.Name == name })
+}
+
+// SetDraining marks the application as shutting down. From now on the readiness
+// fails, so that no new traffic is routed to the application.
+func (r *HealthRegistry) SetDraining() {
+	r.draining.Store(true)
+}
+
+// Check runs all checks of the given type in parallel. Results are taken from
+// the cache, if still valid. Liveness checks are included in the readiness report.
+func (r *HealthRegistry) Check(ctx context.Context, typ HealthCheckType) HealthReport {
+	r.mu.Lock()
+	var entries []*healthEntry
+	for _, entry := range r.entries {
+		if typ == HealthReadiness || entry.check.Type == typ {
+			entries = append(entries, entry)
+		}
+	}
+	r.mu.Unlock()
+
+	results := make([]HealthCheckResult, len(entries))
+
+	var wg sync.WaitGroup
+	for idx, entry := range entries {
+		wg.Go(func() {
+			results[idx] = entry.run(ctx)
+		})
+	}
+
+	wg.Wait()
+
+	if typ == HealthReadiness && r.draining.Load() {
+		results = append(results, HealthCheckResult{
+			Name:      "shutdown",
+			Status:    HealthStatusFailing,
+			Error:     "application is shutting down",
+			CheckedAt: time.Now(),
+		})
+	}
+
+	report := HealthReport{Status: HealthStatusOK, Checks: results}
+	for _, result := range results {
+		if result.Status != HealthStatusOK {
+			report.Status = HealthStatusFailing
+		}
+	}
+
+	return report
+}
+
+func (e *healthEntry) run(ctx context.Context) HealthCheckResult {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(e.validUntil) {
+		return e.result
+	}
+
+	// the result is cached for all callers, so a client that disconnects must
+	// not cancel the check. It is only bounded by its own timeout.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), e.check.Timeout)
+	defer cancel()
+
+	err := runHealthCheck(ctx, e.check.Check)
+
+	e.result = HealthCheckResult{
+		Name:      e.check.Name,
+		Status:    HealthStatusOK,
+		LatencyMs: float64(time.Since(now).Microseconds()) / 1000,
+		CheckedAt: now,
+	}
+
+	if err != nil {
+		e.result.Status = HealthStatusFailing
+		e.result.Error = err.Error()
+	}
+
+	e.validUntil = now.Add(e.check.CacheDuration)
+
+	return e.result
+}
+
+// runHealthCheck runs the check and stops waiting for it once the context is done,
+// so a hanging check can not block the health endpoint.
+func runHealthCheck(ctx context.Context, check func(ctx context.Context) error) error {
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errCh <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+
+		errCh <- check(ctx)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+
+	case <-ctx.Done():
+		return fmt.Errorf("check timed out: %w", ctx.Err())
+	}
+}
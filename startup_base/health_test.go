@@ -0,0 +1,160 @@
+package startup_base
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthRegistryCheck(t *testing.T) {
+	var registry HealthRegistry
+
+	registry.Register(HealthCheck{
+		Name:  "database",
+		Check: func(ctx context.Context) error { return nil },
+	})
+
+	registry.Register(HealthCheck{
+		Name:  "process",
+		Type:  HealthLiveness,
+		Check: func(ctx context.Context) error { return nil },
+	})
+
+	live := registry.Check(t.Context(), HealthLiveness)
+	require.True(t, live.Healthy())
+	require.Len(t, live.Checks, 1)
+	require.Equal(t, "process", live.Checks[0].Name)
+
+	// liveness checks are part of the readiness
+	ready := registry.Check(t.Context(), HealthReadiness)
+	require.True(t, ready.Healthy())
+	require.Len(t, ready.Checks, 2)
+}
+
+func TestHealthRegistryFailingCheck(t *testing.T) {
+	var registry HealthRegistry
+
+	registry.Register(HealthCheck{
+		Name:  "database",
+		Check: func(ctx context.Context) error { return errors.New("connection refused") },
+	})
+
+	registry.Register(HealthCheck{
+		Name:    "slow",
+		Timeout: 10 * time.Millisecond,
+		Check: func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		},
+	})
+
+	report := registry.Check(t.Context(), HealthReadiness)
+	require.False(t, report.Healthy())
+
+	require.Equal(t, HealthStatusFailing, report.Checks[0].Status)
+	require.Equal(t, "connection refused", report.Checks[0].Error)
+
+	require.Equal(t, HealthStatusFailing, report.Checks[1].Status)
+	require.Contains(t, report.Checks[1].Error, "timed out")
+}
+
+func TestHealthRegistryCachesResults(t *testing.T) {
+	var registry HealthRegistry
+
+	var calls atomic.Int32
+	registry.Register(HealthCheck{
+		Name:          "counting",
+		CacheDuration: time.Hour,
+		Check: func(ctx context.Context) error {
+			calls.Add(1)
+			return nil
+		},
+	})
+
+	first := registry.Check(t.Context(), HealthReadiness)
+	second := registry.Check(t.Context(), HealthReadiness)
+
+	require.EqualValues(t, 1, calls.Load())
+	require.Equal(t, first.Checks[0].CheckedAt, second.Checks[0].CheckedAt)
+
+	// registering with the same name replaces the check and its cache
+	registry.Register(HealthCheck{
+		Name:  "counting",
+		Check: func(ctx context.Context) error { return errors.New("replaced") },
+	})
+
+	report := registry.Check(t.Context(), HealthReadiness)
+	require.Len(t, report.Checks, 1)
+	require.Equal(t, "replaced", report.Checks[0].Error)
+}
+
+func TestHealthRegistryDraining(t *testing.T) {
+	var registry HealthRegistry
+
+	registry.Register(HealthCheck{
+		Name:  "database",
+		Check: func(ctx context.Context) error { return nil },
+	})
+
+	registry.SetDraining()
+
+	ready := registry.Check(t.Context(), HealthReadiness)
+	require.False(t, ready.Healthy())
+	require.Equal(t, "shutdown", ready.Checks[1].Name)
+
+	// the application is still alive while draining
+	live := registry.Check(t.Context(), HealthLiveness)
+	require.True(t, live.Healthy())
+}
+
+func TestHealthRegistryUnregister(t *testing.T) {
+	var registry HealthRegistry
+
+	registry.Register(HealthCheck{
+		Name:  "consumer",
+		Check: func(ctx context.Context) error { return errors.New("consumer not running") },
+	})
+
+	require.False(t, registry.Check(t.Context(), HealthReadiness).Healthy())
+
+	registry.Unregister("consumer")
+
+	report := registry.Check(t.Context(), HealthReadiness)
+	require.True(t, report.Healthy())
+	require.Empty(t, report.Checks)
+}
+
+func TestHealthRegistryIgnoresCanceledRequest(t *testing.T) {
+	var registry HealthRegistry
+
+	registry.Register(HealthCheck{
+		Name:          "database",
+		CacheDuration: time.Hour,
+		Check: func(ctx context.Context) error {
+			return ctx.Err()
+		},
+	})
+
+	// a client that disconnected must not cache a failure for all other callers
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	require.True(t, registry.Check(ctx, HealthReadiness).Healthy())
+}
+
+func TestHealthReportRedacted(t *testing.T) {
+	var registry HealthRegistry
+
+	registry.Register(HealthCheck{
+		Name:  "database",
+		Check: func(ctx context.Context) error { return errors.New("dial tcp db.internal:5432: connection refused") },
+	})
+
+	report := registry.Check(t.Context(), HealthReadiness).Redacted()
+	require.False(t, report.Healthy())
+	require.Equal(t, []HealthCheckResult{{Name: "database", Status: HealthStatusFailing}}, report.Checks)
+}
@@ -0,0 +1,331 @@
+package startup_base
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	sl "github.com/flachnetz/startup/v2/startup_logging"
+)
+
+// Names of the hooks registered by the startup modules. Use them in Hook.DependsOn
+// to order your own hooks relative to the built-in ones.
+const (
+	HookHTTP             = "http"
+	HookHTTPAdmin        = "http-admin"
+	HookKafkaConsumer    = "kafka-consumer"
+	HookEventSender      = "event-sender"
+	HookKafkaProducer    = "kafka-producer"
+	HookKafkaAdmin       = "kafka-admin"
+	HookOutburst         = "outburst"
+	HookHistory          = "history"
+	HookMetrics          = "metrics"
+	HookTracing          = "tracing"
+	HookPostgres         = "postgres"
+	HookPostgresReplicas = "postgres-replicas"
+)
+
+// DefaultHookTimeout is the time a single hook may take to start or stop if
+// the hook does not specify its own timeout.
+var DefaultHookTimeout = 10 * time.Second
+
+// Hook describes a component whose start and stop is managed by a Lifecycle.
+type Hook struct {
+	// Name of the component. Multiple hooks may share the same name.
+	Name string
+
+	// Names of the hooks this hook depends on. Dependencies are started before
+	// and stopped after this hook. Unknown names are ignored, which makes
+	// dependencies on optional components possible.
+	DependsOn []string
+
+	// Timeout for OnStart and OnStop. Defaults to DefaultHookTimeout.
+	Timeout time.Duration
+
+	OnStart func(ctx context.Context) error
+	OnStop  func(ctx context.Context) error
+}
+
+// Lifecycle starts and stops registered hooks in dependency order.
+type Lifecycle struct {
+	mu      sync.Mutex
+	hooks   []Hook
+	started bool
+	stopped bool
+
+	stopOnce   sync.Once
+	signalOnce sync.Once
+	done       chan struct{}
+
+	// set once someone waits for Done and takes care of exiting the process
+	awaited atomic.Bool
+}
+
+// DefaultLifecycle is the lifecycle used by the startup modules. It is started by
+// startup.ParseCommandLineWithOptions. It is stopped on SIGINT or SIGTERM by the http
+// server of startup_http, or with --stop-on-signal.
+var DefaultLifecycle = &Lifecycle{}
+
+// RegisterHook registers the hook with the DefaultLifecycle.
+func RegisterHook(hook Hook) {
+	DefaultLifecycle.Append(hook)
+}
+
+// Append registers a new hook. If the lifecycle was already started, the OnStart
+// function of the hook is called directly.
+func (l *Lifecycle) Append(hook Hook) {
+	l.mu.Lock()
+
+	if l.stopped {
+		l.mu.Unlock()
+		log.Warn("Lifecycle already stopped, ignoring hook", slog.String("hook", hook.Name))
+		return
+	}
+
+	l.hooks = append(l.hooks, hook)
+	started := l.started
+
+	l.mu.Unlock()
+
+	if started && hook.OnStart != nil {
+		if err := runHook(context.Background(), hook, "start", hook.OnStart); err != nil {
+			log.Warn("Failed to start hook", slog.String("hook", hook.Name), sl.Error(err))
+		}
+	}
+}
+
+// Start calls the OnStart function of all hooks in dependency order.
+// The first error aborts the start. Hooks registered after Start
+// are started directly by Append.
+func (l *Lifecycle) Start(ctx context.Context) error {
+	l.mu.Lock()
+	if l.started {
+		l.mu.Unlock()
+		return nil
+	}
+
+	l.started = true
+	hooks := slices.Clone(l.hooks)
+	l.mu.Unlock()
+
+	ordered, err := orderHooks(hooks)
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range ordered {
+		if hook.OnStart == nil {
+			continue
+		}
+
+		if err := runHook(ctx, hook, "start", hook.OnStart); err != nil {
+			return fmt.Errorf("start %q: %w", hook.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Stop calls the OnStop function of all hooks in reverse dependency order. A failing
+// or timed out hook does not prevent the remaining hooks from being stopped.
+// Calling Stop more than once has no effect.
+func (l *Lifecycle) Stop(ctx context.Context) error {
+	var err error
+
+	l.stopOnce.Do(func() {
+		defer close(l.doneCh())
+
+		l.mu.Lock()
+		l.stopped = true
+		hooks := slices.Clone(l.hooks)
+		l.mu.Unlock()
+
+		ordered, orderErr := orderHooks(hooks)
+		if orderErr != nil {
+			// still try our best and stop in reverse registration order
+			log.Warn("Can not order hooks, using registration order", sl.Error(orderErr))
+			ordered = hooks
+		}
+
+		for _, hook := range slices.Backward(ordered) {
+			if hook.OnStop == nil {
+				continue
+			}
+
+			log.Info("Stopping component", slog.String("hook", hook.Name))
+
+			if hookErr := runHook(ctx, hook, "stop", hook.OnStop); hookErr != nil {
+				log.Warn("Failed to stop component", slog.String("hook", hook.Name), sl.Error(hookErr))
+				err = errors.Join(err, fmt.Errorf("stop %q: %w", hook.Name, hookErr))
+			}
+		}
+
+		log.Info("All components stopped")
+	})
+
+	return err
+}
+
+// Done returns a channel that is closed once Stop has completed. The caller is
+// expected to exit the process after that, e.g. by returning from main. If nobody
+// waits for Done, StopOnSignal exits the process itself.
+func (l *Lifecycle) Done() <-chan struct{} {
+	l.awaited.Store(true)
+	return l.doneCh()
+}
+
+func (l *Lifecycle) doneCh() chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.done == nil {
+		l.done = make(chan struct{})
+	}
+
+	return l.done
+}
+
+// StopOnSignal stops the lifecycle once SIGINT or SIGTERM is received or the
+// context is cancelled. Only the first call has an effect.
+//
+// After stopping on a signal, the process is terminated by the signal unless
+// someone waits for Done, e.g. the http server of startup_http. Without that,
+// applications like cron jobs or consumers would keep running after their
+// components were stopped.
+func (l *Lifecycle) StopOnSignal(ctx context.Context) {
+	l.signalOnce.Do(func() {
+		signalCh := make(chan os.Signal, 1)
+		signal.Notify(signalCh, syscall.SIGINT, syscall.SIGTERM)
+
+		go func() {
+			defer signal.Stop(signalCh)
+
+			select {
+			case sig := <-signalCh:
+				log.Info("Signal received, shutting down", slog.String("signal", sig.String()))
+
+				_ = l.Stop(context.WithoutCancel(ctx))
+
+				if !l.awaited.Load() {
+					exitOnSignal(sig)
+				}
+
+			case <-ctx.Done():
+				log.Info("Context cancelled, shutting down")
+
+				_ = l.Stop(context.WithoutCancel(ctx))
+
+			case <-l.doneCh():
+				// stopped by someone else
+			}
+		}()
+	})
+}
+
+// exitOnSignal terminates the process with the default handling of the signal, so
+// the exit status tells that the process was killed by it.
+var exitOnSignal = func(sig os.Signal) {
+	signal.Reset(sig)
+
+	process, err := os.FindProcess(os.Getpid())
+	if err == nil {
+		err = process.Signal(sig)
+	}
+
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+func runHook(ctx context.Context, hook Hook, action string, fn func(ctx context.Context) error) error {
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = DefaultHookTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errCh <- fmt.Errorf("panic during %s: %v", action, r)
+			}
+		}()
+
+		errCh <- fn(ctx)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+
+	case <-ctx.Done():
+		return fmt.Errorf("%s timed out after %s: %w", action, timeout, ctx.Err())
+	}
+}
+
+// orderHooks sorts the hooks topologically, so that every hook comes after its
+// dependencies. Independent hooks keep their registration order.
+func orderHooks(hooks []Hook) ([]Hook, error) {
+	byName := map[string][]int{}
+	for idx, hook := range hooks {
+		byName[hook.Name] = append(byName[hook.Name], idx)
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make([]int, len(hooks))
+	ordered := make([]Hook, 0, len(hooks))
+
+	var visit func(idx int, path []string) error
+	visit = func(idx int, path []string) error {
+		switch state[idx] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle between hooks: %v", append(path, hooks[idx].Name))
+		}
+
+		state[idx] = visiting
+
+		for _, dependency := range hooks[idx].DependsOn {
+			for _, depIdx := range byName[dependency] {
+				if depIdx == idx {
+					continue
+				}
+
+				if err := visit(depIdx, append(path, hooks[idx].Name)); err != nil {
+					return err
+				}
+			}
+		}
+
+		state[idx] = visited
+		ordered = append(ordered, hooks[idx])
+
+		return nil
+	}
+
+	for idx := range hooks {
+		if err := visit(idx, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return ordered, nil
+}
@@ -0,0 +1,173 @@
+package startup_base
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type hookRecorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *hookRecorder) hook(name string, dependsOn ...string) Hook {
+	record := func(action string) func(context.Context) error {
+		return func(context.Context) error {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+
+			r.calls = append(r.calls, action+" "+name)
+			return nil
+		}
+	}
+
+	return Hook{
+		Name:      name,
+		DependsOn: dependsOn,
+		OnStart:   record("start"),
+		OnStop:    record("stop"),
+	}
+}
+
+func TestLifecycleOrder(t *testing.T) {
+	var rec hookRecorder
+
+	var lc Lifecycle
+
+	// registered in the order the startup modules usually do it
+	lc.Append(rec.hook(HookPostgres))
+	lc.Append(rec.hook(HookHTTP, HookKafkaConsumer, HookEventSender, HookTracing, HookPostgres))
+	lc.Append(rec.hook(HookKafkaConsumer, HookEventSender, HookPostgres))
+	lc.Append(rec.hook(HookEventSender, HookTracing, HookPostgres))
+	lc.Append(rec.hook(HookTracing, HookPostgres))
+
+	require.NoError(t, lc.Start(t.Context()))
+	require.NoError(t, lc.Stop(t.Context()))
+
+	require.Equal(t, []string{
+		"start postgres",
+		"start tracing",
+		"start event-sender",
+		"start kafka-consumer",
+		"start http",
+		"stop http",
+		"stop kafka-consumer",
+		"stop event-sender",
+		"stop tracing",
+		"stop postgres",
+	}, rec.calls)
+}
+
+func TestLifecycleUnknownDependency(t *testing.T) {
+	var rec hookRecorder
+
+	var lc Lifecycle
+	lc.Append(rec.hook("a", "does-not-exist"))
+	lc.Append(rec.hook("b"))
+
+	require.NoError(t, lc.Start(t.Context()))
+	require.Equal(t, []string{"start a", "start b"}, rec.calls)
+}
+
+func TestLifecycleCycle(t *testing.T) {
+	var rec hookRecorder
+
+	var lc Lifecycle
+	lc.Append(rec.hook("a", "b"))
+	lc.Append(rec.hook("b", "a"))
+
+	require.ErrorContains(t, lc.Start(t.Context()), "cycle")
+}
+
+func TestLifecycleLateRegistration(t *testing.T) {
+	var rec hookRecorder
+
+	var lc Lifecycle
+	require.NoError(t, lc.Start(t.Context()))
+
+	// hooks registered after start are started directly
+	lc.Append(rec.hook("late"))
+	require.Equal(t, []string{"start late"}, rec.calls)
+}
+
+func TestLifecycleStopContinuesOnError(t *testing.T) {
+	var rec hookRecorder
+
+	var lc Lifecycle
+	lc.Append(rec.hook("first"))
+
+	lc.Append(Hook{
+		Name:   "failing",
+		OnStop: func(context.Context) error { return errors.New("failed") },
+	})
+
+	lc.Append(Hook{
+		Name:    "slow",
+		Timeout: 10 * time.Millisecond,
+		OnStop: func(ctx context.Context) error {
+			<-ctx.Done()
+			time.Sleep(time.Second)
+			return nil
+		},
+	})
+
+	lc.Append(Hook{
+		Name:   "panics",
+		OnStop: func(context.Context) error { panic("boom") },
+	})
+
+	err := lc.Stop(t.Context())
+	require.ErrorContains(t, err, "failed")
+	require.ErrorContains(t, err, "timed out")
+	require.ErrorContains(t, err, "boom")
+
+	// the remaining hook was stopped anyways
+	require.Equal(t, []string{"stop first"}, rec.calls)
+
+	select {
+	case <-lc.Done():
+	default:
+		require.Fail(t, "lifecycle not done after stop")
+	}
+
+	// stopping twice has no effect
+	require.NoError(t, lc.Stop(t.Context()))
+	require.Equal(t, []string{"stop first"}, rec.calls)
+}
+
+func TestLifecycleStopOnSignalExits(t *testing.T) {
+	exited := make(chan os.Signal, 1)
+
+	previous := exitOnSignal
+	exitOnSignal = func(sig os.Signal) { exited <- sig }
+	t.Cleanup(func() { exitOnSignal = previous })
+
+	var rec hookRecorder
+
+	var lc Lifecycle
+	lc.Append(rec.hook("worker"))
+	require.NoError(t, lc.Start(t.Context()))
+
+	lc.StopOnSignal(t.Context())
+
+	process, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	require.NoError(t, process.Signal(syscall.SIGTERM))
+
+	select {
+	case sig := <-exited:
+		require.Equal(t, syscall.SIGTERM, sig)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "process did not exit after the signal")
+	}
+
+	// the components were stopped before exiting
+	require.Equal(t, []string{"start worker", "stop worker"}, rec.calls)
+}
@@ -0,0 +1,43 @@
+package startup_base
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Provider provides a value of a specific type that can be injected into the
+// Initialize method of other option structs. Create a Provider using Provide.
+//
+// An option struct declares the values it provides by implementing
+//
+//	Provides() []startup_base.Provider
+//
+// The provider function is called lazily, after the Initialize method of the
+// providing struct has run, and at most once.
+type Provider struct {
+	typ   reflect.Type
+	value func() reflect.Value
+}
+
+// Provide creates a provider for values of type T. T can be an interface type,
+// in which case the value is injected into parameters of exactly that interface type.
+func Provide[T any](fn func() T) Provider {
+	value := sync.OnceValue(func() reflect.Value {
+		result := fn()
+
+		// keep the static type T, even if T is an interface
+		return reflect.ValueOf(&result).Elem()
+	})
+
+	return Provider{typ: reflect.TypeFor[T](), value: value}
+}
+
+// Type returns the type of the provided value.
+func (p Provider) Type() reflect.Type {
+	return p.typ
+}
+
+// Value calls the provider function and returns the provided value.
+func (p Provider) Value() reflect.Value {
+	return p.value()
+}
@@ -0,0 +1,101 @@
+package startup_base
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sl "github.com/flachnetz/startup/v2/startup_logging"
+)
+
+// secretFile is a secret option value that was read from a file.
+type secretFile struct {
+	path  string
+	value atomic.Pointer[string]
+}
+
+// secretFiles maps the address of an option value to the secretFile it was read from.
+var secretFiles sync.Map
+
+// ReadSecretFile reads a secret from a file, as mounted by kubernetes. Trailing
+// newlines are removed.
+func ReadSecretFile(path string) (string, error) {
+	return readSecretFile(path)
+}
+
+func readSecretFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read secret file %q: %w", path, err)
+	}
+
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// RegisterSecretFile remembers that the value of the option was read from the file
+// at path, so rotated values can be picked up using ReloadableSecret.
+func RegisterSecretFile(option *string, path string) {
+	file := &secretFile{path: path}
+	file.value.Store(new(*option))
+
+	secretFiles.Store(option, file)
+}
+
+// ReloadableSecret returns a function that returns the current value of a secret option.
+// If the option value was read from a file, the function returns the latest content of
+// that file, see WatchSecretFiles. Otherwise the function always returns the value the
+// option has now.
+func ReloadableSecret(option *string) func() string {
+	file, ok := secretFiles.Load(option)
+	if !ok {
+		value := *option
+		return func() string { return value }
+	}
+
+	return func() string {
+		return *file.(*secretFile).value.Load()
+	}
+}
+
+// WatchSecretFiles re-reads all secret files every interval until the
+// context is cancelled.
+func WatchSecretFiles(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case <-ticker.C:
+				reloadSecretFiles()
+			}
+		}
+	}()
+}
+
+func reloadSecretFiles() {
+	secretFiles.Range(func(_, value any) bool {
+		file := value.(*secretFile)
+
+		content, err := readSecretFile(file.path)
+		if err != nil {
+			log.Warn("Failed to reload secret", slog.String("path", file.path), sl.Error(err))
+			return true
+		}
+
+		if previous := file.value.Swap(&content); *previous != content {
+			log.Info("Secret changed", slog.String("path", file.path))
+		}
+
+		return true
+	})
+}
@@ -0,0 +1,60 @@
+package startup_base
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloadableSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(path, []byte("first-password\n"), 0o600))
+
+	value, err := ReadSecretFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "first-password", value)
+
+	option := value
+	RegisterSecretFile(&option, path)
+
+	current := ReloadableSecret(&option)
+	require.Equal(t, "first-password", current())
+
+	// rotate the secret
+	require.NoError(t, os.WriteFile(path, []byte("second-password\n"), 0o600))
+	reloadSecretFiles()
+
+	require.Equal(t, "second-password", current())
+}
+
+func TestReloadableSecretNotFromFile(t *testing.T) {
+	option := "not-from-a-file"
+	current := ReloadableSecret(&option)
+	require.Equal(t, "not-from-a-file", current())
+}
+
+func TestReloadableSecretsWithSameValue(t *testing.T) {
+	dir := t.TempDir()
+
+	first := filepath.Join(dir, "first")
+	second := filepath.Join(dir, "second")
+	require.NoError(t, os.WriteFile(first, []byte("shared"), 0o600))
+	require.NoError(t, os.WriteFile(second, []byte("shared"), 0o600))
+
+	firstOption, secondOption, plainOption := "shared", "shared", "shared"
+	RegisterSecretFile(&firstOption, first)
+	RegisterSecretFile(&secondOption, second)
+
+	firstCurrent := ReloadableSecret(&firstOption)
+	secondCurrent := ReloadableSecret(&secondOption)
+	plainCurrent := ReloadableSecret(&plainOption)
+
+	require.NoError(t, os.WriteFile(second, []byte("rotated"), 0o600))
+	reloadSecretFiles()
+
+	require.Equal(t, "shared", firstCurrent())
+	require.Equal(t, "rotated", secondCurrent())
+	require.Equal(t, "shared", plainCurrent())
+}
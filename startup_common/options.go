@@ -34,9 +34,9 @@ type Options struct {
 }
 
 func (o *Options) PropagateInputs() {
-	if o.Postgres.Inputs.Initializer == nil {
+	if o.Postgres.Inputs.Migration == nil && o.Postgres.Inputs.Initializer == nil {
 		// automatically apply migrations on startup
-		o.Postgres.Inputs.Initializer = pg.DefaultMigration(o.Base.TableName("schema"))
+		o.Postgres.Inputs.Migration = pg.DefaultMigrator(o.Base.TableName("schema"))
 	}
 
 	if o.Events.Inputs.OutboxTable == "" {
@@ -1,6 +1,7 @@
 package startup_events
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -37,6 +38,13 @@ func (opts *EventOptions) Initialize(kafkaOptions startup_kafka.KafkaOptions) {
 	opts.kafkaOptions = kafkaOptions
 }
 
+// Provides makes the event sender injectable into Initialize methods.
+func (opts *EventOptions) Provides() []startup_base.Provider {
+	return []startup_base.Provider{
+		startup_base.Provide(opts.EventSender),
+	}
+}
+
 func (opts *EventOptions) EventSender() events.EventSender {
 	opts.eventSenderOnce.Do(func() {
 		eventSender, err := initializeEventSender(opts)
@@ -45,6 +53,15 @@ func (opts *EventOptions) EventSender() events.EventSender {
 		// register as global event sender
 		events.Sender = eventSender
 
+		// flush buffered events and the kafka producer on shutdown
+		startup_base.RegisterHook(startup_base.Hook{
+			Name:      startup_base.HookEventSender,
+			DependsOn: []string{startup_base.HookTracing, startup_base.HookPostgres},
+			OnStop: func(ctx context.Context) error {
+				return eventSender.Close()
+			},
+		})
+
 		opts.eventSender = eventSender
 	})
 
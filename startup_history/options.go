@@ -50,4 +50,10 @@ func (o HistoryOptions) Initialize(
 	})
 
 	startup_base.FatalOnError(err, "Setup history tracking")
+
+	startup_base.RegisterHook(startup_base.Hook{
+		Name:      startup_base.HookHistory,
+		DependsOn: []string{startup_base.HookEventSender, startup_base.HookPostgres},
+		OnStop:    history.StopGlobal,
+	})
 }
@@ -0,0 +1,84 @@
+package startup_http
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/flachnetz/startup/v2/startup_base"
+	sl "github.com/flachnetz/startup/v2/startup_logging"
+)
+
+// activeRequests counts the requests that are currently being served.
+type activeRequests struct {
+	count atomic.Int64
+}
+
+func (a *activeRequests) wrap(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.count.Add(1)
+		defer a.count.Add(-1)
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// drainOptions configures the graceful shutdown of the http server.
+type drainOptions struct {
+	// time to wait after the readiness was flipped, so that kubernetes
+	// can remove the pod from the service endpoints.
+	delay time.Duration
+
+	// maximum time to wait for active requests to finish.
+	timeout time.Duration
+
+	active *activeRequests
+
+	// registry whose readiness check fails while draining.
+	health *startup_base.HealthRegistry
+}
+
+// drainServer fails the readiness check, waits for the change to propagate and
+// shuts down the server. Connections that are still active after the timeout,
+// like long polls or server sent events, are closed forcefully.
+func drainServer(ctx context.Context, server *http.Server, drain drainOptions) error {
+	log := slog.With(slog.String("prefix", "httpd"))
+
+	drain.health.SetDraining()
+
+	if drain.delay > 0 {
+		log.InfoContext(ctx, "Readiness set to failing, waiting before shutdown", slog.Duration("delay", drain.delay))
+
+		select {
+		case <-time.After(drain.delay):
+		case <-ctx.Done():
+		}
+	}
+
+	log.InfoContext(ctx, "Shutting down http server",
+		slog.Duration("timeout", drain.timeout),
+		slog.Int64("activeRequests", drain.active.count.Load()))
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, drain.timeout)
+	defer cancel()
+
+	err := server.Shutdown(shutdownCtx)
+	if err == nil {
+		return nil
+	}
+
+	if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+		log.WarnContext(ctx, "Server shutdown", sl.Error(err))
+		return err
+	}
+
+	cut := drain.active.count.Load()
+
+	log.WarnContext(ctx, "Shutdown timed out, closing remaining connections",
+		slog.Int64("cutRequests", cut))
+
+	return server.Close()
+}
@@ -0,0 +1,58 @@
+package startup_http
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/flachnetz/startup/v2/startup_base"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrainServerClosesHangingRequests(t *testing.T) {
+	started := make(chan struct{})
+
+	active := &activeRequests{}
+	handler := active.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+
+		// a long poll that never finishes on its own
+		<-r.Context().Done()
+	}))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &http.Server{Handler: handler}
+	go func() { _ = server.Serve(listener) }()
+
+	requestDone := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+
+		requestDone <- err
+	}()
+
+	<-started
+	require.EqualValues(t, 1, active.count.Load())
+
+	err = drainServer(t.Context(), server, drainOptions{
+		delay:   10 * time.Millisecond,
+		timeout: 50 * time.Millisecond,
+		active:  active,
+		health:  &startup_base.HealthRegistry{},
+	})
+	require.NoError(t, err)
+
+	// the client sees the connection being cut
+	select {
+	case err := <-requestDone:
+		require.Error(t, err)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "request was not cut")
+	}
+}
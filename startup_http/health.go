@@ -0,0 +1,54 @@
+package startup_http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/flachnetz/startup/v2/startup_base"
+)
+
+// healthHandler serves the health report of the given type as json. The status
+// code is 200 if all checks pass and 503 otherwise, as expected by kubernetes probes.
+// Unless detailed is set, only the name and status of each check are served.
+func healthHandler(registry *startup_base.HealthRegistry, typ startup_base.HealthCheckType, detailed bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, request *http.Request) {
+		report := registry.Check(request.Context(), typ)
+
+		status := http.StatusOK
+		if !report.Healthy() {
+			status = http.StatusServiceUnavailable
+		}
+
+		if !detailed {
+			report = report.Redacted()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+
+		_ = json.NewEncoder(w).Encode(report)
+	}
+}
+
+// mergeWithHealthHandler serves <path>/live and <path>/ready without authentication,
+// all other requests are passed to rest. If path is empty, rest is returned as is.
+func mergeWithHealthHandler(registry *startup_base.HealthRegistry, path string, detailed bool, rest http.Handler) http.Handler {
+	if path == "" {
+		return rest
+	}
+
+	live := healthHandler(registry, startup_base.HealthLiveness, detailed)
+	ready := healthHandler(registry, startup_base.HealthReadiness, detailed)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
+		switch request.URL.Path {
+		case path + "/live":
+			live.ServeHTTP(w, request)
+		case path + "/ready":
+			ready.ServeHTTP(w, request)
+		default:
+			rest.ServeHTTP(w, request)
+		}
+	})
+}
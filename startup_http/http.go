@@ -3,21 +3,23 @@ package startup_http
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
 	"errors"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
-	"os"
-	"os/signal"
 	"strconv"
 	"strings"
-	"syscall"
 	"time"
 
 	tracing "github.com/flachnetz/startup/v2/startup_tracing"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/flachnetz/go-admin"
+	"github.com/flachnetz/startup/v2/lib/actor"
+	"github.com/flachnetz/startup/v2/lib/jwt"
 	"github.com/flachnetz/startup/v2/startup_base"
 	"github.com/goji/httpauth"
 	"github.com/gorilla/handlers"
@@ -34,8 +36,8 @@ type Config struct {
 	// Extra admin handlers to register on the admin page
 	AdminHandlers []admin.RouteConfig
 
-	// Registers a shutdown handler for the http server. If not set,
-	// a default signal handler for clean shutdown on SIGINT and SIGTERM is used.
+	// Registers a shutdown handler for the http server. If not set, the server
+	// is stopped by the startup_base.DefaultLifecycle on SIGINT and SIGTERM.
 	RegisterSignalHandlerForServer func(*http.Server) <-chan struct{}
 
 	// Wrap the http server with this middleware in the end. A good example would
@@ -45,20 +47,39 @@ type Config struct {
 
 type HttpMiddleware = func(http.Handler) http.Handler
 
+// defaultAdminPassword is the default of --http-admin-password. It is
+// refused for the admin listener in production.
+const defaultAdminPassword = "bingo"
+
 type HTTPOptions struct {
-	Address string `long:"http-address" env:"HTTP_ADDRESS" default:":3080" description:"Address to listen on."`
+	Address      string `long:"http-address" env:"HTTP_ADDRESS" default:":3080" description:"Address to listen on."`
+	AdminAddress string `long:"http-admin-address" env:"HTTP_ADMIN_ADDRESS" description:"Serve the admin page, metrics and pprof on a separate address, e.g. 127.0.0.1:3081. If set, /admin is not served on the public address."`
 
 	TLSKeyFile  string `long:"http-tls-key" env:"HTTP_TLS_KEY" description:"Private key file to enable SSL support."`
 	TLSCertFile string `long:"http-tls-cert" env:"HTTP_TLS_CERT" description:"Certificate file to enable SSL support."`
 
+	TLSReloadInterval     time.Duration `long:"http-tls-reload-interval" env:"HTTP_TLS_RELOAD_INTERVAL" default:"1m" description:"Interval to check the certificate files for changes. Disabled if zero."`
+	TLSClientCAFile       string        `long:"http-tls-client-ca" env:"HTTP_TLS_CLIENT_CA" description:"CA bundle to verify client certificates against. Enables mutual TLS."`
+	TLSClientAuthOptional bool          `long:"http-tls-client-auth-optional" env:"HTTP_TLS_CLIENT_AUTH_OPTIONAL" description:"Only verify client certificates if the client sends one."`
+
 	DisableAdminRedirect bool   `long:"http-disable-admin-redirect" env:"HTTP_DISABLE_ADMIN_REDIRECT" description:"Disable admin redirect on /"`
 	DisableAuth          bool   `long:"http-disable-admin-auth" env:"HTTP_DISABLE_ADMIN_AUTH" description:"Disable basic auth"`
 	BasicAuthUsername    string `long:"http-admin-username" env:"HTTP_ADMIN_USERNAME" default:"admin" description:"Basic auth username for admin panel."`
-	BasicAuthPassword    string `long:"http-admin-password" env:"HTTP_ADMIN_PASSWORD" default:"bingo" description:"Basic auth password for admin panel."`
+	BasicAuthPassword    string `long:"http-admin-password" env:"HTTP_ADMIN_PASSWORD" default:"bingo" secret:"true" file-env:"HTTP_ADMIN_PASSWORD_FILE" description:"Basic auth password for admin panel."`
 
 	AccessLog            string `long:"http-access-log" env:"HTTP_ACCESS_LOG" description:"Write http access log to a file. Defaults to stdout."`
 	AccessLogAdminRoute  bool   `long:"http-access-log-admin-route" env:"HTTP_ACCESS_LOG_ADMIN_ROUTE" description:"If enabled, admin route requests will also be logged."`
-	AdminPageShowEnvVars bool   `long:"http-admin-show-env-vars" env:"HTTP_ADMIN_SHOW_ENV_VARS" description:"Show environment variables on the admin page."`
+	AdminPageShowEnvVars bool   `long:"http-admin-show-env-vars" env:"HTTP_ADMIN_SHOW_ENV_VARS" hidden:"true" description:"Deprecated: has no effect, see /admin/config for the effective configuration."`
+
+	AccessLogFormat        string        `long:"http-access-log-format" env:"HTTP_ACCESS_LOG_FORMAT" default:"logfmt" choice:"logfmt" choice:"json" choice:"combined" description:"Format of the access log file. Requests logged to slog use the format of the application log."`
+	AccessLogFields        []string      `long:"http-access-log-field" env:"HTTP_ACCESS_LOG_FIELDS" env-delim:"," default:"size" choice:"size" choice:"trace-id" choice:"actor" choice:"upstream-latency" description:"Optional fields to include in the access log."`
+	AccessLogSample2xx     float64       `long:"http-access-log-sample-2xx" env:"HTTP_ACCESS_LOG_SAMPLE_2XX" default:"1" description:"Fraction of successful requests to log, between 0 and 1. Other requests are always logged."`
+	AccessLogSlowThreshold time.Duration `long:"http-access-log-slow-threshold" env:"HTTP_ACCESS_LOG_SLOW_THRESHOLD" description:"Requests slower than this are always logged, at info level if logged using slog. Disabled if zero."`
+
+	HealthPath string `long:"http-health-path" env:"HTTP_HEALTH_PATH" default:"/health" description:"Serve the liveness and readiness checks on <path>/live and <path>/ready. The public address only reports the status of each check, the admin address includes the errors. Set to an empty string to not serve the checks on the public address."`
+
+	ShutdownDelay   time.Duration `long:"http-shutdown-delay" env:"HTTP_SHUTDOWN_DELAY" default:"0s" description:"Time to wait between failing the readiness check and shutting down the server. Set this to a few seconds in kubernetes."`
+	ShutdownTimeout time.Duration `long:"http-shutdown-timeout" env:"HTTP_SHUTDOWN_TIMEOUT" default:"20s" description:"Maximum time to wait for active requests during shutdown. Remaining connections are closed afterwards."`
 
 	inputs        startup_base.Inputs
 	hasTracing    bool
@@ -66,11 +87,35 @@ type HTTPOptions struct {
 }
 
 func (opts *HTTPOptions) Initialize(ctx context.Context, base startup_base.BaseOptions, tracingOpts *tracing.TracingOptions) {
+	err := opts.checkAdminPassword()
+	startup_base.FatalOnError(err, "Refusing to start in production")
+
+	if opts.AccessLogSample2xx < 0 || opts.AccessLogSample2xx > 1 {
+		startup_base.Panicf("--http-access-log-sample-2xx must be between 0 and 1, got %v", opts.AccessLogSample2xx)
+	}
+
 	opts.inputs = base.Inputs
 	opts.hasTracing = tracingOpts != nil
 	opts.cancelContext = ctx
 }
 
+// checkAdminPassword fails for an admin listener in production that is protected by the
+// default password. Without a separate admin listener, only a warning is logged for now.
+func (opts *HTTPOptions) checkAdminPassword() error {
+	if !startup_base.IsProduction() || opts.DisableAuth || opts.BasicAuthPassword != defaultAdminPassword {
+		return nil
+	}
+
+	if opts.AdminAddress != "" {
+		return errors.New("the admin listener uses the default admin password, set --http-admin-password")
+	}
+
+	slog.Warn("The admin pages use the default admin password in production. Set --http-admin-password, " +
+		"a future release refuses to start with the default password.")
+
+	return nil
+}
+
 func (opts *HTTPOptions) ServeHandler(handler http.Handler) {
 	opts.Serve(Config{
 		Routing: func(mux *http.ServeMux) http.Handler { return handler },
@@ -98,11 +143,12 @@ func (opts *HTTPOptions) Serve(config Config) {
 		admin.WithHeapDump(),
 		// admin.WithMetrics(metrics.DefaultRegistry),
 		WithPrometheusMetrics("/metrics"),
+		WithEffectiveConfig(),
 		updateLogLevelHandler(),
 	}
 
 	if opts.AdminPageShowEnvVars {
-		routeConfigs = append(routeConfigs, admin.WithEnvironmentVariables())
+		slog.Warn("Option --http-admin-show-env-vars is deprecated and has no effect, use /admin/config instead")
 	}
 
 	if startup_base.BuildGitHash != "" {
@@ -126,7 +172,7 @@ func (opts *HTTPOptions) Serve(config Config) {
 		handler = config.Routing(router)
 	}
 
-	if !opts.DisableAdminRedirect {
+	if !opts.DisableAdminRedirect && opts.AdminAddress == "" {
 		// try to register / -> /admin redirect.
 		tryRegisterAdminHandlerRedirect(router)
 	}
@@ -135,11 +181,19 @@ func (opts *HTTPOptions) Serve(config Config) {
 	routeConfigs = append(routeConfigs, config.AdminHandlers...)
 
 	// Admin handler with a lot of admin-stuff
-	adminHandler := requireAuth(opts.DisableAuth, opts.BasicAuthUsername, opts.BasicAuthPassword,
+	adminHandler := requireAuth(opts.DisableAuth, opts.BasicAuthUsername, startup_base.ReloadableSecret(&opts.BasicAuthPassword),
 		admin.NewAdminHandler("/admin", appName, routeConfigs...))
 
-	// merge handlers
-	handler = mergeWithAdminHandler(adminHandler, handler)
+	if opts.AdminAddress != "" {
+		// serve admin handler on its own internal listener
+		opts.serveAdmin(adminHandler)
+	} else {
+		// merge handlers
+		handler = mergeWithAdminHandler(adminHandler, handler)
+	}
+
+	// the public listener only serves the status of the checks, details might leak internal hosts
+	handler = mergeWithHealthHandler(startup_base.DefaultHealthRegistry, opts.HealthPath, false, handler)
 
 	// don't let a panic crash the server.
 	recoveryStack := handlers.RecoveryHandler(
@@ -149,20 +203,28 @@ func (opts *HTTPOptions) Serve(config Config) {
 
 	handler = recoveryStack(handler)
 
+	accessLogConfig := accessLogConfig{
+		Fields:        opts.AccessLogFields,
+		Sample2xx:     opts.AccessLogSample2xx,
+		SlowThreshold: opts.AccessLogSlowThreshold,
+	}
+
 	if opts.AccessLog == "" {
 		// log all requests using slog logger
 		handler = loggingHandler{
 			handler: handler,
-			log: func(ctx context.Context, attrs []slog.Attr) {
-				if !opts.AccessLogAdminRoute {
-					for _, a := range attrs {
-						if a.Key == "uri" && strings.Contains(a.Value.String(), "/admin/") {
-							return
-						}
-					}
+			config:  accessLogConfig,
+			log: func(ctx context.Context, entry accessLogEntry, attrs []slog.Attr) {
+				if !opts.AccessLogAdminRoute && strings.Contains(requestURI(entry.Request), "/admin/") {
+					return
+				}
+
+				level := slog.LevelDebug
+				if entry.slow(opts.AccessLogSlowThreshold) {
+					level = slog.LevelInfo
 				}
 
-				access(ctx, attrs)
+				slog.LogAttrs(ctx, level, "access", attrs...)
 			},
 		}
 	} else if opts.AccessLog != "/dev/null" {
@@ -173,19 +235,8 @@ func (opts *HTTPOptions) Serve(config Config) {
 			// write events directly to log file
 			handler = loggingHandler{
 				handler: handler,
-				log: func(_ context.Context, attrs []slog.Attr) {
-					var b strings.Builder
-					for i, a := range attrs {
-						if i > 0 {
-							b.WriteByte(' ')
-						}
-						b.WriteString(a.Key)
-						b.WriteByte('=')
-						b.WriteString(a.Value.String())
-					}
-					b.WriteByte('\n')
-					_, _ = fp.Write([]byte(b.String()))
-				},
+				config:  accessLogConfig,
+				log:     accessLogWriter(fp, opts.AccessLogFormat),
 			}
 		}
 	}
@@ -194,6 +245,9 @@ func (opts *HTTPOptions) Serve(config Config) {
 		handler = config.UseMiddleware(handler)
 	}
 
+	active := &activeRequests{}
+	handler = active.wrap(handler)
+
 	server := &http.Server{
 		Addr:              opts.Address,
 		Handler:           handler,
@@ -203,7 +257,12 @@ func (opts *HTTPOptions) Serve(config Config) {
 
 	registerSignalHandler := config.RegisterSignalHandlerForServer
 	if registerSignalHandler == nil {
-		registerSignalHandler = buildSignalHandlerForServer(opts.cancelContext)
+		registerSignalHandler = buildSignalHandlerForServer(opts.cancelContext, drainOptions{
+			delay:   opts.ShutdownDelay,
+			timeout: opts.ShutdownTimeout,
+			active:  active,
+			health:  startup_base.DefaultHealthRegistry,
+		})
 	}
 
 	waitCh := registerSignalHandler(server)
@@ -216,9 +275,14 @@ func (opts *HTTPOptions) Serve(config Config) {
 		slog.Info("Start https server",
 			slog.String("address", opts.Address),
 			slog.String("cert", opts.TLSCertFile),
-			slog.String("key", opts.TLSKeyFile))
+			slog.String("key", opts.TLSKeyFile),
+			slog.String("clientCA", opts.TLSClientCAFile))
 
-		err = server.ListenAndServeTLS(opts.TLSCertFile, opts.TLSKeyFile)
+		server.TLSConfig, err = opts.tlsConfig(opts.cancelContext)
+		startup_base.PanicOnError(err, "Could not configure tls")
+
+		// certificates are provided by the tls config
+		err = server.ListenAndServeTLS("", "")
 	}
 
 	if errors.Is(err, http.ErrServerClosed) {
@@ -244,44 +308,93 @@ func WithPrometheusMetrics(s string) admin.RouteConfig {
 	)
 }
 
-func access(ctx context.Context, attrs []slog.Attr) {
-	args := make([]any, len(attrs))
-	for i, a := range attrs {
-		args[i] = a
-	}
-	slog.DebugContext(ctx, "access", args...)
+// WithEffectiveConfig serves the effective configuration of the application,
+// including the source of every value. Secrets are redacted.
+func WithEffectiveConfig() admin.RouteConfig {
+	return admin.Describe(
+		"The effective configuration with the source of every value. Secrets are redacted.",
+		admin.WithGenericValue("config", startup_base.EffectiveConfig),
+	)
 }
 
-func buildSignalHandlerForServer(ctx context.Context) func(*http.Server) <-chan struct{} {
+// buildSignalHandlerForServer registers the server with the startup_base.DefaultLifecycle.
+// The server is the first component to shut down on SIGINT and SIGTERM, the returned
+// channel is closed after all other components are stopped too.
+func buildSignalHandlerForServer(ctx context.Context, drain drainOptions) func(*http.Server) <-chan struct{} {
 	return func(server *http.Server) <-chan struct{} {
-		waitCh := make(chan struct{})
+		lifecycle := startup_base.DefaultLifecycle
+
+		lifecycle.Append(startup_base.Hook{
+			Name: startup_base.HookHTTP,
+			DependsOn: []string{
+				startup_base.HookHTTPAdmin,
+				startup_base.HookKafkaConsumer,
+				startup_base.HookEventSender,
+				startup_base.HookKafkaProducer,
+				startup_base.HookKafkaAdmin,
+				startup_base.HookOutburst,
+				startup_base.HookHistory,
+				startup_base.HookMetrics,
+				startup_base.HookTracing,
+				startup_base.HookPostgres,
+				startup_base.HookPostgresReplicas,
+			},
+			// leave some time to force close the connections after the shutdown timeout
+			Timeout: drain.delay + drain.timeout + 5*time.Second,
+			OnStop: func(stopCtx context.Context) error {
+				return drainServer(stopCtx, server, drain)
+			},
+		})
 
-		signalCh := make(chan os.Signal, 1)
+		// already done by ParseCommandLine, but the server might be started without it.
+		lifecycle.StopOnSignal(ctx)
 
-		go func() {
-			signal.Notify(signalCh, syscall.SIGINT, syscall.SIGTERM)
-			defer signal.Stop(signalCh)
+		return lifecycle.Done()
+	}
+}
 
-			select {
-			// wait for signal
-			case <-signalCh:
+// adminHealthPath is the path of the health checks on the admin listener. They
+// are served even if disabled on the public address, as the listener is internal.
+func (opts *HTTPOptions) adminHealthPath() string {
+	if opts.HealthPath == "" {
+		return "/health"
+	}
 
-			// wait for context cancellation
-			case <-ctx.Done():
-			}
+	return opts.HealthPath
+}
 
-			slog.InfoContext(ctx, "Signal received, shutting down", slog.String("prefix", "httpd"))
+// serveAdmin starts a separate server for the admin handler on AdminAddress. The
+// server is stopped after the public server, so metrics stay available while draining.
+func (opts *HTTPOptions) serveAdmin(adminHandler http.Handler) {
+	router := http.NewServeMux()
+	if !opts.DisableAdminRedirect {
+		tryRegisterAdminHandlerRedirect(router)
+	}
 
-			err := server.Shutdown(context.Background())
-			if err != nil {
-				slog.WarnContext(ctx, "Server shutdown", slog.String("prefix", "httpd"))
-			}
+	server := &http.Server{
+		Addr:              opts.AdminAddress,
+		Handler:           mergeWithHealthHandler(startup_base.DefaultHealthRegistry, opts.adminHealthPath(), true, mergeWithAdminHandler(adminHandler, router)),
+		ReadHeaderTimeout: 1 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
 
-			close(waitCh)
-		}()
+	listener, err := net.Listen("tcp", opts.AdminAddress)
+	startup_base.PanicOnError(err, "Could not listen on admin address %q", opts.AdminAddress)
 
-		return waitCh
-	}
+	startup_base.RegisterHook(startup_base.Hook{
+		Name:      startup_base.HookHTTPAdmin,
+		DependsOn: []string{startup_base.HookMetrics},
+		OnStop:    server.Shutdown,
+	})
+
+	slog.Info("Start admin http server", slog.String("address", opts.AdminAddress))
+
+	go func() {
+		err := server.Serve(listener)
+		if !errors.Is(err, http.ErrServerClosed) {
+			startup_base.PanicOnError(err, "Could not start admin server")
+		}
+	}()
 }
 
 func tryRegisterAdminHandlerRedirect(router *http.ServeMux) {
@@ -306,8 +419,23 @@ func mergeWithAdminHandler(admin, rest http.Handler) http.HandlerFunc {
 	}
 }
 
-func requireAuth(disableAuth bool, user, pass string, handler http.Handler) http.HandlerFunc {
-	authed := httpauth.SimpleBasicAuth(user, pass)(handler)
+// requireAuth protects the admin handler with basic auth. An authenticated request
+// carries the admin user as a jwt.Identity with the admin role, so admin pages can
+// gate their actions with lib/boff roles.
+func requireAuth(disableAuth bool, user string, pass func() string, handler http.Handler) http.HandlerFunc {
+	authed := httpauth.BasicAuth(httpauth.AuthOptions{
+		Realm: "Restricted",
+		AuthFunc: func(givenUser, givenPass string, _ *http.Request) bool {
+			// compare hashes to not leak the length of the credentials
+			givenUserHash := sha256.Sum256([]byte(givenUser))
+			givenPassHash := sha256.Sum256([]byte(givenPass))
+			userHash := sha256.Sum256([]byte(user))
+			passHash := sha256.Sum256([]byte(pass()))
+
+			return subtle.ConstantTimeCompare(givenUserHash[:], userHash[:]) == 1 &&
+				subtle.ConstantTimeCompare(givenPassHash[:], passHash[:]) == 1
+		},
+	})(withAdminIdentity(user, handler))
 
 	return func(writer http.ResponseWriter, request *http.Request) {
 		if disableAuth || request.URL.Path == "/admin/ping" {
@@ -318,6 +446,19 @@ func requireAuth(disableAuth bool, user, pass string, handler http.Handler) http
 	}
 }
 
+// withAdminIdentity puts the basic auth admin user as identity and audit actor into
+// the request context.
+func withAdminIdentity(user string, handler http.Handler) http.Handler {
+	identity := jwt.Identity{Subject: user, Roles: []string{jwt.RoleAdmin}}
+
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		ctx := jwt.WithIdentity(request.Context(), identity)
+		ctx = actor.WithActor(ctx, identity.Actor())
+
+		handler.ServeHTTP(writer, request.WithContext(ctx))
+	})
+}
+
 func updateLogLevelHandler() admin.RouteConfig {
 	return admin.Describe(
 		"Configure logging by posting a log level like 'info', 'debug' or 'warn' to this endpoint.",
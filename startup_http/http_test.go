@@ -0,0 +1,98 @@
+package startup_http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flachnetz/startup/v2/lib/jwt"
+	"github.com/flachnetz/startup/v2/startup_base"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireAuthProvidesAdminIdentity(t *testing.T) {
+	var identity *jwt.Identity
+
+	handler := requireAuth(false, "admin", func() string { return "secret" }, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, ok := jwt.IdentityFrom(r.Context()); ok {
+			identity = &id
+		}
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/outburst/pause", nil)
+	req.SetBasicAuth("admin", "wrong")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Nil(t, identity)
+
+	req.SetBasicAuth("admin", "secret")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotNil(t, identity)
+	require.Equal(t, "admin", identity.Subject)
+	require.True(t, identity.HasRole(jwt.RoleAdmin))
+}
+
+func TestCheckAdminPassword(t *testing.T) {
+	previous := startup_base.GetEnvironment()
+	t.Cleanup(func() { startup_base.SetEnvironment(previous) })
+
+	startup_base.SetEnvironment("production")
+
+	// without an admin listener the default password only logs a warning
+	opts := HTTPOptions{BasicAuthPassword: defaultAdminPassword}
+	require.NoError(t, opts.checkAdminPassword())
+
+	opts.AdminAddress = "127.0.0.1:3081"
+	require.ErrorContains(t, opts.checkAdminPassword(), "--http-admin-password")
+
+	opts.BasicAuthPassword = "secret"
+	require.NoError(t, opts.checkAdminPassword())
+
+	startup_base.SetEnvironment("development")
+
+	opts.BasicAuthPassword = defaultAdminPassword
+	require.NoError(t, opts.checkAdminPassword())
+}
+
+func TestMergeWithHealthHandler(t *testing.T) {
+	registry := &startup_base.HealthRegistry{}
+	registry.Register(startup_base.HealthCheck{
+		Name:  "database",
+		Check: func(ctx context.Context) error { return errors.New("dial tcp db.internal:5432: connection refused") },
+	})
+
+	app := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	serve := func(handler http.Handler, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	// the public listener only reports the status
+	public := mergeWithHealthHandler(registry, "/_health", false, app)
+
+	rec := serve(public, "/_health/ready")
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.JSONEq(t, `{"status":"failing","checks":[{"name":"database","status":"failing"}]}`, rec.Body.String())
+
+	// other paths, including the default one, are served by the application
+	require.Equal(t, http.StatusTeapot, serve(public, "/health/ready").Code)
+
+	// the admin listener includes the error
+	rec = serve(mergeWithHealthHandler(registry, "/health", true, app), "/health/ready")
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Contains(t, rec.Body.String(), "connection refused")
+
+	// an empty path disables the checks
+	require.Equal(t, http.StatusTeapot, serve(mergeWithHealthHandler(registry, "", false, app), "/health/live").Code)
+}
@@ -2,37 +2,170 @@ package startup_http
 
 import (
 	"context"
+	"encoding/json"
+	"io"
 	"log/slog"
+	"math/rand/v2"
 	"net"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
+	"unicode"
 
 	"github.com/felixge/httpsnoop"
+	"github.com/flachnetz/startup/v2/lib/actor"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// Optional fields of the access log.
+const (
+	AccessLogFieldTraceId         = "trace-id"
+	AccessLogFieldActor           = "actor"
+	AccessLogFieldSize            = "size"
+	AccessLogFieldUpstreamLatency = "upstream-latency"
+)
+
+// Formats of the access log file.
+const (
+	AccessLogFormatLogfmt   = "logfmt"
+	AccessLogFormatJSON     = "json"
+	AccessLogFormatCombined = "combined"
+)
+
+type upstreamLatencyKey struct{}
+
+// AddUpstreamLatency adds the time spent waiting for an upstream service to the
+// access log entry of the current request, see AccessLogFieldUpstreamLatency.
+func AddUpstreamLatency(ctx context.Context, latency time.Duration) {
+	if total, ok := ctx.Value(upstreamLatencyKey{}).(*atomic.Int64); ok {
+		total.Add(int64(latency))
+	}
+}
+
+// accessLogEntry describes a single served request.
+type accessLogEntry struct {
+	Time            time.Time
+	Request         *http.Request
+	Metrics         httpsnoop.Metrics
+	UpstreamLatency time.Duration
+
+	// actor of the request, as established by the handler
+	Actor    actor.Actor
+	HasActor bool
+}
+
+func (e accessLogEntry) slow(threshold time.Duration) bool {
+	return threshold > 0 && e.Metrics.Duration >= threshold
+}
+
+// accessLogConfig decides which requests are logged and with which fields.
+type accessLogConfig struct {
+	Fields []string
+
+	// fraction of requests with a 2xx status to log
+	Sample2xx float64
+
+	// requests slower than this are always logged, at Info level if written to slog
+	SlowThreshold time.Duration
+}
+
+// keep returns false if the entry is sampled out.
+func (c accessLogConfig) keep(entry accessLogEntry) bool {
+	status := entry.Metrics.Code
+	if status < 200 || status >= 300 || c.Sample2xx >= 1 || entry.slow(c.SlowThreshold) {
+		return true
+	}
+
+	return rand.Float64() < c.Sample2xx
+}
+
+func (c accessLogConfig) attrs(entry accessLogEntry) []slog.Attr {
+	req := entry.Request
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+
+	attrs := []slog.Attr{
+		slog.String("host", host),
+		slog.String("method", req.Method),
+		slog.String("uri", requestURI(req)),
+		slog.String("proto", req.Proto),
+		slog.Int("status", entry.Metrics.Code),
+	}
+
+	if slices.Contains(c.Fields, AccessLogFieldSize) {
+		attrs = append(attrs, slog.Int("size", int(entry.Metrics.Written)))
+	}
+
+	attrs = append(attrs, slog.Duration("latency", entry.Metrics.Duration))
+
+	if slices.Contains(c.Fields, AccessLogFieldUpstreamLatency) {
+		attrs = append(attrs, slog.Duration("upstreamLatency", entry.UpstreamLatency))
+	}
+
+	if slices.Contains(c.Fields, AccessLogFieldTraceId) {
+		if spanContext := trace.SpanContextFromContext(req.Context()); spanContext.HasTraceID() {
+			attrs = append(attrs, slog.String("traceId", spanContext.TraceID().String()))
+		}
+	}
+
+	if slices.Contains(c.Fields, AccessLogFieldActor) {
+		if entry.HasActor {
+			attrs = append(attrs,
+				slog.String("actorType", string(entry.Actor.Type)),
+				slog.String("actorId", entry.Actor.Id))
+		}
+	}
+
+	return attrs
+}
+
 type loggingHandler struct {
 	handler http.Handler
-	log     func(ctx context.Context, attrs []slog.Attr)
+	config  accessLogConfig
+	log     func(ctx context.Context, entry accessLogEntry, attrs []slog.Attr)
 }
 
 func (h loggingHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	startTime := time.Now()
 
-	var metrics httpsnoop.Metrics
-	metrics.CaptureMetrics(w, func(writer http.ResponseWriter) {
+	var upstreamLatency atomic.Int64
+	ctx := context.WithValue(req.Context(), upstreamLatencyKey{}, &upstreamLatency)
+
+	// the actor is usually attached by a middleware further down the chain
+	ctx, actors := actor.WithRecorder(ctx)
+
+	req = req.WithContext(ctx)
+
+	metrics := httpsnoop.CaptureMetricsFn(w, func(writer http.ResponseWriter) {
 		h.handler.ServeHTTP(writer, req)
 	})
 
-	attrs := buildAccessLogAttrs(req, startTime, metrics)
-	h.log(req.Context(), attrs)
-}
+	entry := accessLogEntry{
+		Time:            startTime,
+		Request:         req,
+		Metrics:         metrics,
+		UpstreamLatency: time.Duration(upstreamLatency.Load()),
+	}
 
-func buildAccessLogAttrs(req *http.Request, ts time.Time, metrics httpsnoop.Metrics) []slog.Attr {
-	host, _, err := net.SplitHostPort(req.RemoteAddr)
-	if err != nil {
-		host = req.RemoteAddr
+	entry.Actor, entry.HasActor = actor.FromContext(ctx)
+	if !entry.HasActor {
+		entry.Actor, entry.HasActor = actors.Actor()
+	}
+
+	if !h.config.keep(entry) {
+		return
 	}
 
+	h.log(req.Context(), entry, h.config.attrs(entry))
+}
+
+func requestURI(req *http.Request) string {
 	uri := req.RequestURI
 	if req.ProtoMajor == 2 && req.Method == "CONNECT" {
 		uri = req.Host
@@ -41,13 +174,123 @@ func buildAccessLogAttrs(req *http.Request, ts time.Time, metrics httpsnoop.Metr
 		uri = req.URL.RequestURI()
 	}
 
-	return []slog.Attr{
-		slog.String("host", host),
-		slog.String("method", req.Method),
-		slog.String("uri", uri),
-		slog.String("proto", req.Proto),
-		slog.Int("status", metrics.Code),
-		slog.Int("size", int(metrics.Written)),
-		slog.Duration("latency", metrics.Duration),
+	return uri
+}
+
+// accessLogWriter returns a function that writes access log entries in the given format.
+func accessLogWriter(w io.Writer, format string) func(ctx context.Context, entry accessLogEntry, attrs []slog.Attr) {
+	var formatter func(b []byte, entry accessLogEntry, attrs []slog.Attr) []byte
+
+	switch format {
+	case AccessLogFormatJSON:
+		formatter = appendJSON
+	case AccessLogFormatCombined:
+		formatter = appendCombined
+	default:
+		formatter = appendLogfmt
 	}
+
+	return func(_ context.Context, entry accessLogEntry, attrs []slog.Attr) {
+		line := formatter(nil, entry, attrs)
+		_, _ = w.Write(append(line, '\n'))
+	}
+}
+
+func appendLogfmt(b []byte, _ accessLogEntry, attrs []slog.Attr) []byte {
+	for i, a := range attrs {
+		if i > 0 {
+			b = append(b, ' ')
+		}
+
+		b = append(b, a.Key...)
+		b = append(b, '=')
+		b = appendLogValue(b, a.Value.String())
+	}
+
+	return b
+}
+
+// appendLogValue appends the value, quoted if it is empty or could be mistaken
+// for the separator of another field or line.
+func appendLogValue(b []byte, value string) []byte {
+	needsQuote := value == "" || strings.ContainsFunc(value, func(r rune) bool {
+		return r == ' ' || r == '"' || !unicode.IsPrint(r)
+	})
+
+	if needsQuote {
+		return strconv.AppendQuote(b, value)
+	}
+
+	return append(b, value...)
+}
+
+func appendJSON(b []byte, entry accessLogEntry, attrs []slog.Attr) []byte {
+	b = append(b, `{"time":`...)
+	b = strconv.AppendQuote(b, entry.Time.Format(time.RFC3339Nano))
+
+	for _, a := range attrs {
+		b = append(b, ',')
+		b = strconv.AppendQuote(b, a.Key)
+		b = append(b, ':')
+
+		switch a.Value.Kind() {
+		case slog.KindInt64:
+			b = strconv.AppendInt(b, a.Value.Int64(), 10)
+
+		case slog.KindDuration:
+			// milliseconds are easier to aggregate than go duration strings
+			b = strconv.AppendFloat(b, float64(a.Value.Duration().Microseconds())/1000, 'f', -1, 64)
+
+		default:
+			encoded, _ := json.Marshal(a.Value.String())
+			b = append(b, encoded...)
+		}
+	}
+
+	return append(b, '}')
+}
+
+// appendCombined formats the entry in the Apache combined log format.
+func appendCombined(b []byte, entry accessLogEntry, _ []slog.Attr) []byte {
+	req := entry.Request
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+
+	user := "-"
+	if entry.HasActor {
+		user = entry.Actor.Id
+	} else if username, _, ok := req.BasicAuth(); ok && username != "" {
+		user = username
+	}
+
+	b = append(b, host...)
+	b = append(b, " - "...)
+	b = appendLogValue(b, user)
+	b = append(b, " ["...)
+	b = entry.Time.AppendFormat(b, "02/Jan/2006:15:04:05 -0700")
+	b = append(b, "] "...)
+
+	// the request line is sent by the client, escape it like the other quoted fields
+	b = strconv.AppendQuote(b, req.Method+" "+requestURI(req)+" "+req.Proto)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(entry.Metrics.Code), 10)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, entry.Metrics.Written, 10)
+	b = append(b, ' ')
+	b = strconv.AppendQuote(b, orDash(req.Referer()))
+	b = append(b, ' ')
+	b = strconv.AppendQuote(b, orDash(req.UserAgent()))
+
+	return b
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
 }
@@ -0,0 +1,125 @@
+package startup_http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flachnetz/startup/v2/lib/actor"
+	"github.com/stretchr/testify/require"
+)
+
+func serveLogged(t *testing.T, config accessLogConfig, format string, handler http.HandlerFunc) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	logged := loggingHandler{handler: handler, config: config, log: accessLogWriter(&buf, format)}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/items?page=2", nil)
+	req.RemoteAddr = "10.0.0.1:4711"
+	req.Header.Set("User-Agent", "curl/8.0")
+
+	logged.ServeHTTP(httptest.NewRecorder(), req)
+
+	return buf.String()
+}
+
+func TestAccessLogLogfmt(t *testing.T) {
+	line := serveLogged(t, accessLogConfig{Fields: []string{AccessLogFieldSize}, Sample2xx: 1}, AccessLogFormatLogfmt,
+		func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("hello"))
+		})
+
+	require.Regexp(t, `^host=10.0.0.1 method=GET uri=/api/items\?page=2 proto=HTTP/1.1 status=200 size=5 latency=\S+\n$`, line)
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	config := accessLogConfig{
+		Fields:    []string{AccessLogFieldActor, AccessLogFieldUpstreamLatency},
+		Sample2xx: 1,
+	}
+
+	line := serveLogged(t, config, AccessLogFormatJSON, func(w http.ResponseWriter, r *http.Request) {
+		// an inner middleware establishes the actor
+		_ = actor.WithActor(r.Context(), actor.Actor{Type: actor.TypeUser, Id: "sub-1"})
+
+		AddUpstreamLatency(r.Context(), 1500*time.Microsecond)
+		AddUpstreamLatency(r.Context(), 500*time.Microsecond)
+
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	var parsed map[string]any
+	require.NoError(t, json.Unmarshal([]byte(line), &parsed))
+
+	require.Equal(t, "GET", parsed["method"])
+	require.EqualValues(t, http.StatusTeapot, parsed["status"])
+	require.EqualValues(t, 2, parsed["upstreamLatency"])
+	require.Equal(t, "user", parsed["actorType"])
+	require.Equal(t, "sub-1", parsed["actorId"])
+	require.NotContains(t, parsed, "size")
+	require.Contains(t, parsed, "time")
+}
+
+func TestAccessLogCombined(t *testing.T) {
+	line := serveLogged(t, accessLogConfig{Sample2xx: 1}, AccessLogFormatCombined,
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("not found"))
+		})
+
+	require.Regexp(t, `^10.0.0.1 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /api/items\?page=2 HTTP/1.1" 404 9 "-" "curl/8.0"\n$`, line)
+}
+
+func TestAccessLogCombinedEscapesClientValues(t *testing.T) {
+	line := serveLogged(t, accessLogConfig{Sample2xx: 1}, AccessLogFormatCombined,
+		func(w http.ResponseWriter, r *http.Request) {
+			r.RequestURI = "/api/\" 200 0\n10.0.0.2 - admin"
+			_ = actor.WithActor(r.Context(), actor.Actor{Type: actor.TypeUser, Id: "sub 1\nforged"})
+		})
+
+	require.Regexp(t, `^10.0.0.1 - "sub 1\\nforged" \[[^]]+\] "GET /api/\\" 200 0\\n10.0.0.2 - admin HTTP/1.1" 200 0 "-" "curl/8.0"\n$`, line)
+}
+
+func TestAccessLogLogfmtEscapesNewlines(t *testing.T) {
+	line := serveLogged(t, accessLogConfig{Fields: []string{AccessLogFieldActor}, Sample2xx: 1}, AccessLogFormatLogfmt,
+		func(w http.ResponseWriter, r *http.Request) {
+			_ = actor.WithActor(r.Context(), actor.Actor{Type: actor.TypeUser, Id: "sub-1\nforged=1"})
+		})
+
+	require.Contains(t, line, ` actorId="sub-1\nforged=1"`)
+	require.Equal(t, 1, strings.Count(line, "\n"))
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	config := accessLogConfig{Sample2xx: 0, SlowThreshold: 10 * time.Millisecond}
+
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	require.Empty(t, serveLogged(t, config, AccessLogFormatLogfmt, ok))
+
+	failing := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusInternalServerError) }
+	require.NotEmpty(t, serveLogged(t, config, AccessLogFormatLogfmt, failing))
+
+	slow := func(w http.ResponseWriter, r *http.Request) { time.Sleep(20 * time.Millisecond) }
+	require.NotEmpty(t, serveLogged(t, config, AccessLogFormatLogfmt, slow))
+}
+
+func TestAccessLogTraceIdMissing(t *testing.T) {
+	config := accessLogConfig{Fields: []string{AccessLogFieldTraceId}}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(context.Background())
+	attrs := config.attrs(accessLogEntry{Request: req})
+
+	for _, attr := range attrs {
+		require.NotEqual(t, "traceId", attr.Key, "request without span has no trace id")
+	}
+
+	require.IsType(t, slog.Attr{}, attrs[0])
+}
@@ -0,0 +1,180 @@
+package startup_http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+
+	sl "github.com/flachnetz/startup/v2/startup_logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var certificateExpiry = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "http_tls_certificate_expiry_timestamp_seconds",
+	Help: "Unix time at which the currently loaded certificate expires.",
+}, []string{"file", "type"})
+
+// certificateLoader serves the certificate from the given files and
+// reloads it once the files change on disk.
+type certificateLoader struct {
+	certFile string
+	keyFile  string
+
+	certificate atomic.Pointer[tls.Certificate]
+	modTime     time.Time
+}
+
+func newCertificateLoader(certFile, keyFile string) (*certificateLoader, error) {
+	loader := &certificateLoader{certFile: certFile, keyFile: keyFile}
+	if err := loader.reload(); err != nil {
+		return nil, err
+	}
+
+	return loader, nil
+}
+
+// GetCertificate can be used as tls.Config.GetCertificate.
+func (l *certificateLoader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return l.certificate.Load(), nil
+}
+
+// reload reads the certificate if the files changed since the last load.
+func (l *certificateLoader) reload() error {
+	modTime, err := latestModTime(l.certFile, l.keyFile)
+	if err != nil {
+		return err
+	}
+
+	if modTime.Equal(l.modTime) {
+		return nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate: %w", err)
+	}
+
+	l.certificate.Store(&certificate)
+	l.modTime = modTime
+
+	if leaf := certificate.Leaf; leaf != nil {
+		certificateExpiry.WithLabelValues(l.certFile, "server").Set(float64(leaf.NotAfter.Unix()))
+
+		slog.Info("Loaded tls certificate",
+			slog.String("cert", l.certFile),
+			slog.String("subject", leaf.Subject.String()),
+			slog.Time("notAfter", leaf.NotAfter))
+	}
+
+	return nil
+}
+
+// watch checks the files for changes every interval until the context is cancelled.
+// A certificate that fails to load is logged and the previous one is kept.
+func (l *certificateLoader) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			if err := l.reload(); err != nil {
+				slog.WarnContext(ctx, "Failed to reload tls certificate, keeping the current one",
+					slog.String("cert", l.certFile), sl.Error(err))
+			}
+		}
+	}
+}
+
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+
+	for _, file := range files {
+		stat, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("stat %q: %w", file, err)
+		}
+
+		if stat.ModTime().After(latest) {
+			latest = stat.ModTime()
+		}
+	}
+
+	return latest, nil
+}
+
+// loadClientCAs reads a pem encoded CA bundle to verify client certificates against.
+func loadClientCAs(file string) (*x509.CertPool, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read client ca bundle: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+
+	var count int
+	for block, rest := pem.Decode(content); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse client ca certificate: %w", err)
+		}
+
+		pool.AddCert(cert)
+		count++
+
+		certificateExpiry.WithLabelValues(file, "client-ca:"+cert.Subject.CommonName).Set(float64(cert.NotAfter.Unix()))
+	}
+
+	if count == 0 {
+		return nil, errors.New("no certificates found in client ca bundle")
+	}
+
+	return pool, nil
+}
+
+// tlsConfig builds the tls configuration for the http server. The certificate
+// is reloaded in the background until the context is cancelled.
+func (opts *HTTPOptions) tlsConfig(ctx context.Context) (*tls.Config, error) {
+	loader, err := newCertificateLoader(opts.TLSCertFile, opts.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.TLSReloadInterval > 0 {
+		go loader.watch(ctx, opts.TLSReloadInterval)
+	}
+
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: loader.GetCertificate,
+	}
+
+	if opts.TLSClientCAFile != "" {
+		config.ClientCAs, err = loadClientCAs(opts.TLSClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		if opts.TLSClientAuthOptional {
+			config.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,113 @@
+package startup_http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeSelfSignedCertificate writes a new self-signed certificate and its key
+// into the given files.
+func writeSelfSignedCertificate(t *testing.T, certFile, keyFile string, serial int64) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+
+	require.NoError(t, os.WriteFile(certFile, certPem, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, keyPem, 0o600))
+}
+
+func TestCertificateLoaderReloadsChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+
+	writeSelfSignedCertificate(t, certFile, keyFile, 1)
+
+	loader, err := newCertificateLoader(certFile, keyFile)
+	require.NoError(t, err)
+
+	cert, err := loader.GetCertificate(nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, cert.Leaf.SerialNumber.Int64())
+
+	// rotate the certificate
+	writeSelfSignedCertificate(t, certFile, keyFile, 2)
+
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, future, future))
+
+	require.NoError(t, loader.reload())
+
+	cert, err = loader.GetCertificate(nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, cert.Leaf.SerialNumber.Int64())
+}
+
+func TestCertificateLoaderKeepsCertificateOnError(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+
+	writeSelfSignedCertificate(t, certFile, keyFile, 1)
+
+	loader, err := newCertificateLoader(certFile, keyFile)
+	require.NoError(t, err)
+
+	// a half written certificate must not replace the current one
+	require.NoError(t, os.WriteFile(certFile, []byte("garbage"), 0o600))
+
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, future, future))
+
+	require.Error(t, loader.reload())
+
+	cert, err := loader.GetCertificate(nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, cert.Leaf.SerialNumber.Int64())
+}
+
+func TestLoadClientCAs(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "ca.crt")
+	keyFile := filepath.Join(dir, "ca.key")
+
+	writeSelfSignedCertificate(t, certFile, keyFile, 1)
+
+	pool, err := loadClientCAs(certFile)
+	require.NoError(t, err)
+	require.NotNil(t, pool)
+
+	_, err = loadClientCAs(keyFile)
+	require.ErrorContains(t, err, "no certificates")
+}
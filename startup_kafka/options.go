@@ -9,7 +9,9 @@ import (
 	"maps"
 	"net/http"
 	"regexp"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
@@ -45,6 +47,29 @@ type KafkaOptions struct {
 	ConfluentURL string `long:"confluent-url" default:"http://confluent-registry.shared.svc.cluster.local" env:"EVENT_SENDER_CONFLUENT_URL" description:"Confluent schema registry url."`
 
 	clientId string
+
+	// shared by all copies of the options, created in Initialize
+	producer *sharedProducer
+	admin    *sharedAdmin
+}
+
+type sharedProducer struct {
+	once     sync.Once
+	producer *kafka.Producer
+}
+
+// sharedAdmin is the admin client of the broker health check, created on the first check.
+type sharedAdmin struct {
+	mu     sync.Mutex
+	client *kafka.AdminClient
+	closed bool
+}
+
+// Provides makes the shared kafka producer injectable into Initialize methods.
+func (opts *KafkaOptions) Provides() []startup_base.Provider {
+	return []startup_base.Provider{
+		startup_base.Provide(opts.Producer),
+	}
 }
 
 // Initialize creates the configured topics on startup. It is a no-op unless
@@ -63,8 +88,90 @@ func (opts *KafkaOptions) Initialize(ctx context.Context, base startup_base.Base
 	}
 
 	opts.clientId = base.ServiceName
+	opts.producer = &sharedProducer{}
+	opts.admin = &sharedAdmin{}
 
 	opts.createTopics(ctx)
+
+	if len(opts.KafkaAddresses) > 0 {
+		startup_base.RegisterHealthCheck(startup_base.HealthCheck{
+			Name:  "kafka",
+			Check: opts.checkBrokers,
+		})
+	}
+}
+
+// checkBrokers fetches the broker metadata using an admin client of its own, so that
+// applications that only consume do not create the shared producer.
+func (opts *KafkaOptions) checkBrokers(ctx context.Context) error {
+	timeout := startup_base.DefaultHealthCheckTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
+	client, err := opts.adminClient()
+	if err != nil {
+		return fmt.Errorf("create admin client: %w", err)
+	}
+
+	metadata, err := client.GetMetadata(nil, false, int(timeout.Milliseconds()))
+	if err != nil {
+		return fmt.Errorf("get broker metadata: %w", err)
+	}
+
+	if len(metadata.Brokers) == 0 {
+		return errors.New("no brokers available")
+	}
+
+	return nil
+}
+
+// adminClient returns the admin client of the health check. It is created on first
+// use and closed on shutdown.
+func (opts *KafkaOptions) adminClient() (*kafka.AdminClient, error) {
+	shared := opts.admin
+
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+
+	if shared.closed {
+		return nil, errors.New("kafka client closed")
+	}
+
+	if shared.client != nil {
+		return shared.client, nil
+	}
+
+	config := opts.DefaultConfig(kafka.ConfigMap{
+		// nobody reads the logs of the admin client
+		"go.logs.channel.enable": false,
+	})
+
+	for _, k := range slices.Concat(producerOnlyKeys, consumerOnlyKeys) {
+		delete(config, k)
+	}
+
+	client, err := kafka.NewAdminClient(new(config))
+	if err != nil {
+		return nil, err
+	}
+
+	startup_base.RegisterHook(startup_base.Hook{
+		Name: startup_base.HookKafkaAdmin,
+		OnStop: func(ctx context.Context) error {
+			shared.mu.Lock()
+			defer shared.mu.Unlock()
+
+			shared.client.Close()
+			shared.closed = true
+
+			return nil
+		},
+	})
+
+	shared.client = client
+
+	return client, nil
 }
 
 func (opts *KafkaOptions) createTopics(ctx context.Context) {
@@ -117,6 +224,42 @@ func (opts *KafkaOptions) NewProducer(overrideConfig kafka.ConfigMap) *kafka.Pro
 	return producer
 }
 
+// Producer returns a producer using DefaultConfig that is shared within the application.
+// The producer is flushed and closed on shutdown.
+func (opts *KafkaOptions) Producer() *kafka.Producer {
+	if opts.producer == nil {
+		opts.producer = &sharedProducer{}
+	}
+
+	shared := opts.producer
+	shared.once.Do(func() {
+		producer := opts.NewProducer(nil)
+
+		startup_base.RegisterHook(startup_base.Hook{
+			Name:      startup_base.HookKafkaProducer,
+			DependsOn: []string{startup_base.HookTracing},
+			OnStop: func(ctx context.Context) error {
+				defer producer.Close()
+
+				timeoutMs := 5000
+				if deadline, ok := ctx.Deadline(); ok {
+					timeoutMs = int(time.Until(deadline).Milliseconds())
+				}
+
+				if remaining := producer.Flush(timeoutMs); remaining > 0 {
+					return fmt.Errorf("%d messages not delivered", remaining)
+				}
+
+				return nil
+			},
+		})
+
+		shared.producer = producer
+	})
+
+	return shared.producer
+}
+
 // producerOnlyKeys are rdkafka properties that only apply to producers.
 // They live in the shared DefaultConfig, so strip them when building a
 // consumer to avoid CONFWARN log noise.
@@ -53,6 +53,12 @@ func (opts *MetricsOptions) Initialize(base startup_base.BaseOptions) {
 		if !opts.PrometheusConfig.Disabled {
 			opts.PrometheusConfig.httpServer = startPrometheusMetrics(opts.PrometheusConfig)
 		}
+
+		startup_base.RegisterHook(startup_base.Hook{
+			Name:      startup_base.HookMetrics,
+			DependsOn: []string{startup_base.HookPostgres},
+			OnStop:    opts.shutdown,
+		})
 	})
 }
 
@@ -82,8 +88,10 @@ func (opts *MetricsOptions) captureRuntimeMetrics() {
 }
 
 func (opts *MetricsOptions) Shutdown() error {
-	ctx := context.Background()
+	return opts.shutdown(context.Background())
+}
 
+func (opts *MetricsOptions) shutdown(ctx context.Context) error {
 	if opts.PrometheusConfig.httpServer != nil {
 		if err := opts.PrometheusConfig.httpServer.Shutdown(ctx); err != nil {
 			sl.LoggerOf(ctx).ErrorContext(ctx, "Failed to shutdown Prometheus HTTP server", sl.Error(err))
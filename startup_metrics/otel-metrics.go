@@ -56,6 +56,12 @@ func (opts *OTELMetricsOptions) Initialize() {
 			// Start Prometheus HTTP server
 			opts.PrometheusConfig.httpServer = startPrometheusMetrics(opts.PrometheusConfig)
 		}
+
+		startup_base.RegisterHook(startup_base.Hook{
+			Name:      startup_base.HookMetrics,
+			DependsOn: []string{startup_base.HookPostgres},
+			OnStop:    opts.shutdown,
+		})
 	})
 }
 
@@ -86,8 +92,11 @@ func (opts *OTELMetricsOptions) captureRuntimeMetrics() {
 }
 
 func (opts *OTELMetricsOptions) Shutdown() error {
+	return opts.shutdown(context.Background())
+}
+
+func (opts *OTELMetricsOptions) shutdown(ctx context.Context) error {
 	// Shutdown Prometheus HTTP server if it exists
-	ctx := context.Background()
 	if opts.PrometheusConfig.httpServer != nil {
 		if err := opts.PrometheusConfig.httpServer.Shutdown(ctx); err != nil {
 			sl.LoggerOf(ctx).Error("Failed to shutdown Prometheus HTTP server", sl.Error(err))
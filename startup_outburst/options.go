@@ -1,8 +1,14 @@
 package startup_outburst
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"time"
 
+	confluent "github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/flachnetz/startup/v2/lib/events/outburst"
 	sb "github.com/flachnetz/startup/v2/startup_base"
 	"github.com/flachnetz/startup/v2/startup_kafka"
@@ -12,11 +18,53 @@ import (
 // Options wires the outburst outbox consumer into the startup framework and
 // exposes its tunables as command line flags. Zero values fall back to the
 // library defaults (4 workers, 128 queue buffer, 128 batch size).
+//
+// The flags configure the default outbox. Further outboxes, e.g. one for bulk
+// events next to the default one, are configured via Inputs.Outboxes.
 type Options struct {
-	WorkerCount       uint `long:"outburst-worker-count" env:"OUTBURST_WORKER_COUNT" default:"4" description:"Number of key-sharded workers on the notify path. Rows sharing a kafka_key keep their order at any value."`
-	WorkerQueueBuffer uint `long:"outburst-queue-buffer" env:"OUTBURST_QUEUE_BUFFER" default:"128" description:"Buffer size of each per-shard worker queue. A full queue applies backpressure to the listen loop."`
-	BatchSize         uint `long:"outburst-batch-size" env:"OUTBURST_BATCH_SIZE" default:"128" description:"Number of rows read per batch by the fallback cron."`
-	EnableDebug       bool `long:"outburst-debug" env:"OUTBURST_DEBUG" description:"Enable outburst debug logging."`
+	WorkerCount       uint  `long:"outburst-worker-count" env:"OUTBURST_WORKER_COUNT" default:"4" description:"Number of key-sharded workers on the notify path. Rows sharing a kafka_key keep their order at any value."`
+	WorkerQueueBuffer uint  `long:"outburst-queue-buffer" env:"OUTBURST_QUEUE_BUFFER" default:"128" description:"Buffer size of each per-shard worker queue. A full queue applies backpressure to the listen loop."`
+	BatchSize         uint  `long:"outburst-batch-size" env:"OUTBURST_BATCH_SIZE" default:"128" description:"Number of rows read per batch by the fallback cron."`
+	MaxBacklog        int64 `long:"outburst-max-backlog" env:"OUTBURST_MAX_BACKLOG" description:"Outbox size above which the readiness check fails. Disabled if zero."`
+	MaxAttempts       uint  `long:"outburst-max-attempts" env:"OUTBURST_MAX_ATTEMPTS" default:"10" description:"Failed attempts after which a row is moved into the quarantine table."`
+	StrictOrdering    bool  `long:"outburst-strict-ordering" env:"OUTBURST_STRICT_ORDERING" description:"Publish the rows of a kafka_key strictly in order, also across lost notifications and the fallback cron."`
+	EnableDebug       bool  `long:"outburst-debug" env:"OUTBURST_DEBUG" description:"Enable outburst debug logging."`
+
+	// Inputs holds values that are not parsed from the command line but injected
+	// by the caller before Initialize runs.
+	Inputs struct {
+		// Outboxes are relayed in addition to the default outbox.
+		Outboxes []Outbox
+	}
+}
+
+// Outbox configures an additional outbox with its own relay. Zero values fall
+// back to the library defaults.
+type Outbox struct {
+	// Name of the outbox. Labels the metrics and names the health check.
+	// Required and unique.
+	Name string
+
+	// Table of the outbox, defaults to the table name of "<Name>_outbox".
+	Table string
+
+	// Channel the insert trigger notifies on, defaults to "<Name>-message". Write
+	// to the outbox using events.WriteToOutboxChannel.
+	Channel string
+
+	WorkerCount       uint
+	WorkerQueueBuffer uint
+	BatchSize         uint
+	MaxBacklog        int64
+	MaxAttempts       uint
+
+	// StrictOrdering enables strict per-key ordering for this outbox, it is
+	// always enabled if the default outbox uses strict ordering.
+	StrictOrdering bool
+
+	// ProducerConfig overrides the default kafka configuration for the producer
+	// of this outbox, e.g. a higher linger.ms for bulk events.
+	ProducerConfig confluent.ConfigMap
 }
 
 // Initialize creates the outbox table and starts the outburst background task.
@@ -26,15 +74,107 @@ func (o *Options) Initialize(
 	kafka startup_kafka.KafkaOptions,
 	pg *startup_postgres.PostgresOptions,
 ) {
-	err := outburst.Initialize(ctx, outburst.Options{
-		Kafka:              kafka.NewProducer(nil),
+	if pg == nil {
+		sb.Panicf("The outbox needs the startup_postgres.PostgresOptions in the options")
+	}
+
+	producer := kafka.NewProducer(producerConfig(nil, o.StrictOrdering))
+	producers := []*confluent.Producer{producer}
+
+	stop, err := outburst.Initialize(ctx, outburst.Options{
+		Kafka:              producer,
 		Database:           pg.Connection(),
 		OutboxTable:        base.TableName("outbox"),
 		WorkerCount:        o.WorkerCount,
 		WorkerQueueBuffer:  o.WorkerQueueBuffer,
 		BatchSize:          o.BatchSize,
+		MaxBacklog:         o.MaxBacklog,
+		MaxAttempts:        o.MaxAttempts,
+		StrictOrdering:     o.StrictOrdering,
 		EnableDebugLogging: o.EnableDebug,
 	})
 
 	sb.FatalOnError(err, "Create outbox failed")
+
+	stops := []func(ctx context.Context) error{stop}
+
+	names := map[string]bool{}
+	for _, outbox := range o.Inputs.Outboxes {
+		if outbox.Name == "" || names[outbox.Name] {
+			sb.Panicf("Outbox needs a unique name, got %q", outbox.Name)
+		}
+
+		names[outbox.Name] = true
+
+		producer := kafka.NewProducer(producerConfig(outbox.ProducerConfig, outbox.StrictOrdering || o.StrictOrdering))
+		producers = append(producers, producer)
+
+		stop, err := outburst.Initialize(ctx, outburst.Options{
+			Kafka:              producer,
+			Database:           pg.Connection(),
+			OutboxTable:        cmp.Or(outbox.Table, base.TableName(outbox.Name+"_outbox")),
+			Name:               outbox.Name,
+			Channel:            cmp.Or(outbox.Channel, outbox.Name+"-message"),
+			WorkerCount:        cmp.Or(outbox.WorkerCount, o.WorkerCount),
+			WorkerQueueBuffer:  cmp.Or(outbox.WorkerQueueBuffer, o.WorkerQueueBuffer),
+			BatchSize:          cmp.Or(outbox.BatchSize, o.BatchSize),
+			MaxBacklog:         outbox.MaxBacklog,
+			MaxAttempts:        cmp.Or(outbox.MaxAttempts, o.MaxAttempts),
+			StrictOrdering:     outbox.StrictOrdering || o.StrictOrdering,
+			EnableDebugLogging: o.EnableDebug,
+		})
+
+		sb.FatalOnError(err, "Create outbox %q failed", outbox.Name)
+
+		stops = append(stops, stop)
+	}
+
+	sb.RegisterHook(sb.Hook{
+		Name:      sb.HookOutburst,
+		DependsOn: []string{sb.HookTracing, sb.HookPostgres},
+		OnStop: func(ctx context.Context) error {
+			// stop the relays first, no row is produced after they returned
+			var errs error
+			for _, stop := range stops {
+				errs = errors.Join(errs, stop(ctx))
+			}
+
+			if errs != nil {
+				// a relay might still produce, keep the producers open
+				return errs
+			}
+
+			timeoutMs := 5000
+			if deadline, ok := ctx.Deadline(); ok {
+				timeoutMs = int(time.Until(deadline).Milliseconds())
+			}
+
+			var remaining int
+			for _, producer := range producers {
+				remaining += producer.Flush(timeoutMs)
+				producer.Close()
+			}
+
+			if remaining > 0 {
+				return fmt.Errorf("%d messages not delivered", remaining)
+			}
+
+			return nil
+		},
+	})
+}
+
+// producerConfig returns the kafka configuration of the producer of an outbox. Strict
+// ordering needs an idempotent producer, so retries can not reorder the messages of a
+// batch.
+func producerConfig(config confluent.ConfigMap, strictOrdering bool) confluent.ConfigMap {
+	if !strictOrdering {
+		return config
+	}
+
+	result := confluent.ConfigMap{}
+	maps.Copy(result, config)
+	result["enable.idempotence"] = true
+
+	return result
 }
@@ -19,6 +19,12 @@ type Tracer interface {
 	AcquireConnectionEnd(ctx context.Context)
 }
 
+// TransactionRetryTracer can be implemented by a Tracer to record the retries of a
+// transaction. The context is the one returned by Tracer.TransactionStart.
+type TransactionRetryTracer interface {
+	TransactionRetry(ctx context.Context, attempt int, err error)
+}
+
 var globalTracer atomic.Pointer[Tracer]
 
 func InstallTracer(tracer Tracer) {
@@ -0,0 +1,59 @@
+package startup_postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+)
+
+// AdvisoryLockKey maps a string to the integer key of a postgres advisory lock.
+//
+// Postgres locks on integers, not on strings. As such we just hash the string into
+// some 63 bit integer using the pretty good fnv hash. Conflicts are _pretty_ rare and
+// in our case normally not a problem. If there would be a conflict, then two processes
+// are waiting on the same lock, which probably just means a little longer waiting time.
+func AdvisoryLockKey(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return h.Sum64() & ^(uint64(1) << 63)
+}
+
+// withSessionLock runs fn while holding the session level advisory lock for the given key.
+// The lock is held by a dedicated connection, so fn can use other connections of the pool.
+// A pool of a single connection is rejected, fn would wait for a connection forever.
+func withSessionLock(ctx context.Context, db *sql.DB, key string, fn func() error) error {
+	if db.Stats().MaxOpenConnections == 1 {
+		return fmt.Errorf("advisory lock %q needs a pool of at least two connections", key)
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("connection for advisory lock %q: %w", key, err)
+	}
+
+	defer func() { _ = conn.Close() }()
+
+	lock := AdvisoryLockKey(key)
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", lock).Scan(&locked); err != nil {
+		return fmt.Errorf("getting advisory lock %q: %w", key, err)
+	}
+
+	if !locked {
+		slog.InfoContext(ctx, "Waiting for advisory lock", slog.String("prefix", "database"), slog.String("lock", key))
+
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lock); err != nil {
+			return fmt.Errorf("getting advisory lock %q: %w", key, err)
+		}
+	}
+
+	defer func() {
+		// closing the connection would release it too, but it goes back to the pool
+		_, _ = conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", lock)
+	}()
+
+	return fn()
+}
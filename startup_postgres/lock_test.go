@@ -0,0 +1,36 @@
+package startup_postgres
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdvisoryLockKey(t *testing.T) {
+	// keys must never change, or two versions of an application stop excluding each other
+	require.Equal(t, uint64(0x58858c6888bf01a7), AdvisoryLockKey("startup_postgres.migration:schema"))
+
+	require.NotEqual(t, AdvisoryLockKey("a"), AdvisoryLockKey("b"))
+
+	for _, key := range []string{"", "a", "startup_postgres.migration:schema"} {
+		require.Zero(t, AdvisoryLockKey(key)>>63, "lock key must fit into a bigint")
+	}
+}
+
+func TestSessionLockNeedsSecondConnection(t *testing.T) {
+	db, err := sql.Open("pgx", "postgres://localhost:1/unused")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	db.SetMaxOpenConns(1)
+
+	// the database is never touched
+	err = withSessionLock(t.Context(), db, "test", func() error {
+		require.Fail(t, "must not run")
+		return nil
+	})
+
+	require.ErrorContains(t, err, "at least two connections")
+}
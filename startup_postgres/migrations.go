@@ -1,46 +1,318 @@
 package startup_postgres
 
 import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log/slog"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
+	fsx "github.com/flachnetz/startup/v2/lib/fs"
 	"github.com/flachnetz/startup/v2/startup_base"
 	"github.com/jmoiron/sqlx"
 	migrate "github.com/rubenv/sql-migrate"
 )
 
+var ErrDownInProduction = errors.New("refusing to run down migrations in production without --postgres-migrate-force")
+
+// MigrateCommand selects what a migration Initializer does, see --postgres-migrate.
+type MigrateCommand struct {
+	// Kind is one of "up", "status", "down", "redo" or "dry-run".
+	Kind string
+
+	// Steps is the number of migrations to roll back for "down".
+	Steps int
+
+	// Force allows down migrations in production.
+	Force bool
+}
+
+// ParseMigrateCommand parses the value of --postgres-migrate.
+func ParseMigrateCommand(value string) (MigrateCommand, error) {
+	kind, steps, hasSteps := strings.Cut(value, ":")
+
+	switch kind {
+	case "", "up":
+		return MigrateCommand{Kind: "up"}, nil
+
+	case "status", "redo", "dry-run":
+		if hasSteps {
+			return MigrateCommand{}, fmt.Errorf("migrate command %q takes no argument", kind)
+		}
+
+		return MigrateCommand{Kind: kind}, nil
+
+	case "down":
+		command := MigrateCommand{Kind: kind, Steps: 1}
+
+		if hasSteps {
+			n, err := strconv.Atoi(steps)
+			if err != nil || n < 1 {
+				return MigrateCommand{}, fmt.Errorf("invalid number of steps in migrate command %q", value)
+			}
+
+			command.Steps = n
+		}
+
+		return command, nil
+
+	default:
+		return MigrateCommand{}, fmt.Errorf("unknown migrate command %q", value)
+	}
+}
+
+// Exits returns true if the command is an operational command. The application
+// exits once the command finished instead of starting up.
+func (c MigrateCommand) Exits() bool {
+	return c.Kind != "up"
+}
+
+// Migrator runs the database migration for the migrate command, see --postgres-migrate.
+// Configure it as PostgresOptions.Inputs.Migration to support all migrate commands.
+type Migrator func(db *sqlx.DB, command MigrateCommand) error
+
+// Up returns an Initializer that applies all pending migrations.
+func (m Migrator) Up() Initializer {
+	return func(db *sqlx.DB) error {
+		return m(db, MigrateCommand{Kind: "up"})
+	}
+}
+
 // Migration Runs a migration with the sql files from the given directory.
 // The directory must exist. The migration library will use the given table
-// name to store the migration progress
+// name to store the migration progress.
+//
+// The Initializer applies all pending migrations. Use NewMigrator to support
+// the other commands of --postgres-migrate.
 func Migration(table, directory string) Initializer {
+	return NewMigrator(table, directory).Up()
+}
+
+// MigrationFS runs a migration with the sql files from the given directory of the file
+// system, usually an embed.FS. This does not depend on the working directory of the
+// application. A file system wrapped in a fsx.NoReadDirFS is unwrapped, as the migration
+// needs to list the directory. See Migration on how to configure the migration.
+//
+//	//go:embed sql/*.sql
+//	var migrations embed.FS
+//
+//	opts.Postgres.Inputs.Initializer = startup_postgres.MigrationFS("schema", migrations, "sql")
+func MigrationFS(table string, files fs.FS, directory string) Initializer {
+	return NewMigratorFS(table, files, directory).Up()
+}
+
+// NewMigrator is Migration for all commands of --postgres-migrate.
+//
+//	opts.Postgres.Inputs.Migration = startup_postgres.NewMigrator("schema", "sql")
+func NewMigrator(table, directory string) Migrator {
 	if _, err := os.Stat(directory); os.IsNotExist(err) {
 		startup_base.PanicOnError(err, "No database migration files found")
 	}
 
-	return func(db *sqlx.DB) error {
-		migrate.SetTable(table)
+	return migration(table, &migrate.FileMigrationSource{Dir: directory})
+}
+
+// NewMigratorFS is MigrationFS for all commands of --postgres-migrate.
+func NewMigratorFS(table string, files fs.FS, directory string) Migrator {
+	source, err := migrationSourceFS(files, directory)
+	startup_base.PanicOnError(err, "No database migration files found")
+
+	return migration(table, source)
+}
+
+func migrationSourceFS(files fs.FS, directory string) (migrate.MigrationSource, error) {
+	if noReadDir, ok := files.(fsx.NoReadDirFS); ok {
+		files = noReadDir.Unwrap()
+	}
+
+	sub, err := fs.Sub(files, directory)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := fs.ReadDir(sub, "."); err != nil {
+		return nil, err
+	}
+
+	return migrate.HttpFileSystemMigrationSource{FileSystem: http.FS(sub)}, nil
+}
 
-		migrations := &migrate.FileMigrationSource{Dir: directory}
-		n, err := migrate.Exec(db.DB, "postgres", migrations, migrate.Up)
+// migration creates the Migrator for the migration source. The migration is serialized using
+// an advisory lock on the migration table, so concurrently starting instances of the
+// application never run the migration at the same time.
+func migration(table string, source migrate.MigrationSource) Migrator {
+	return func(db *sqlx.DB, command MigrateCommand) error {
+		set := migrate.MigrationSet{TableName: table}
+
+		return withSessionLock(context.Background(), db.DB, migrationLockKey(table), func() error {
+			return runMigrations(db.DB, set, source, command, os.Stdout)
+		})
+	}
+}
+
+func migrationLockKey(table string) string {
+	return "startup_postgres.migration:" + table
+}
+
+func runMigrations(db *sql.DB, set migrate.MigrationSet, source migrate.MigrationSource, command MigrateCommand, out io.Writer) error {
+	logger := slog.With(slog.String("prefix", "database"))
+
+	if (command.Kind == "down" || command.Kind == "redo") && startup_base.IsProduction() && !command.Force {
+		return ErrDownInProduction
+	}
+
+	switch command.Kind {
+	case "status":
+		migrations, err := source.FindMigrations()
 		if err != nil {
-			return fmt.Errorf("applying database migration: %w", err)
+			return fmt.Errorf("loading database migrations: %w", err)
+		}
+
+		records, err := set.GetMigrationRecords(db, "postgres")
+		if err != nil {
+			return fmt.Errorf("loading applied database migrations: %w", err)
 		}
 
-		slog.Info(
-			"Migrations executed",
-			slog.String("prefix", "database"),
-			slog.Int("count", n),
-		)
+		return writeMigrationStatus(out, migrations, records)
 
+	case "dry-run":
+		planned, _, err := set.PlanMigration(db, "postgres", source, migrate.Up, 0)
+		if err != nil {
+			return fmt.Errorf("planning database migration: %w", err)
+		}
+
+		return writeMigrationPlan(out, planned)
+
+	case "down":
+		n, err := set.ExecMax(db, "postgres", source, migrate.Down, command.Steps)
+		if err != nil {
+			return fmt.Errorf("rolling back database migration: %w", err)
+		}
+
+		logger.Warn("Migrations rolled back", slog.Int("count", n))
+		return nil
+
+	case "redo":
+		n, err := set.ExecMax(db, "postgres", source, migrate.Down, 1)
+		if err != nil {
+			return fmt.Errorf("rolling back database migration: %w", err)
+		}
+
+		if n == 0 {
+			return errors.New("no applied database migration to redo")
+		}
+
+		if _, err := set.ExecMax(db, "postgres", source, migrate.Up, 1); err != nil {
+			return fmt.Errorf("reapplying database migration: %w", err)
+		}
+
+		logger.Warn("Migration redone")
+		return nil
+
+	default:
+		n, err := set.Exec(db, "postgres", source, migrate.Up)
+		if err != nil {
+			return fmt.Errorf("applying database migration: %w", err)
+		}
+
+		logger.Info("Migrations executed", slog.Int("count", n))
 		return nil
 	}
 }
 
+// writeMigrationStatus prints a table of all known and applied migrations.
+func writeMigrationStatus(out io.Writer, migrations []*migrate.Migration, records []*migrate.MigrationRecord) error {
+	applied := map[string]time.Time{}
+	for _, record := range records {
+		applied[record.Id] = record.AppliedAt
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "MIGRATION\tSTATUS\tAPPLIED AT\tCHECKSUM")
+
+	for _, m := range migrations {
+		status, appliedAt := "pending", "-"
+		if at, ok := applied[m.Id]; ok {
+			status, appliedAt = "applied", at.UTC().Format(time.RFC3339)
+			delete(applied, m.Id)
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", m.Id, status, appliedAt, migrationChecksum(m))
+	}
+
+	// applied migrations without a file
+	for _, record := range records {
+		if _, ok := applied[record.Id]; ok {
+			_, _ = fmt.Fprintf(w, "%s\tunknown\t%s\t-\n", record.Id, record.AppliedAt.UTC().Format(time.RFC3339))
+		}
+	}
+
+	return w.Flush()
+}
+
+// writeMigrationPlan prints the statements of the planned migrations.
+func writeMigrationPlan(out io.Writer, planned []*migrate.PlannedMigration) error {
+	if len(planned) == 0 {
+		_, err := fmt.Fprintln(out, "-- no pending migrations")
+		return err
+	}
+
+	for _, m := range planned {
+		if _, err := fmt.Fprintf(out, "-- migration %s\n", m.Id); err != nil {
+			return err
+		}
+
+		for _, query := range m.Queries {
+			if _, err := fmt.Fprintln(out, strings.TrimSpace(query)); err != nil {
+				return err
+			}
+		}
+
+		if _, err := fmt.Fprintln(out); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrationChecksum identifies the content of a migration file.
+func migrationChecksum(m *migrate.Migration) string {
+	hash := sha256.New()
+
+	for _, query := range m.Up {
+		_, _ = io.WriteString(hash, query)
+	}
+
+	_, _ = io.WriteString(hash, "\x00")
+
+	for _, query := range m.Down {
+		_, _ = io.WriteString(hash, query)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))[:12]
+}
+
 // DefaultMigration creates an Initializer that performs a database migration by looking for
-// sql files in the default directories.
+// sql files in the default directories. Prefer MigrationFS with embedded files, so the
+// application does not depend on its working directory.
 func DefaultMigration(table string) Initializer {
-	return Migration(table, guessMigrationDirectory())
+	return DefaultMigrator(table).Up()
+}
+
+// DefaultMigrator is DefaultMigration for all commands of --postgres-migrate.
+func DefaultMigrator(table string) Migrator {
+	return NewMigrator(table, guessMigrationDirectory())
 }
 
 func guessMigrationDirectory() string {
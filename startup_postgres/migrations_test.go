@@ -0,0 +1,140 @@
+package startup_postgres
+
+import (
+	"bytes"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	fsx "github.com/flachnetz/startup/v2/lib/fs"
+	"github.com/flachnetz/startup/v2/startup_base"
+	migrate "github.com/rubenv/sql-migrate"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMigrateCommand(t *testing.T) {
+	valid := map[string]MigrateCommand{
+		"":        {Kind: "up"},
+		"up":      {Kind: "up"},
+		"status":  {Kind: "status"},
+		"redo":    {Kind: "redo"},
+		"dry-run": {Kind: "dry-run"},
+		"down":    {Kind: "down", Steps: 1},
+		"down:3":  {Kind: "down", Steps: 3},
+	}
+
+	for value, expected := range valid {
+		command, err := ParseMigrateCommand(value)
+		require.NoError(t, err, value)
+		require.Equal(t, expected, command, value)
+	}
+
+	for _, value := range []string{"down:0", "down:x", "status:1", "sideways"} {
+		_, err := ParseMigrateCommand(value)
+		require.Error(t, err, value)
+	}
+
+	require.False(t, MigrateCommand{Kind: "up"}.Exits())
+	require.True(t, MigrateCommand{Kind: "status"}.Exits())
+}
+
+func TestRunMigrationsRefusesDownInProduction(t *testing.T) {
+	previous := startup_base.GetEnvironment()
+	t.Cleanup(func() { startup_base.SetEnvironment(previous) })
+
+	startup_base.SetEnvironment("production")
+
+	for _, command := range []MigrateCommand{{Kind: "down", Steps: 1}, {Kind: "redo"}} {
+		// the database is never touched
+		err := runMigrations(nil, migrate.MigrationSet{}, migrate.MemoryMigrationSource{}, command, nil)
+		require.ErrorIs(t, err, ErrDownInProduction)
+	}
+}
+
+func TestWriteMigrationStatus(t *testing.T) {
+	migrations := []*migrate.Migration{
+		{Id: "0001_init.sql", Up: []string{"CREATE TABLE a ();"}, Down: []string{"DROP TABLE a;"}},
+		{Id: "0002_more.sql", Up: []string{"CREATE TABLE b ();"}},
+	}
+
+	appliedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	records := []*migrate.MigrationRecord{
+		{Id: "0000_gone.sql", AppliedAt: appliedAt},
+		{Id: "0001_init.sql", AppliedAt: appliedAt},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeMigrationStatus(&buf, migrations, records))
+
+	expected := "" +
+		"MIGRATION      STATUS   APPLIED AT            CHECKSUM\n" +
+		"0001_init.sql  applied  2026-03-01T12:00:00Z  " + migrationChecksum(migrations[0]) + "\n" +
+		"0002_more.sql  pending  -                     " + migrationChecksum(migrations[1]) + "\n" +
+		"0000_gone.sql  unknown  2026-03-01T12:00:00Z  -\n"
+
+	require.Equal(t, expected, buf.String())
+
+	// the checksum changes with the content
+	changed := *migrations[0]
+	changed.Up = []string{"CREATE TABLE a (id int);"}
+	require.NotEqual(t, migrationChecksum(migrations[0]), migrationChecksum(&changed))
+}
+
+func TestWriteMigrationPlan(t *testing.T) {
+	planned := []*migrate.PlannedMigration{{
+		Migration: &migrate.Migration{Id: "0002_more.sql"},
+		Queries:   []string{"CREATE TABLE b ();\n"},
+	}}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeMigrationPlan(&buf, planned))
+	require.Equal(t, "-- migration 0002_more.sql\nCREATE TABLE b ();\n\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeMigrationPlan(&buf, nil))
+	require.Equal(t, "-- no pending migrations\n", buf.String())
+}
+
+func TestMigrationSourceFS(t *testing.T) {
+	files := fstest.MapFS{
+		"sql/0001_init.sql": {Data: []byte("-- +migrate Up\nCREATE TABLE a ();\n\n-- +migrate Down\nDROP TABLE a;\n")},
+		"sql/0002_more.sql": {Data: []byte("-- +migrate Up\nCREATE TABLE b ();\n")},
+		"static/index.html": {Data: []byte("<html></html>")},
+	}
+
+	// the same file system as served over http, without directory listings
+	for _, files := range []fs.FS{files, fsx.NewNoReadDirFS(files)} {
+		source, err := migrationSourceFS(files, "sql")
+		require.NoError(t, err)
+
+		migrations, err := source.FindMigrations()
+		require.NoError(t, err)
+
+		require.Len(t, migrations, 2)
+		require.Equal(t, "0001_init.sql", migrations[0].Id)
+		require.Len(t, migrations[0].Down, 1)
+		require.Equal(t, "0002_more.sql", migrations[1].Id)
+	}
+
+	_, err := migrationSourceFS(files, "missing")
+	require.Error(t, err)
+}
+
+func TestRunCommand(t *testing.T) {
+	// up starts the application
+	opts := PostgresOptions{Migrate: "up"}
+	ran, err := opts.RunCommand()
+	require.NoError(t, err)
+	require.False(t, ran)
+
+	// other commands need a migration, the database is never touched
+	opts = PostgresOptions{Migrate: "status"}
+	_, err = opts.RunCommand()
+	require.ErrorContains(t, err, `no database migration configured to run migrate command "status"`)
+
+	opts = PostgresOptions{Migrate: "sideways"}
+	_, err = opts.RunCommand()
+	require.ErrorContains(t, err, "invalid --postgres-migrate")
+}
@@ -0,0 +1,212 @@
+package startup_postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/flachnetz/startup/v2/startup_base"
+	sl "github.com/flachnetz/startup/v2/startup_logging"
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// replicaLagQuery returns the replication lag in seconds. A replica that replayed
+// everything it received is not lagging, even if the primary was idle for a while.
+const replicaLagQuery = `
+	SELECT CASE
+		WHEN NOT pg_is_in_recovery() THEN 0
+		WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+	END`
+
+// ReplicaSet routes read only transactions to hot standby replicas. Replicas are checked
+// periodically and only used if they are reachable and not lagging too far behind.
+// If no replica can be used, the primary is used instead.
+//
+// A ReplicaSet can be used as ql.TxStarter, transactions started with BeginTxx
+// always run on the primary. Use ql.InReadOnlyTransaction to run on a replica.
+type ReplicaSet struct {
+	primary  *sqlx.DB
+	replicas []*replica
+	maxLag   time.Duration
+
+	next atomic.Uint64
+}
+
+type replica struct {
+	name string
+	db   *sqlx.DB
+
+	healthy atomic.Bool
+	lag     atomic.Int64
+}
+
+// NewReplicaSet creates a ReplicaSet from already opened database pools. Call
+// Check to update the state of the replicas, they are considered unhealthy until then.
+func NewReplicaSet(primary *sqlx.DB, maxLag time.Duration, replicas ...*sqlx.DB) *ReplicaSet {
+	set := &ReplicaSet{primary: primary, maxLag: maxLag}
+
+	for idx, db := range replicas {
+		set.replicas = append(set.replicas, &replica{name: fmt.Sprintf("replica-%d", idx), db: db})
+	}
+
+	return set
+}
+
+// BeginTxx starts a transaction on the primary.
+func (set *ReplicaSet) BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error) {
+	return set.primary.BeginTxx(ctx, opts)
+}
+
+// Connx returns a connection of the primary.
+func (set *ReplicaSet) Connx(ctx context.Context) (*sqlx.Conn, error) {
+	return set.primary.Connx(ctx)
+}
+
+// Primary returns the pool of the primary database.
+func (set *ReplicaSet) Primary() *sqlx.DB {
+	return set.primary
+}
+
+// Replica returns the pool of a healthy replica that does not lag more than the
+// configured maximum. Usable replicas are picked round-robin. If no replica is
+// usable, the primary is returned.
+func (set *ReplicaSet) Replica() *sqlx.DB {
+	var candidates []*replica
+	for _, r := range set.replicas {
+		if r.healthy.Load() && time.Duration(r.lag.Load()) <= set.maxLag {
+			candidates = append(candidates, r)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return set.primary
+	}
+
+	idx := set.next.Add(1) % uint64(len(candidates))
+	return candidates[idx].db
+}
+
+// Check updates health and lag of all replicas.
+func (set *ReplicaSet) Check(ctx context.Context) {
+	for _, r := range set.replicas {
+		r.check(ctx)
+	}
+}
+
+func (r *replica) check(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	var lagSeconds float64
+	err := r.db.GetContext(ctx, &lagSeconds, replicaLagQuery)
+
+	healthy := err == nil
+	if previous := r.healthy.Swap(healthy); previous != healthy {
+		if healthy {
+			slog.InfoContext(ctx, "Replica is healthy", slog.String("prefix", "postgres"), slog.String("replica", r.name))
+		} else {
+			slog.WarnContext(ctx, "Replica is unhealthy", slog.String("prefix", "postgres"), slog.String("replica", r.name), sl.Error(err))
+		}
+	}
+
+	if healthy {
+		r.lag.Store(int64(lagSeconds * float64(time.Second)))
+	}
+}
+
+// watch checks the replicas every interval until the context is cancelled.
+func (set *ReplicaSet) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			set.Check(ctx)
+		}
+	}
+}
+
+func (set *ReplicaSet) observeStats() {
+	m := otel.Meter("db.pool")
+
+	healthy, _ := m.Int64ObservableGauge("db.replica.healthy")
+	lag, _ := m.Int64ObservableGauge("db.replica.lag_ms")
+
+	_, _ = m.RegisterCallback(
+		func(_ context.Context, o metric.Observer) error {
+			for _, r := range set.replicas {
+				attrs := metric.WithAttributes(attribute.String("pool", r.name))
+
+				var healthyValue int64
+				if r.healthy.Load() {
+					healthyValue = 1
+				}
+
+				o.ObserveInt64(healthy, healthyValue, attrs)
+				o.ObserveInt64(lag, time.Duration(r.lag.Load()).Milliseconds(), attrs)
+			}
+
+			return nil
+		},
+		healthy, lag,
+	)
+
+	for _, r := range set.replicas {
+		observeStats(r.name, r.db)
+	}
+}
+
+// ReplicaSet returns the ReplicaSet of the primary Connection and the configured replicas.
+// Without replicas, all transactions run on the primary.
+func (opts *PostgresOptions) ReplicaSet() *ReplicaSet {
+	opts.replicasOnce.Do(func() {
+		logger := slog.With(slog.String("prefix", "postgres"))
+
+		var replicas []*sqlx.DB
+		for idx := range opts.ReplicaURLs {
+			db, _ := opts.open(logger, &opts.ReplicaURLs[idx], opts.ReplicaPoolSize, opts.ReplicaConnectionLifetime)
+			replicas = append(replicas, db)
+		}
+
+		set := NewReplicaSet(opts.Connection(), opts.ReplicaMaxLag, replicas...)
+		if len(replicas) > 0 {
+			ctx, cancel := context.WithCancel(context.Background())
+
+			set.Check(ctx)
+			set.observeStats()
+
+			if opts.ReplicaCheckInterval > 0 {
+				go set.watch(ctx, opts.ReplicaCheckInterval)
+			}
+
+			startup_base.RegisterHook(startup_base.Hook{
+				Name: startup_base.HookPostgresReplicas,
+				OnStop: func(ctx context.Context) error {
+					cancel()
+
+					logger.Info("Closing replica connection pools")
+					for _, db := range replicas {
+						startup_base.Close(db, "Close replica connection pool")
+					}
+
+					return nil
+				},
+			})
+		}
+
+		opts.replicas = set
+	})
+
+	return opts.replicas
+}
@@ -0,0 +1,38 @@
+package startup_postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplicaSetSelection(t *testing.T) {
+	primary, first, second := &sqlx.DB{}, &sqlx.DB{}, &sqlx.DB{}
+
+	set := NewReplicaSet(primary, time.Second, first, second)
+
+	// replicas are unhealthy until checked
+	require.Same(t, primary, set.Replica())
+
+	set.replicas[0].healthy.Store(true)
+	set.replicas[1].healthy.Store(true)
+
+	picked := map[*sqlx.DB]int{}
+	for range 10 {
+		picked[set.Replica()]++
+	}
+
+	require.Equal(t, map[*sqlx.DB]int{first: 5, second: 5}, picked)
+
+	// the second replica lags too far behind
+	set.replicas[1].lag.Store(int64(2 * time.Second))
+	for range 4 {
+		require.Same(t, first, set.Replica())
+	}
+
+	// fall back to the primary
+	set.replicas[0].healthy.Store(false)
+	require.Same(t, primary, set.Replica())
+}
@@ -64,5 +64,12 @@ func (opts *TracingOptions) Initialize(base startup_base.BaseOptions) {
 			propagation.TraceContext{},
 			propagation.Baggage{},
 		))
+
+		// flush pending spans to the exporter on shutdown
+		startup_base.RegisterHook(startup_base.Hook{
+			Name:      startup_base.HookTracing,
+			DependsOn: []string{startup_base.HookPostgres},
+			OnStop:    tp.Shutdown,
+		})
 	})
 }
@@ -102,6 +102,19 @@ func (t *tracer) TransactionEnd(ctx context.Context) {
 	trace.SpanFromContext(ctx).End()
 }
 
+func (t *tracer) TransactionRetry(ctx context.Context, attempt int, err error) {
+	if ctx.Value(pg_trace.DisableTracingKey) != nil {
+		return
+	}
+
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Int("tx.retries", attempt))
+	span.AddEvent("retry", trace.WithAttributes(
+		attribute.Int("tx.attempt", attempt),
+		attribute.String("error", err.Error()),
+	))
+}
+
 func (t *tracer) AcquireConnectionStart(ctx context.Context) context.Context {
 	if ctx.Value(pg_trace.DisableTracingKey) != nil {
 		return ctx
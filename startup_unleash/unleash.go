@@ -19,7 +19,7 @@ type Unleash struct {
 	}
 
 	BaseUrl  startup.URL `long:"unleash-base-url" env:"UNLEASH_BASE_URL" default:"http://unleash.shared.svc.cluster.local/api" description:"Unleash base URL"`
-	ApiToken string      `long:"unleash-api-token" env:"UNLEASH_API_TOKEN" default:"default:production.unleash-insecure-api-token" description:"Unleash API token"`
+	ApiToken string      `long:"unleash-api-token" env:"UNLEASH_API_TOKEN" default:"default:production.unleash-insecure-api-token" secret:"true" file-env:"UNLEASH_API_TOKEN_FILE" description:"Unleash API token"`
 }
 
 func (o Unleash) Initialize(base startup_base.BaseOptions) {